@@ -8,7 +8,7 @@ import (
 // V1 list.
 
 type ListInput struct {
-	NextToken  string `json:"NextTovi ken,omitempty"`
+	NextToken  string `json:"NextToken,omitempty"`
 	MaxResults int    `json:"MaxResults,omitempty"`
 }
 
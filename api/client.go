@@ -14,6 +14,7 @@ import (
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/feed"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/fqdn"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logprofile"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/predefinedurl"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/prefix"
@@ -127,6 +128,11 @@ type ApiClient struct {
 	maxGortns int
 	XSLPath   string
 	Mock      bool
+	logger    logging.Logger
+
+	// owned is set if the client was built by New, and so is closed with
+	// the ApiClient.
+	owned bool
 }
 
 type EndPointInput struct {
@@ -140,6 +146,22 @@ func SetLogger(logger *zap.SugaredLogger) {
 	Logger = logger
 }
 
+// SetLogger sets the structured logger used by this client.
+func (c *ApiClient) SetLogger(l logging.Logger) {
+	c.logger = l
+}
+
+// log returns the client's logger, falling back to the global Logger.
+func (c *ApiClient) log() logging.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	if Logger != nil {
+		return logging.Zap(Logger)
+	}
+	return logging.Nop()
+}
+
 func (c *ApiClient) SetEndpoint(ctx context2.Context, input EndPointInput) error {
 	return c.client.SetEndpoint(ctx, input)
 }
@@ -169,6 +191,9 @@ func (c *ApiClient) GetProfile(ctx context2.Context) string {
 }
 
 // sdk consumers instantiate APIClient using NewAPIClient() and invoke APIs under api directory
+//
+// Deprecated: Use New, which sets up the client and returns an error
+// instead of exiting when no logger is set.
 func NewAPIClient(client Client, ctx context.Context, maxGortns int, XSLPath string, mock bool) *ApiClient {
 	if !mock && Logger == nil {
 		log.Fatalf("Initialize logger using SetLogger()")
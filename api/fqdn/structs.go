@@ -13,6 +13,7 @@ type ListInput struct {
 	Running     bool   `json:"Running,omitempty"`
 	Uncommitted bool   `json:"Uncommitted,omitempty"`
 	MaxResults  int    `json:"MaxResults,omitempty"`
+	NextToken   string `json:"NextToken,omitempty"`
 }
 
 type ListOutput struct {
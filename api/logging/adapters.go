@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"log"
+	"log/slog"
+
+	"go.uber.org/zap"
+)
+
+// Zap returns a Logger backed by a zap logger.
+func Zap(l *zap.SugaredLogger) Logger {
+	return zapLogger{l}
+}
+
+type zapLogger struct {
+	l *zap.SugaredLogger
+}
+
+func (z zapLogger) Debug(msg string, fields ...Field) {
+	z.l.Debugw(msg, keyvals(Redact(fields))...)
+}
+
+func (z zapLogger) Info(msg string, fields ...Field) {
+	z.l.Infow(msg, keyvals(Redact(fields))...)
+}
+
+func (z zapLogger) Warn(msg string, fields ...Field) {
+	z.l.Warnw(msg, keyvals(Redact(fields))...)
+}
+
+func (z zapLogger) Error(msg string, fields ...Field) {
+	z.l.Errorw(msg, keyvals(Redact(fields))...)
+}
+
+func (z zapLogger) With(fields ...Field) Logger {
+	return zapLogger{z.l.With(keyvals(Redact(fields))...)}
+}
+
+// Slog returns a Logger backed by a log/slog logger.
+func Slog(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) log(level slog.Level, msg string, fields []Field) {
+	s.l.Log(context.Background(), level, msg, keyvals(Redact(fields))...)
+}
+
+func (s slogLogger) Debug(msg string, fields ...Field) {
+	s.log(slog.LevelDebug, msg, fields)
+}
+
+func (s slogLogger) Info(msg string, fields ...Field) {
+	s.log(slog.LevelInfo, msg, fields)
+}
+
+func (s slogLogger) Warn(msg string, fields ...Field) {
+	s.log(slog.LevelWarn, msg, fields)
+}
+
+func (s slogLogger) Error(msg string, fields ...Field) {
+	s.log(slog.LevelError, msg, fields)
+}
+
+func (s slogLogger) With(fields ...Field) Logger {
+	return slogLogger{s.l.With(keyvals(Redact(fields))...)}
+}
+
+/*
+Std returns a Logger that writes to a standard library logger, which is what
+the SDK did before structured logging. Debug lines are dropped unless debug
+is set.
+*/
+func Std(l *log.Logger, debug bool) Logger {
+	return stdLogger{l: l, debug: debug}
+}
+
+type stdLogger struct {
+	l      *log.Logger
+	debug  bool
+	fields []Field
+}
+
+func (s stdLogger) print(level, msg string, fields []Field) {
+	s.l.Printf("%s %s%s", level, msg, format(Redact(join(s.fields, fields))))
+}
+
+func (s stdLogger) Debug(msg string, fields ...Field) {
+	if s.debug {
+		s.print("DEBUG", msg, fields)
+	}
+}
+
+func (s stdLogger) Info(msg string, fields ...Field) {
+	s.print("INFO", msg, fields)
+}
+
+func (s stdLogger) Warn(msg string, fields ...Field) {
+	s.print("WARN", msg, fields)
+}
+
+func (s stdLogger) Error(msg string, fields ...Field) {
+	s.print("ERROR", msg, fields)
+}
+
+func (s stdLogger) With(fields ...Field) Logger {
+	s.fields = join(s.fields, fields)
+	return s
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// logSecrets logs every secret, on its own and in a nested body, through a
+// logger made With one of them.
+func logSecrets(l Logger) {
+	l = l.With(F("Authorization", secrets["Authorization"]))
+	l.Info("request",
+		F("x-api-key", secrets["x-api-key"]),
+		F("body", []byte(`{"Response": {"TokenId": "token-id-1", "SubscriptionKey": "subscription-key-2"}}`)),
+		F("user", map[string]string{"name": "alice"}),
+	)
+	l.Error("login failed", F("Password", secrets["Password"]))
+}
+
+func checkRedacted(t *testing.T, out string) {
+	t.Helper()
+	for k, v := range secrets {
+		if strings.Contains(out, v) {
+			t.Fatalf("%s was logged: %s", k, out)
+		}
+	}
+	if !strings.Contains(out, "request") || !strings.Contains(out, "login failed") {
+		t.Fatalf("lines are missing: %s", out)
+	}
+}
+
+func TestZap(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logSecrets(Zap(zap.New(core).Sugar()))
+
+	var b strings.Builder
+	for _, e := range logs.All() {
+		b.WriteString(e.Message)
+		for k, v := range e.ContextMap() {
+			fmt.Fprintf(&b, " %s=%v", k, v)
+		}
+		b.WriteString("\n")
+	}
+	checkRedacted(t, b.String())
+	if logs.Len() != 2 {
+		t.Fatalf("expected 2 lines, got %d", logs.Len())
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logSecrets(Slog(slog.New(slog.NewJSONHandler(&buf, nil))))
+	checkRedacted(t, buf.String())
+}
+
+func TestStd(t *testing.T) {
+	var buf bytes.Buffer
+	l := Std(log.New(&buf, "", 0), false)
+	logSecrets(l)
+	l.Debug("dropped")
+	checkRedacted(t, buf.String())
+	if strings.Contains(buf.String(), "dropped") {
+		t.Fatalf("debug line was logged without debug: %s", buf.String())
+	}
+
+	buf.Reset()
+	Std(log.New(&buf, "", 0), true).Debug("kept", F("name", "fw1"))
+	if got := buf.String(); got != "DEBUG kept name=fw1\n" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Package logging is the structured logger used throughout the SDK.
+
+Both aws.Client and api.ApiClient take a Logger. Adapters are provided for
+zap, log/slog and the standard library log package, and every adapter runs
+its fields through Redact, so credentials never end up in the logs.
+*/
+package logging
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field is a key/value pair attached to a log line.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a Field.
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger is the interface the SDK logs through.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+
+	// With returns a Logger that adds the given fields to every line.
+	With(fields ...Field) Logger
+}
+
+// Nop returns a Logger that discards everything.
+func Nop() Logger {
+	return nop{}
+}
+
+type nop struct{}
+
+func (nop) Debug(string, ...Field) {}
+func (nop) Info(string, ...Field)  {}
+func (nop) Warn(string, ...Field)  {}
+func (nop) Error(string, ...Field) {}
+
+func (n nop) With(...Field) Logger {
+	return n
+}
+
+// keyvals flattens fields into alternating keys and values.
+func keyvals(fields []Field) []interface{} {
+	ans := make([]interface{}, 0, 2*len(fields))
+	for _, f := range fields {
+		ans = append(ans, f.Key, f.Value)
+	}
+	return ans
+}
+
+// format renders fields as space separated key=value pairs.
+func format(fields []Field) string {
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.Key, f.Value)
+	}
+	return b.String()
+}
+
+func join(a, b []Field) []Field {
+	if len(a) == 0 {
+		return b
+	}
+	ans := make([]Field, 0, len(a)+len(b))
+	ans = append(ans, a...)
+	return append(ans, b...)
+}
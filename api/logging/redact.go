@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Redacted replaces sensitive values.
+const Redacted = "REDACTED"
+
+// sensitive holds the lower cased keys whose values are never logged.
+var sensitive = map[string]bool{
+	"authorization":   true,
+	"x-api-key":       true,
+	"tokenid":         true,
+	"subscriptionkey": true,
+	"password":        true,
+	"b64":             true,
+	"secretaccesskey": true,
+	"secret-key":      true,
+	"sessiontoken":    true,
+	"appclientsecret": true,
+	"externalid":      true,
+	"external-id":     true,
+}
+
+// IsSensitive returns true if values stored under the given key (a header,
+// JSON key or field name) must be redacted.
+func IsSensitive(key string) bool {
+	return sensitive[strings.ToLower(key)]
+}
+
+// RedactHeader returns a copy of h with sensitive headers redacted.
+func RedactHeader(h http.Header) http.Header {
+	ans := h.Clone()
+	for k := range ans {
+		if IsSensitive(k) {
+			ans[k] = []string{Redacted}
+		}
+	}
+	return ans
+}
+
+// RedactJSON returns body with the values of sensitive keys redacted, at
+// any depth. Bodies that are not JSON are returned as is.
+func RedactJSON(body []byte) []byte {
+	var v interface{}
+	if len(body) == 0 || json.Unmarshal(body, &v) != nil {
+		return body
+	}
+	b, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return body
+	}
+	return b
+}
+
+func redactValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, y := range x {
+			if IsSensitive(k) {
+				x[k] = Redacted
+			} else {
+				x[k] = redactValue(y)
+			}
+		}
+	case []interface{}:
+		for i := range x {
+			x[i] = redactValue(x[i])
+		}
+	}
+	return v
+}
+
+// Redact returns the fields with sensitive values redacted. Fields holding
+// headers or raw JSON bodies are redacted in depth.
+func Redact(fields []Field) []Field {
+	ans := make([]Field, len(fields))
+	for i, f := range fields {
+		switch v := f.Value.(type) {
+		case http.Header:
+			f.Value = RedactHeader(v)
+		case json.RawMessage:
+			f.Value = string(RedactJSON(v))
+		case []byte:
+			f.Value = string(RedactJSON(v))
+		default:
+			if IsSensitive(f.Key) {
+				f.Value = Redacted
+			}
+		}
+		ans[i] = f
+	}
+	return ans
+}
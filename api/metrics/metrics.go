@@ -0,0 +1,54 @@
+/*
+Package metrics is the instrumentation interface of the SDK.
+
+aws.Client reports API calls, retries, token refreshes and long running waits
+to a Metrics. Nop discards everything, and Registry keeps the values in memory
+and serves them in the Prometheus text format.
+*/
+package metrics
+
+import "time"
+
+// Metrics receives measurements from the SDK. Implementations must be safe
+// for concurrent use.
+type Metrics interface {
+	// APICall is called once per API call, after any retries. The operation
+	// is the name of the client function, such as "CreateRuleStack", and
+	// status is the HTTP status code, or zero if no response was received.
+	APICall(operation, method string, status int, latency time.Duration)
+
+	// Retry is called each time a failed API call is retried.
+	Retry(operation, method string)
+
+	// TokenRefresh is called each time a JWT is fetched for a permission.
+	TokenRefresh(permission string, err error)
+
+	// Wait is called when polling for a long running operation finishes.
+	Wait(operation string, elapsed time.Duration, err error)
+
+	// RateLimitWait is called each time a request goes through the rate
+	// limiter of its permission, with the time it had to wait (possibly
+	// zero).
+	RateLimitWait(permission string, wait time.Duration)
+}
+
+// Nop returns a Metrics that discards everything.
+func Nop() Metrics {
+	return nop{}
+}
+
+type nop struct{}
+
+func (nop) APICall(string, string, int, time.Duration) {}
+func (nop) Retry(string, string)                       {}
+func (nop) TokenRefresh(string, error)                 {}
+func (nop) Wait(string, time.Duration, error)          {}
+func (nop) RateLimitWait(string, time.Duration)        {}
+
+// result is the label value used for the outcome of an operation.
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
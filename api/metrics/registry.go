@@ -0,0 +1,282 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the histogram upper bounds, in seconds, used for API
+// call latencies.
+var DefaultBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// DefaultRateLimitBuckets are the histogram upper bounds, in seconds, used
+// for the time spent waiting on the rate limiter.
+var DefaultRateLimitBuckets = []float64{0, .01, .05, .1, .25, .5, 1, 2.5, 5}
+
+// DefaultWaitBuckets are the histogram upper bounds, in seconds, used for
+// waits.
+var DefaultWaitBuckets = []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+/*
+Registry is an in-memory Metrics.
+
+It is also an http.Handler serving the Prometheus text exposition format, so
+it can be mounted on an existing mux:
+
+	reg := metrics.NewRegistry("cloudngfw")
+	client.Metrics = reg
+	http.Handle("/metrics", reg)
+*/
+type Registry struct {
+	mu        sync.Mutex
+	namespace string
+
+	calls     *counterVec
+	latency   *histogramVec
+	retries   *counterVec
+	refreshes *counterVec
+	waits     *histogramVec
+	limited   *histogramVec
+}
+
+// NewRegistry returns an empty Registry whose metric names start with the
+// given namespace. If namespace is empty, "cloudngfw" is used.
+func NewRegistry(namespace string) *Registry {
+	if namespace == "" {
+		namespace = "cloudngfw"
+	}
+
+	return &Registry{
+		namespace: namespace,
+		calls: newCounterVec(
+			"api_calls_total", "API calls by operation, method and status.",
+			"operation", "method", "status",
+		),
+		latency: newHistogramVec(
+			"api_call_duration_seconds", "Latency of API calls, retries included.",
+			DefaultBuckets, "operation", "method",
+		),
+		retries: newCounterVec(
+			"api_retries_total", "Retried API calls.",
+			"operation", "method",
+		),
+		refreshes: newCounterVec(
+			"token_refreshes_total", "JWT refreshes by permission.",
+			"permission", "result",
+		),
+		waits: newHistogramVec(
+			"wait_duration_seconds", "Time spent waiting for long running operations.",
+			DefaultWaitBuckets, "operation", "result",
+		),
+		limited: newHistogramVec(
+			"rate_limit_wait_seconds", "Time spent waiting on the client side rate limiter.",
+			DefaultRateLimitBuckets, "permission",
+		),
+	}
+}
+
+// APICall implements Metrics.
+func (r *Registry) APICall(operation, method string, status int, latency time.Duration) {
+	code := "error"
+	if status != 0 {
+		code = strconv.Itoa(status)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls.add(1, operation, method, code)
+	r.latency.observe(latency.Seconds(), operation, method)
+}
+
+// Retry implements Metrics.
+func (r *Registry) Retry(operation, method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries.add(1, operation, method)
+}
+
+// TokenRefresh implements Metrics.
+func (r *Registry) TokenRefresh(permission string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshes.add(1, permission, result(err))
+}
+
+// Wait implements Metrics.
+func (r *Registry) Wait(operation string, elapsed time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waits.observe(elapsed.Seconds(), operation, result(err))
+}
+
+// RateLimitWait implements Metrics.
+func (r *Registry) RateLimitWait(permission string, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limited.observe(wait.Seconds(), permission)
+}
+
+// ServeHTTP writes the current values in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	r.mu.Lock()
+	r.calls.write(bw, r.namespace)
+	r.latency.write(bw, r.namespace)
+	r.retries.write(bw, r.namespace)
+	r.refreshes.write(bw, r.namespace)
+	r.waits.write(bw, r.namespace)
+	r.limited.write(bw, r.namespace)
+	r.mu.Unlock()
+	bw.Flush()
+}
+
+// Counter returns the current value of the counter with the given name
+// (without the namespace) and label values, mostly for use in tests.
+func (r *Registry) Counter(name string, labels ...string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range []*counterVec{r.calls, r.retries, r.refreshes} {
+		if v.name == name {
+			return v.values[key(labels)]
+		}
+	}
+	return 0
+}
+
+// key joins label values into a map key.
+func key(labels []string) string {
+	return strings.Join(labels, "\xff")
+}
+
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+}
+
+func (v *counterVec) add(n float64, labels ...string) {
+	v.values[key(labels)] += n
+}
+
+func (v *counterVec) write(w *bufio.Writer, namespace string) {
+	name := namespace + "_" + v.name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, v.help, name)
+	for _, k := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", name, labelString(v.labels, k, ""), formatFloat(v.values[k]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	buckets []float64
+	labels  []string
+	values  map[string]*histogram
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		labels:  labels,
+		values:  make(map[string]*histogram),
+	}
+}
+
+func (v *histogramVec) observe(x float64, labels ...string) {
+	k := key(labels)
+	h := v.values[k]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(v.buckets))}
+		v.values[k] = h
+	}
+
+	for i, le := range v.buckets {
+		if x <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += x
+}
+
+func (v *histogramVec) write(w *bufio.Writer, namespace string) {
+	name := namespace + "_" + v.name
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, v.help, name)
+
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		h := v.values[k]
+		for i, le := range v.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, labelString(v.labels, k, formatFloat(le)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, labelString(v.labels, k, "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, labelString(v.labels, k, ""), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labelString(v.labels, k, ""), h.count)
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	ans := make([]string, 0, len(m))
+	for k := range m {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// labelString renders the label set stored under k, adding the "le" label
+// if it is not empty.
+func labelString(names []string, k, le string) string {
+	var values []string
+	if len(names) > 0 {
+		values = strings.Split(k, "\xff")
+	}
+
+	parts := make([]string, 0, len(names)+1)
+	for i, n := range names {
+		parts = append(parts, fmt.Sprintf("%s=%s", n, strconv.Quote(values[i])))
+	}
+	if le != "" {
+		parts = append(parts, fmt.Sprintf("le=%q", le))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry("ns")
+	r.APICall("ListRuleStack", http.MethodGet, http.StatusOK, 250*time.Millisecond)
+	r.APICall("ListRuleStack", http.MethodGet, 0, 2*time.Second)
+	r.Retry("ListRuleStack", http.MethodGet)
+	r.TokenRefresh("rulestack", nil)
+	r.TokenRefresh("rulestack", nil)
+	r.TokenRefresh("rulestack", errors.New("denied"))
+	r.RateLimitWait("rulestack", 0)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("wrong content type %q", ct)
+	}
+
+	want := strings.Join([]string{
+		`# HELP ns_api_calls_total API calls by operation, method and status.`,
+		`# TYPE ns_api_calls_total counter`,
+		`ns_api_calls_total{operation="ListRuleStack",method="GET",status="200"} 1`,
+		`ns_api_calls_total{operation="ListRuleStack",method="GET",status="error"} 1`,
+		`# HELP ns_api_call_duration_seconds Latency of API calls, retries included.`,
+		`# TYPE ns_api_call_duration_seconds histogram`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="0.05"} 0`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="0.1"} 0`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="0.25"} 1`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="0.5"} 1`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="1"} 1`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="2.5"} 2`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="5"} 2`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="10"} 2`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="30"} 2`,
+		`ns_api_call_duration_seconds_bucket{operation="ListRuleStack",method="GET",le="+Inf"} 2`,
+		`ns_api_call_duration_seconds_sum{operation="ListRuleStack",method="GET"} 2.25`,
+		`ns_api_call_duration_seconds_count{operation="ListRuleStack",method="GET"} 2`,
+		`# HELP ns_api_retries_total Retried API calls.`,
+		`# TYPE ns_api_retries_total counter`,
+		`ns_api_retries_total{operation="ListRuleStack",method="GET"} 1`,
+		`# HELP ns_token_refreshes_total JWT refreshes by permission.`,
+		`# TYPE ns_token_refreshes_total counter`,
+		`ns_token_refreshes_total{permission="rulestack",result="error"} 1`,
+		`ns_token_refreshes_total{permission="rulestack",result="success"} 2`,
+		`# HELP ns_wait_duration_seconds Time spent waiting for long running operations.`,
+		`# TYPE ns_wait_duration_seconds histogram`,
+		`# HELP ns_rate_limit_wait_seconds Time spent waiting on the client side rate limiter.`,
+		`# TYPE ns_rate_limit_wait_seconds histogram`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="0"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="0.01"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="0.05"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="0.1"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="0.25"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="0.5"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="1"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="2.5"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="5"} 1`,
+		`ns_rate_limit_wait_seconds_bucket{permission="rulestack",le="+Inf"} 1`,
+		`ns_rate_limit_wait_seconds_sum{permission="rulestack"} 0`,
+		`ns_rate_limit_wait_seconds_count{permission="rulestack"} 1`,
+		``,
+	}, "\n")
+	if got := w.Body.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if n := r.Counter("api_calls_total", "ListRuleStack", http.MethodGet, "200"); n != 1 {
+		t.Fatalf("Counter returned %v", n)
+	}
+	if n := r.Counter("unknown_total"); n != 0 {
+		t.Fatalf("Counter of an unknown metric returned %v", n)
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	v := newHistogramVec("h", "A histogram.", []float64{1, 2})
+	for _, x := range []float64{0.5, 1, 1.5, 3} {
+		v.observe(x)
+	}
+
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	v.write(w, "ns")
+	w.Flush()
+
+	want := `# HELP ns_h A histogram.
+# TYPE ns_h histogram
+ns_h_bucket{le="1"} 2
+ns_h_bucket{le="2"} 3
+ns_h_bucket{le="+Inf"} 4
+ns_h_sum 6
+ns_h_count 4
+`
+	if got := b.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNewRegistryDefaultNamespace(t *testing.T) {
+	r := NewRegistry("")
+	r.Retry("ReadFirewall", http.MethodGet)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), "\ncloudngfw_api_retries_total{operation=\"ReadFirewall\",method=\"GET\"} 1\n") {
+		t.Fatalf("the default namespace was not used:\n%s", w.Body.String())
+	}
+}
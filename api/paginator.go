@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoMorePages is returned by NextPage once the last page has been read.
+var ErrNoMorePages = errors.New("no more pages")
+
+// PageFunc fetches the page for the given NextToken, returning the page and
+// the NextToken of the page after it (empty for the last page).
+type PageFunc[T any] func(ctx context.Context, nextToken string) (T, string, error)
+
+// PaginatorOption configures a Paginator.
+type PaginatorOption func(*paginatorOptions)
+
+type paginatorOptions struct {
+	prefetch bool
+}
+
+// WithPrefetch makes the paginator fetch the next page in the background
+// while the caller is working on the current one.
+func WithPrefetch() PaginatorOption {
+	return func(o *paginatorOptions) {
+		o.prefetch = true
+	}
+}
+
+type pageResult[T any] struct {
+	page  T
+	token string
+	err   error
+}
+
+/*
+Paginator walks the pages of a NextToken based List API.
+
+	p := client.NewFirewallPaginator(firewall.ListInput{MaxResults: 50})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		...
+	}
+
+A Paginator is not safe for concurrent use. If WithPrefetch is given and the
+caller stops before the last page, Close should be called to abandon the page
+being fetched in the background.
+*/
+type Paginator[T any] struct {
+	fetch   PageFunc[T]
+	opts    paginatorOptions
+	token   string
+	started bool
+	done    bool
+
+	pending chan pageResult[T]
+	cancel  context.CancelFunc
+}
+
+// NewPaginator returns a paginator that starts at the given NextToken.
+func NewPaginator[T any](nextToken string, fetch PageFunc[T], opts ...PaginatorOption) *Paginator[T] {
+	p := &Paginator[T]{
+		fetch: fetch,
+		token: nextToken,
+	}
+	for _, o := range opts {
+		o(&p.opts)
+	}
+	return p
+}
+
+// HasMorePages returns true if there are more pages to be read.
+func (p *Paginator[T]) HasMorePages() bool {
+	return !p.done
+}
+
+// NextPage returns the next page.
+func (p *Paginator[T]) NextPage(ctx context.Context) (T, error) {
+	var res pageResult[T]
+	if p.done {
+		return res.page, ErrNoMorePages
+	}
+	if err := ctx.Err(); err != nil {
+		p.Close()
+		return res.page, err
+	}
+
+	if p.pending != nil {
+		select {
+		case res = <-p.pending:
+		case <-ctx.Done():
+			p.Close()
+			return res.page, ctx.Err()
+		}
+		p.pending = nil
+		p.cancel()
+		p.cancel = nil
+	} else {
+		res.page, res.token, res.err = p.fetch(ctx, p.token)
+	}
+
+	if res.err != nil {
+		p.done = true
+		return res.page, res.err
+	}
+
+	// Stop on a repeated token as well, so a misbehaving endpoint can't
+	// keep the caller looping forever.
+	if res.token == "" || (p.started && res.token == p.token) {
+		p.done = true
+	}
+	p.started = true
+	p.token = res.token
+
+	if p.opts.prefetch && !p.done {
+		// The prefetch outlives this call, so it must not end with ctx,
+		// which the caller may cancel as soon as the page is returned.
+		// Close cancels it instead.
+		pctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		ch := make(chan pageResult[T], 1)
+		p.pending = ch
+		p.cancel = cancel
+		go func(token string) {
+			var r pageResult[T]
+			r.page, r.token, r.err = p.fetch(pctx, token)
+			ch <- r
+		}(p.token)
+	}
+
+	return res.page, nil
+}
+
+// Pages calls fn for every remaining page, stopping early if fn returns false.
+func (p *Paginator[T]) Pages(ctx context.Context, fn func(T) bool) error {
+	defer p.Close()
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if !fn(page) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// All returns every remaining page.
+func (p *Paginator[T]) All(ctx context.Context) ([]T, error) {
+	var ans []T
+	err := p.Pages(ctx, func(page T) bool {
+		ans = append(ans, page)
+		return true
+	})
+	return ans, err
+}
+
+// Close abandons any page being prefetched and stops the paginator.
+func (p *Paginator[T]) Close() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.pending = nil
+	p.done = true
+}
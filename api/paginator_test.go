@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// pages returns a PageFunc serving n pages, each holding its own index,
+// and counting the fetches made.
+func pages(n int, fetches *int) PageFunc[int] {
+	return func(ctx context.Context, token string) (int, string, error) {
+		*fetches++
+		if err := ctx.Err(); err != nil {
+			return 0, "", err
+		}
+		i := 0
+		if token != "" {
+			i, _ = strconv.Atoi(token)
+		}
+		next := ""
+		if i+1 < n {
+			next = strconv.Itoa(i + 1)
+		}
+		return i, next, nil
+	}
+}
+
+func TestPaginator(t *testing.T) {
+	var fetches int
+	p := NewPaginator("", pages(3, &fetches))
+	got, err := p.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != 0 || got[2] != 2 || fetches != 3 {
+		t.Fatalf("got pages %v in %d fetches", got, fetches)
+	}
+	if p.HasMorePages() {
+		t.Fatalf("paginator has more pages after the last one")
+	}
+	if _, err = p.NextPage(context.Background()); !errors.Is(err, ErrNoMorePages) {
+		t.Fatalf("expected ErrNoMorePages, got %v", err)
+	}
+}
+
+func TestPaginatorPrefetchPerPageContext(t *testing.T) {
+	var got []int
+	p := NewPaginator("", pages(3, new(int)), WithPrefetch())
+	for p.HasMorePages() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		page, err := p.NextPage(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("page %d: %s", len(got), err)
+		}
+		got = append(got, page)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got pages %v", got)
+	}
+}
+
+func TestPaginatorRepeatedToken(t *testing.T) {
+	var fetches int
+	p := NewPaginator("", func(ctx context.Context, token string) (int, string, error) {
+		fetches++
+		return fetches, "same", nil
+	})
+	got, err := p.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || fetches != 2 {
+		t.Fatalf("got pages %v in %d fetches, expected to stop on the repeated token", got, fetches)
+	}
+}
+
+func TestPaginatorStopsEarly(t *testing.T) {
+	var fetches int
+	p := NewPaginator("", pages(5, &fetches))
+	err := p.Pages(context.Background(), func(page int) bool {
+		return page < 1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 2 {
+		t.Fatalf("expected 2 fetches, got %d", fetches)
+	}
+	if p.HasMorePages() {
+		t.Fatalf("paginator was not closed after stopping early")
+	}
+}
+
+func TestPaginatorClose(t *testing.T) {
+	started := make(chan struct{})
+	abandoned := make(chan error, 1)
+	p := NewPaginator("", func(ctx context.Context, token string) (int, string, error) {
+		if token == "" {
+			return 0, "1", nil
+		}
+		close(started)
+		<-ctx.Done()
+		abandoned <- ctx.Err()
+		return 0, "", ctx.Err()
+	}, WithPrefetch())
+
+	if _, err := p.NextPage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	p.Close()
+
+	select {
+	case err := <-abandoned:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("prefetch ended with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close did not cancel the prefetch")
+	}
+	if p.HasMorePages() {
+		t.Fatalf("paginator has more pages after Close")
+	}
+	if _, err := p.NextPage(context.Background()); !errors.Is(err, ErrNoMorePages) {
+		t.Fatalf("expected ErrNoMorePages, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/account"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/appid"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/certificate"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/country"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/feed"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/fqdn"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/predefinedurl"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/prefix"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/security"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/url"
+)
+
+/* Paginators for the NextToken based List APIs.  Each one starts at the
+NextToken of the given input.
+*/
+
+// NewAccountPaginator walks the accounts.
+func (c *ApiClient) NewAccountPaginator(input account.ListInput, opts ...PaginatorOption) *Paginator[account.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (account.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListAccounts(ctx, input)
+		if err != nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewAppIDPaginator walks the app-id versions.
+func (c *ApiClient) NewAppIDPaginator(input appid.ListInput, opts ...PaginatorOption) *Paginator[appid.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (appid.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListAppID(ctx, input)
+		if err != nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewCertificatePaginator walks the certificates of a rulestack.
+func (c *ApiClient) NewCertificatePaginator(input certificate.ListInput, opts ...PaginatorOption) *Paginator[certificate.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (certificate.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListCertificate(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewCountryPaginator walks the countries.
+func (c *ApiClient) NewCountryPaginator(input country.ListInput, opts ...PaginatorOption) *Paginator[country.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (country.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListCountry(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewFeedPaginator walks the intelligent feeds of a rulestack.
+func (c *ApiClient) NewFeedPaginator(input feed.ListInput, opts ...PaginatorOption) *Paginator[feed.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (feed.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListFeed(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewFirewallPaginator walks the firewalls.
+func (c *ApiClient) NewFirewallPaginator(input firewall.ListInput, opts ...PaginatorOption) *Paginator[firewall.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (firewall.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListFirewall(ctx, input)
+		if err != nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewFqdnPaginator walks the FQDN lists of a rulestack.
+func (c *ApiClient) NewFqdnPaginator(input fqdn.ListInput, opts ...PaginatorOption) *Paginator[fqdn.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (fqdn.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListFqdn(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewUrlPredefinedCategoryPaginator walks the predefined URL categories.
+func (c *ApiClient) NewUrlPredefinedCategoryPaginator(input predefinedurl.ListInput, opts ...PaginatorOption) *Paginator[predefinedurl.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (predefinedurl.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListUrlPredefinedCategories(ctx, input)
+		if err != nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewUrlCategoryActionOverridePaginator walks the URL category action overrides of a rulestack.
+func (c *ApiClient) NewUrlCategoryActionOverridePaginator(input predefinedurl.ListOverridesInput, opts ...PaginatorOption) *Paginator[predefinedurl.ListOverridesOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (predefinedurl.ListOverridesOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListUrlCategoriesActionOverride(ctx, input)
+		if err != nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewPrefixListPaginator walks the prefix lists of a rulestack.
+func (c *ApiClient) NewPrefixListPaginator(input prefix.ListInput, opts ...PaginatorOption) *Paginator[prefix.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (prefix.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListPrefixList(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewSecurityRulePaginator walks the security rules of a rule list.
+func (c *ApiClient) NewSecurityRulePaginator(input security.ListInput, opts ...PaginatorOption) *Paginator[security.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (security.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListSecurityRule(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewRuleStackPaginator walks the rulestacks.
+func (c *ApiClient) NewRuleStackPaginator(input stack.ListInput, opts ...PaginatorOption) *Paginator[stack.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (stack.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListRuleStack(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewRuleStackTagPaginator walks the tags of a rulestack.
+func (c *ApiClient) NewRuleStackTagPaginator(input stack.ListTagsInput, opts ...PaginatorOption) *Paginator[stack.ListTagsOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (stack.ListTagsOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListTagsRuleStack(ctx, input)
+		if err != nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
+
+// NewUrlCustomCategoryPaginator walks the custom URL categories of a rulestack.
+func (c *ApiClient) NewUrlCustomCategoryPaginator(input url.ListInput, opts ...PaginatorOption) *Paginator[url.ListOutput] {
+	return NewPaginator(input.NextToken, func(ctx context.Context, token string) (url.ListOutput, string, error) {
+		input.NextToken = token
+		out, err := c.client.ListUrlCustomCategory(ctx, input)
+		if err != nil || out.Response == nil {
+			return out, "", err
+		}
+		return out, out.Response.NextToken, nil
+	}, opts...)
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/prefix"
 )
 
@@ -25,9 +26,9 @@ func (c *ApiClient) ReadPrefixList(ctx context.Context, a prefix.ReadInput) (pre
 }
 
 func (c *ApiClient) CreatePrefixList(ctx context.Context, f prefix.Info) error {
-	Logger.Infof("prefix:%+v", f)
+	c.log().Info("create prefix list", logging.F("prefix_list", f.Name), logging.F("rulestack", f.Rulestack))
 	if err := c.client.CreatePrefixList(ctx, f); err != nil {
-		Logger.Errorf("err:%+v", err)
+		c.log().Error("create prefix list failed", logging.F("prefix_list", f.Name), logging.F("error", err))
 		return err
 	}
 	return nil
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+// ErrUnknownProvider is returned by New for providers that were not
+// registered.
+var ErrUnknownProvider = errors.New("unknown cloud provider")
+
+// ProviderConfig is what a Provider builds its client from.
+type ProviderConfig struct {
+	// Settings are the provider specific settings given with WithSettings,
+	// or nil. See the provider's package for what it accepts.
+	Settings interface{}
+
+	// Logger is the logger of the ApiClient, for the client to use as well.
+	Logger logging.Logger
+}
+
+// Provider builds a Client that is set up and ready to use.
+type Provider func(ctx context.Context, cfg ProviderConfig) (Client, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+/*
+Register makes a provider available to New by name, such as
+CloudProviderAWS.
+
+Providers register themselves when their package is imported:
+
+	import _ "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws"
+
+Register panics if the provider is nil or the name already taken.
+*/
+func Register(name string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if p == nil {
+		panic("api: Register provider is nil")
+	}
+	if _, dup := providers[name]; dup {
+		panic("api: Register called twice for provider " + name)
+	}
+	providers[name] = p
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	ans := make([]string, 0, len(providers))
+	for name := range providers {
+		ans = append(ans, name)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// Option configures the ApiClient built by New.
+type Option func(*options)
+
+type options struct {
+	settings  interface{}
+	client    Client
+	maxGortns int
+	xslPath   string
+	mock      bool
+	logger    logging.Logger
+}
+
+// WithSettings passes provider specific settings to the provider.
+func WithSettings(settings interface{}) Option {
+	return func(o *options) {
+		o.settings = settings
+	}
+}
+
+// WithClient uses a client that is already set up instead of having the
+// provider build one. The client is then the caller's to close.
+func WithClient(c Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithMaxGoroutines bounds the goroutines used by calls fanning out. The
+// default is 1.
+func WithMaxGoroutines(n int) Option {
+	return func(o *options) {
+		o.maxGortns = n
+	}
+}
+
+// WithXSLPath sets the path of the XSL files used to transform rulestack
+// XML.
+func WithXSLPath(path string) Option {
+	return func(o *options) {
+		o.xslPath = path
+	}
+}
+
+// WithMock makes the calls that support it return without calling the
+// API, for tests.
+func WithMock() Option {
+	return func(o *options) {
+		o.mock = true
+	}
+}
+
+// WithLogger sets the logger of the ApiClient and of the client the
+// provider builds.
+func WithLogger(l logging.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+/*
+New returns an ApiClient for a registered provider, such as
+CloudProviderAWS, with its client built and set up by the provider.
+
+The logger is the one given with WithLogger, else the global Logger set with
+SetLogger. One of them is required unless WithMock is given.
+
+Close the ApiClient once done with, to stop the client's background work.
+*/
+func New(ctx context.Context, provider string, opts ...Option) (*ApiClient, error) {
+	o := options{maxGortns: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.maxGortns < 1 {
+		return nil, fmt.Errorf("max goroutines must be at least 1, not %d", o.maxGortns)
+	}
+	if o.client != nil && o.settings != nil {
+		return nil, fmt.Errorf("settings can't be given along with a client")
+	}
+	if o.logger == nil && Logger != nil {
+		o.logger = logging.Zap(Logger)
+	}
+	if o.logger == nil {
+		if !o.mock {
+			return nil, fmt.Errorf("no logger: use WithLogger or SetLogger")
+		}
+		o.logger = logging.Nop()
+	}
+
+	owned := false
+	client := o.client
+	if client == nil {
+		providersMu.RLock()
+		p := providers[provider]
+		providersMu.RUnlock()
+		if p == nil {
+			return nil, fmt.Errorf("%w %q, registered providers: %s", ErrUnknownProvider, provider, strings.Join(Providers(), ", "))
+		}
+
+		var err error
+		if client, err = p(ctx, ProviderConfig{Settings: o.settings, Logger: o.logger}); err != nil {
+			return nil, fmt.Errorf("%s: %w", provider, err)
+		}
+		owned = true
+	}
+
+	if got := client.GetCloudProvider(ctx); got != provider {
+		if c, ok := client.(io.Closer); owned && ok {
+			c.Close()
+		}
+		return nil, fmt.Errorf("the client is for provider %s, not %s", got, provider)
+	}
+
+	return &ApiClient{
+		client:    client,
+		ctx:       ctx,
+		maxGortns: o.maxGortns,
+		XSLPath:   o.xslPath,
+		Mock:      o.mock,
+		logger:    o.logger,
+		owned:     owned,
+	}, nil
+}
+
+// Close closes the client built by New. Clients given with WithClient are
+// left to the caller.
+func (c *ApiClient) Close() error {
+	if !c.owned {
+		return nil
+	}
+	if cl, ok := c.client.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors that an APIError can be matched against with errors.Is.
+var (
+	ErrNotFound     = errors.New("resource not found")
+	ErrConflict     = errors.New("resource conflict")
+	ErrThrottled    = errors.New("request throttled")
+	ErrUnauthorized = errors.New("request unauthorized")
+	ErrValidation   = errors.New("request failed validation")
+)
+
+/*
+APIError is returned for any API call that was answered with an error, either
+as a HTTP status code of 400 or higher or as a non-zero ResponseStatus in the
+body of an otherwise successful response.
+
+It implements Failure, so code checking for a *Status through Failed() keeps
+working, and it can be matched against the sentinel errors in this package
+with errors.Is.
+*/
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// ErrorCode is the service error code from the ResponseStatus, or -1 if
+	// the body only carried a gateway message.
+	ErrorCode int
+
+	// Reason is the error message returned by the service.
+	Reason string
+
+	// RequestID is the x-amzn-RequestId of the response, if any.
+	RequestID string
+
+	// Method and Path identify the operation that failed.
+	Method string
+	Path   string
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	if e.Method != "" || e.Path != "" {
+		fmt.Fprintf(&b, "%s %s: ", e.Method, e.Path)
+	}
+	fmt.Fprintf(&b, "Error(%d): %s", e.ErrorCode, e.Reason)
+	if e.StatusCode >= http.StatusBadRequest {
+		fmt.Fprintf(&b, " (http %d", e.StatusCode)
+		if e.RequestID != "" {
+			fmt.Fprintf(&b, ", request id %s", e.RequestID)
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Failed returns the service status of the error.
+func (e *APIError) Failed() *Status {
+	return &Status{
+		Code:   e.ErrorCode,
+		Reason: e.Reason,
+	}
+}
+
+// Is reports whether the error falls into the class of the given sentinel.
+func (e *APIError) Is(target error) bool {
+	return classify(e.StatusCode, e.Reason) == target
+}
+
+// Is lets a bare *Status be matched against the sentinel errors too.
+func (s Status) Is(target error) bool {
+	return classify(0, s.Reason) == target
+}
+
+func classify(code int, reason string) error {
+	switch code {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		// The service returns 400 for missing objects and token conflicts
+		// as well, so look at the reason before settling on validation.
+		if err := classifyReason(reason); err != nil {
+			return err
+		}
+		return ErrValidation
+	}
+
+	return classifyReason(reason)
+}
+
+func classifyReason(reason string) error {
+	s := Status{Reason: reason}
+	lower := strings.ToLower(reason)
+	switch {
+	case s.TokenConflict(), strings.Contains(lower, "already exists"):
+		return ErrConflict
+	case s.ObjectNotFound(), s.LrsAssociateDoesNotExist(), s.LrsResourceEntryMissing():
+		return ErrNotFound
+	case strings.Contains(lower, "too many requests"), strings.Contains(lower, "rate exceeded"):
+		return ErrThrottled
+	case strings.Contains(lower, "unauthorized"), strings.Contains(lower, "not authorized"):
+		return ErrUnauthorized
+	case s.InvalidRequest():
+		return ErrValidation
+	}
+
+	return nil
+}
+
+/*
+NewAPIError builds an APIError from a response body.
+
+The body may carry either a ResponseStatus or a bare gateway message. If
+neither is present, the HTTP status text is used as the reason.
+*/
+func NewAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+		e.RequestID = resp.Header.Get("x-amzn-RequestId")
+		if resp.Request != nil {
+			e.Method = resp.Request.Method
+			e.Path = resp.Request.URL.Path
+		}
+	}
+
+	var ans struct {
+		Status  Status `json:"ResponseStatus"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &ans); err == nil {
+		switch {
+		case ans.Status.Code != 0 || ans.Status.Reason != "":
+			e.ErrorCode = ans.Status.Code
+			e.Reason = ans.Status.Reason
+		case ans.Message != "":
+			e.ErrorCode = -1
+			e.Reason = fmt.Sprintf("error: %s", ans.Message)
+		}
+	}
+	if e.Reason == "" {
+		e.ErrorCode = -1
+		e.Reason = http.StatusText(e.StatusCode)
+		if e.Reason == "" {
+			e.Reason = strings.TrimSpace(string(body))
+		}
+	}
+
+	return e
+}
+
+// FromStatus wraps a failed body status in an APIError.
+func FromStatus(resp *http.Response, s *Status) *APIError {
+	e := NewAPIError(resp, nil)
+	e.ErrorCode = s.Code
+	e.Reason = s.Reason
+	return e
+}
@@ -3,9 +3,9 @@ package api
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
 )
 
@@ -31,9 +31,7 @@ func (c *ApiClient) ReadRuleStack(ctx context.Context, input stack.ReadInput) (s
 		return stack.ReadOutput{}, err
 	}
 
-	log.Printf(
-		"read rulestack %s",
-		input.Name)
+	c.log().Info("read rulestack", logging.F("rulestack", input.Name))
 
 	return out, nil
 }
@@ -44,9 +42,7 @@ func (c *ApiClient) ValidateRuleStack(ctx context.Context, input stack.SimpleInp
 		return err
 	}
 
-	log.Printf(
-		"validate rulestack %s",
-		input.Name)
+	c.log().Info("validate rulestack", logging.F("rulestack", input.Name))
 
 	return nil
 }
@@ -57,28 +53,22 @@ func (c *ApiClient) ExportRuleStackXML(ctx context.Context, input stack.ReadInpu
 		return stack.ExportRulestackXmlOutput{}, err
 	}
 
-	log.Printf(
-		"export rulestack:%s",
-		input.Name)
+	c.log().Info("export rulestack", logging.F("rulestack", input.Name))
 
 	return out, nil
 }
 
 func (c *ApiClient) SaveRuleStackXML(ctx context.Context, input stack.SaveRulestackXmlInput) error {
 	if c.Mock {
-		log.Printf(
-			"mocking save rulestack xml:%s",
-			input.Name)
+		c.log().Info("mocking save rulestack xml", logging.F("rulestack", input.Name))
 		for _, fw := range input.Firewalls {
-			log.Printf(
-				"writing to:%s.txt", fw.FirewallId)
+			c.log().Debug("writing rulestack xml", logging.F("file", fw.FirewallId+".txt"))
 			os.WriteFile(fmt.Sprintf("/tmp/%s.txt", fw.FirewallId),
 				[]byte(input.RuleStackEntryXml.Xml), 0644)
 		}
 
 		dgFileName := fmt.Sprintf("/tmp/%s_%s.txt", input.Name, c.GetRegion(ctx))
-		log.Printf(
-			"writing to:%s", dgFileName)
+		c.log().Debug("writing rulestack xml", logging.F("file", dgFileName))
 		os.WriteFile(dgFileName, []byte(input.RuleStackEntryXml.Xml), 0644)
 		return nil
 	}
@@ -86,17 +76,13 @@ func (c *ApiClient) SaveRuleStackXML(ctx context.Context, input stack.SaveRulest
 		return err
 	}
 
-	log.Printf(
-		"export rulestack:%s",
-		input.Name)
+	c.log().Info("save rulestack xml", logging.F("rulestack", input.Name))
 
 	return nil
 }
 
 func (c *ApiClient) CreateRuleStack(ctx context.Context, input stack.Info) error {
-	log.Printf(
-		"create rulestack %s",
-		input.Name)
+	c.log().Info("create rulestack", logging.F("rulestack", input.Name))
 	if err := c.client.CreateRuleStack(ctx, input); err != nil {
 		return err
 	}
@@ -104,9 +90,7 @@ func (c *ApiClient) CreateRuleStack(ctx context.Context, input stack.Info) error
 }
 
 func (c *ApiClient) UpdateRuleStack(ctx context.Context, input stack.Info) error {
-	log.Printf(
-		"create rulestack %s",
-		input.Name)
+	c.log().Info("update rulestack", logging.F("rulestack", input.Name))
 	if err := c.client.UpdateRuleStack(ctx, input); err != nil {
 		return err
 	}
@@ -114,10 +98,7 @@ func (c *ApiClient) UpdateRuleStack(ctx context.Context, input stack.Info) error
 }
 
 func (c *ApiClient) DeleteRuleStack(ctx context.Context, input stack.SimpleInput) error {
-	log.Printf(
-		"commit rulestack %s %s",
-		input.Name,
-		input.Scope)
+	c.log().Info("delete rulestack", logging.F("rulestack", input.Name), logging.F("scope", input.Scope))
 	if err := c.client.DeleteRuleStack(ctx, input); err != nil {
 		return err
 	}
@@ -125,10 +106,7 @@ func (c *ApiClient) DeleteRuleStack(ctx context.Context, input stack.SimpleInput
 }
 
 func (c *ApiClient) CommitRuleStack(ctx context.Context, input stack.SimpleInput) error {
-	log.Printf(
-		"commit rulestack %s %s",
-		input.Name,
-		input.Scope)
+	c.log().Info("commit rulestack", logging.F("rulestack", input.Name), logging.F("scope", input.Scope))
 	if err := c.client.CommitRuleStack(ctx, input); err != nil {
 		return err
 	}
@@ -136,10 +114,7 @@ func (c *ApiClient) CommitRuleStack(ctx context.Context, input stack.SimpleInput
 }
 
 func (c *ApiClient) CommitStatusRuleStack(ctx context.Context, input stack.SimpleInput) (stack.CommitStatus, error) {
-	Logger.Debugf(
-		"commit status rulestack %s %s",
-		input.Name,
-		input.Scope)
+	c.log().Debug("commit status rulestack", logging.F("rulestack", input.Name), logging.F("scope", input.Scope))
 	status, err := c.client.CommitStatusRuleStack(ctx, input)
 	if err != nil {
 		return stack.CommitStatus{}, err
@@ -151,10 +126,7 @@ func (c *ApiClient) PollCommitRulestack(ctx context.Context, input stack.SimpleI
 	if c.Mock {
 		return stack.CommitStatus{}, nil
 	}
-	Logger.Debugf(
-		"commit rulestack %s %s",
-		input.Name,
-		input.Scope)
+	c.log().Debug("poll rulestack commit", logging.F("rulestack", input.Name), logging.F("scope", input.Scope))
 	status, err := c.client.PollCommitRuleStack(ctx, input)
 	if err != nil {
 		return stack.CommitStatus{}, err
@@ -9,6 +9,8 @@ import (
 
 // Create creates an object.
 func (c *Client) CreateAccount(ctx context.Context, input account.CreateInput) (account.CreateOutput, error) {
+	ctx = withOperation(ctx, "CreateAccount")
+
 	c.Log(http.MethodPost, "create account")
 	path := Path{
 		V1Path: []string{"v1", "mgmt", "linkaccounts"},
@@ -29,6 +31,8 @@ func (c *Client) CreateAccount(ctx context.Context, input account.CreateInput) (
 
 // Read returns information on the given object.
 func (c *Client) ReadAccount(ctx context.Context, input account.ReadInput) (account.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadAccount")
+
 	accountId := input.AccountId
 	c.Log(http.MethodGet, "describe account: %s", accountId)
 	path := Path{
@@ -50,6 +54,8 @@ func (c *Client) ReadAccount(ctx context.Context, input account.ReadInput) (acco
 
 // List returns a list of given objects.
 func (c *Client) ListAccounts(ctx context.Context, input account.ListInput) (account.ListOutput, error) {
+	ctx = withOperation(ctx, "ListAccounts")
+
 	c.Log(http.MethodGet, "list accounts")
 	path := Path{
 		V1Path: []string{"v1", "mgmt", "linkaccounts"},
@@ -70,6 +76,8 @@ func (c *Client) ListAccounts(ctx context.Context, input account.ListInput) (acc
 
 // Delete the given account.
 func (c *Client) DeleteAccount(ctx context.Context, input account.DeleteInput) error {
+	ctx = withOperation(ctx, "DeleteAccount")
+
 	c.Log(http.MethodDelete, "delete account: %s", input.AccountId)
 	path := Path{
 		V1Path: []string{"v1", "mgmt", "linkaccounts", input.AccountId},
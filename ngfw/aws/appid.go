@@ -9,6 +9,8 @@ import (
 
 // List returns a list of objects.
 func (c *Client) ListAppID(ctx context.Context, input appid.ListInput) (appid.ListOutput, error) {
+	ctx = withOperation(ctx, "ListAppID")
+
 	c.Log(http.MethodGet, "list app-id versions")
 	path := Path{
 		V1Path: []string{"v1", "config", "appidversions"},
@@ -29,6 +31,8 @@ func (c *Client) ListAppID(ctx context.Context, input appid.ListInput) (appid.Li
 
 // ReadAppId returns information on the given app-id version.
 func (c *Client) ReadAppID(ctx context.Context, input appid.ReadInput) (appid.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadAppID")
+
 	c.Log(http.MethodGet, "describe app-id version: %s", input.Version)
 	path := Path{
 		V1Path: []string{"v1", "config", "appidversions"},
@@ -50,6 +54,8 @@ func (c *Client) ReadAppID(ctx context.Context, input appid.ReadInput) (appid.Re
 // ReadApplication returns information on the given application in the specified
 // app-id.
 func (c *Client) ReadApplication(ctx context.Context, version, app string) (appid.ReadApplicationOutput, error) {
+	ctx = withOperation(ctx, "ReadApplication")
+
 	c.Log(http.MethodGet, "describe app-id %q application: %s", version, app)
 	path := Path{
 		V1Path: []string{"v1", "config", "appidversions", version, "appids", app},
@@ -0,0 +1,256 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+// DefaultRoleSessionName is the session name used when none is configured.
+const DefaultRoleSessionName = "sdk_session"
+
+/*
+AssumeRoleOptions configures how an IAM role is assumed.
+
+	{
+	    "session-name": "ci",
+	    "external-id": "4c2d...",
+	    "source-identity": "alice",
+	    "tags": {"team": "netsec"},
+	    "transitive-tag-keys": ["team"],
+	    "via": ["arn:aws:iam::111111111111:role/jump"],
+	    "via-external-ids": {"arn:aws:iam::111111111111:role/jump": "9a1f..."},
+	    "web-identity-token-file": "/var/run/secrets/oidc/token"
+	}
+*/
+type AssumeRoleOptions struct {
+	// SessionName is the role session name. If empty, DefaultRoleSessionName
+	// is used.
+	SessionName string `json:"session-name,omitempty"`
+
+	// ExternalID is passed when assuming the role itself. The roles in Via
+	// take theirs from ViaExternalIDs.
+	ExternalID string `json:"external-id,omitempty"`
+
+	// SourceIdentity is passed to every AssumeRole call.
+	SourceIdentity string `json:"source-identity,omitempty"`
+
+	// Tags are the session tags, and TransitiveTagKeys the tags that carry
+	// over to the next role in the chain. They are passed to the first
+	// AssumeRole call.
+	Tags              map[string]string `json:"tags,omitempty"`
+	TransitiveTagKeys []string          `json:"transitive-tag-keys,omitempty"`
+
+	// DurationSeconds is the lifetime of the role credentials. If zero, the
+	// STS default is used. AWS caps chained roles at an hour, so it can't be
+	// more than 3600 with Via.
+	DurationSeconds int64 `json:"duration-seconds,omitempty"`
+
+	// Via lists the roles to assume, in order, before the role itself, for
+	// role chaining.
+	Via []string `json:"via,omitempty"`
+
+	// ViaExternalIDs maps the roles in Via onto the external ID to pass when
+	// assuming them.
+	ViaExternalIDs map[string]string `json:"via-external-ids,omitempty"`
+
+	// WebIdentityTokenFile, if set, is the file holding an OIDC token. The
+	// first role is then assumed with AssumeRoleWithWebIdentity, which takes
+	// no external ID, source identity or session tags, so those can only be
+	// used with Via.
+	WebIdentityTokenFile string `json:"web-identity-token-file,omitempty"`
+}
+
+func (o *AssumeRoleOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.DurationSeconds != 0 && (o.DurationSeconds < 900 || o.DurationSeconds > 43200) {
+		return fmt.Errorf("duration-seconds must be between 900 and 43200, not %d", o.DurationSeconds)
+	}
+	if len(o.Via) > 0 && o.DurationSeconds > 3600 {
+		return fmt.Errorf("duration-seconds can't be more than 3600 for a role chained through via, not %d", o.DurationSeconds)
+	}
+	if o.SourceIdentity != "" && len(o.SourceIdentity) < 2 {
+		return fmt.Errorf("source-identity must be at least 2 characters long")
+	}
+	for k := range o.Tags {
+		if k == "" {
+			return fmt.Errorf("tag keys must not be empty")
+		}
+	}
+	for _, k := range o.TransitiveTagKeys {
+		if _, ok := o.Tags[k]; !ok {
+			return fmt.Errorf("transitive tag key %q is not one of the tags", k)
+		}
+	}
+	for _, arn := range o.Via {
+		if arn == "" {
+			return fmt.Errorf("via must not contain empty role ARNs")
+		}
+	}
+	for arn := range o.ViaExternalIDs {
+		if !slices.Contains(o.Via, arn) {
+			return fmt.Errorf("via-external-ids has an external ID for %q, which is not in via", arn)
+		}
+	}
+	if o.WebIdentityTokenFile != "" {
+		if len(o.Via) == 0 {
+			switch {
+			case o.ExternalID != "":
+				return fmt.Errorf("external-id can't be used with web-identity-token-file unless the role is chained through via")
+			case o.SourceIdentity != "":
+				return fmt.Errorf("source-identity can't be used with web-identity-token-file unless the role is chained through via")
+			case len(o.Tags) > 0 || len(o.TransitiveTagKeys) > 0:
+				return fmt.Errorf("tags can't be used with web-identity-token-file unless the role is chained through via")
+			}
+		} else if o.ViaExternalIDs[o.Via[0]] != "" {
+			return fmt.Errorf("via-external-ids can't have an external ID for %q, which is assumed with web-identity-token-file", o.Via[0])
+		}
+	}
+	return nil
+}
+
+// redacted returns a copy of o with the external IDs hidden.
+func (o *AssumeRoleOptions) redacted() AssumeRoleOptions {
+	ans := *o
+	if ans.ExternalID != "" {
+		ans.ExternalID = logging.Redacted
+	}
+	if len(o.ViaExternalIDs) > 0 {
+		ans.ViaExternalIDs = make(map[string]string, len(o.ViaExternalIDs))
+		for arn := range o.ViaExternalIDs {
+			ans.ViaExternalIDs[arn] = logging.Redacted
+		}
+	}
+	return ans
+}
+
+// assumeRoleOptions returns the options for the role used by the given
+// permission.
+func (c *Client) assumeRoleOptions(permission string) AssumeRoleOptions {
+	var o *AssumeRoleOptions
+	switch permission {
+	case PermissionFirewall:
+		o = c.LfaAssumeRole
+	case PermissionRulestack:
+		o = c.LraAssumeRole
+	case PermissionGlobalRulestack:
+		o = c.GraAssumeRole
+	case PermissionAccount:
+		o = c.AcctAdminAssumeRole
+	}
+	if o == nil {
+		o = c.AssumeRole
+	}
+	if o == nil {
+		return AssumeRoleOptions{}
+	}
+	return *o
+}
+
+// assume assumes the role arn, going through any roles in o.Via first, and
+// returns the credentials of the last role.
+func (s *iamRoleSource) assume(ctx context.Context, region, arn string, o AssumeRoleOptions) (*sts.Credentials, error) {
+	session := o.SessionName
+	if session == "" {
+		session = DefaultRoleSessionName
+	}
+
+	var creds *sts.Credentials
+	roles := append(append([]string(nil), o.Via...), arn)
+	for i, role := range roles {
+		svc, err := s.sts(region, creds)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 && o.WebIdentityTokenFile != "" {
+			b, err := ioutil.ReadFile(o.WebIdentityTokenFile)
+			if err != nil {
+				return nil, err
+			}
+			in := &sts.AssumeRoleWithWebIdentityInput{
+				RoleArn:          aws.String(role),
+				RoleSessionName:  aws.String(session),
+				WebIdentityToken: aws.String(strings.TrimSpace(string(b))),
+			}
+			if o.DurationSeconds != 0 && i == len(roles)-1 {
+				in.DurationSeconds = aws.Int64(o.DurationSeconds)
+			}
+			out, err := svc.AssumeRoleWithWebIdentityWithContext(ctx, in)
+			if err != nil {
+				return nil, fmt.Errorf("assuming %s with web identity: %w", role, err)
+			}
+			creds = out.Credentials
+			continue
+		}
+
+		in := &sts.AssumeRoleInput{
+			RoleArn:         aws.String(role),
+			RoleSessionName: aws.String(session),
+		}
+		extID := o.ViaExternalIDs[role]
+		if i == len(roles)-1 {
+			extID = o.ExternalID
+		}
+		if extID != "" {
+			in.ExternalId = aws.String(extID)
+		}
+		if o.SourceIdentity != "" {
+			in.SourceIdentity = aws.String(o.SourceIdentity)
+		}
+		if o.DurationSeconds != 0 && i == len(roles)-1 {
+			in.DurationSeconds = aws.Int64(o.DurationSeconds)
+		}
+		// Transitive tags can't be set again further down the chain, so
+		// the tags are only passed to the first AssumeRole call.
+		if i == 0 || (i == 1 && o.WebIdentityTokenFile != "") {
+			in.Tags = sessionTags(o.Tags)
+			in.TransitiveTagKeys = aws.StringSlice(o.TransitiveTagKeys)
+		}
+		out, err := svc.AssumeRoleWithContext(ctx, in)
+		if err != nil {
+			return nil, fmt.Errorf("assuming %s: %w", role, err)
+		}
+		creds = out.Credentials
+	}
+
+	return creds, nil
+}
+
+func sessionTags(tags map[string]string) []*sts.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ans := make([]*sts.Tag, 0, len(keys))
+	for _, k := range keys {
+		ans = append(ans, &sts.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+	return ans
+}
+
+// chainedCredentials turns the credentials of an assumed role into the
+// credentials for the next STS call.
+func chainedCredentials(creds *sts.Credentials) *credentials.Credentials {
+	return credentials.NewStaticCredentials(
+		aws.StringValue(creds.AccessKeyId),
+		aws.StringValue(creds.SecretAccessKey),
+		aws.StringValue(creds.SessionToken),
+	)
+}
@@ -0,0 +1,180 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// stsStub is a fake STS endpoint that records the form of every call.
+type stsStub struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls []map[string]string
+}
+
+func newSTSStub(t *testing.T) *stsStub {
+	s := &stsStub{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("bad STS request: %s", err)
+		}
+		call := make(map[string]string)
+		for k := range r.PostForm {
+			call[k] = r.PostForm.Get(k)
+		}
+		s.mu.Lock()
+		s.calls = append(s.calls, call)
+		n := len(s.calls)
+		s.mu.Unlock()
+
+		action := call["Action"]
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>AKID%[2]d</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>token</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`, action, n)
+	}))
+	return s
+}
+
+func TestAssumeRoleChain(t *testing.T) {
+	sts := newSTSStub(t)
+	defer sts.Close()
+
+	c := &Client{AccessKey: "AKID0", SecretKey: "secret", AssumeRole: &AssumeRoleOptions{
+		SessionName:       "ci",
+		ExternalID:        "ext",
+		Tags:              map[string]string{"team": "netsec"},
+		TransitiveTagKeys: []string{"team"},
+		DurationSeconds:   3600,
+		Via:               []string{"arn:aws:iam::111111111111:role/jump"},
+		ViaExternalIDs:    map[string]string{"arn:aws:iam::111111111111:role/jump": "jump-ext"},
+	}}
+	if err := c.AssumeRole.validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := c.iamRoles()
+	s.endpoint = sts.URL
+
+	creds, err := s.assume(context.Background(), "us-east-1", "arn:aws:iam::222222222222:role/lra", c.assumeRoleOptions(PermissionRulestack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *creds.AccessKeyId != "AKID2" {
+		t.Fatalf("got the credentials of the wrong role: %s", *creds.AccessKeyId)
+	}
+
+	calls := sts.calls
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 STS calls, got %d", len(calls))
+	}
+	first, last := calls[0], calls[1]
+	if first["RoleArn"] != "arn:aws:iam::111111111111:role/jump" || last["RoleArn"] != "arn:aws:iam::222222222222:role/lra" {
+		t.Fatalf("roles assumed in the wrong order: %q, %q", first["RoleArn"], last["RoleArn"])
+	}
+	if first["Tags.member.1.Key"] != "team" || first["TransitiveTagKeys.member.1"] != "team" || last["Tags.member.1.Key"] != "" {
+		t.Fatalf("session tags should only be sent to the first role: %v, %v", first, last)
+	}
+	if first["DurationSeconds"] != "" || last["DurationSeconds"] != "3600" {
+		t.Fatalf("duration should only be sent to the last role: %v, %v", first, last)
+	}
+	if first["ExternalId"] != "jump-ext" || last["ExternalId"] != "ext" {
+		t.Fatalf("each role should get its own external ID: %v, %v", first, last)
+	}
+	for _, call := range calls {
+		if call["RoleSessionName"] != "ci" {
+			t.Fatalf("missing session name: %v", call)
+		}
+	}
+}
+
+func TestAssumeRoleWithWebIdentity(t *testing.T) {
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("oidc-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sts := newSTSStub(t)
+	defer sts.Close()
+
+	c := &Client{AssumeRole: &AssumeRoleOptions{
+		ExternalID:           "ext",
+		SourceIdentity:       "alice",
+		Tags:                 map[string]string{"team": "netsec"},
+		DurationSeconds:      3600,
+		Via:                  []string{"arn:aws:iam::111111111111:role/jump"},
+		WebIdentityTokenFile: token,
+	}}
+	if err := c.AssumeRole.validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := c.iamRoles()
+	s.endpoint = sts.URL
+
+	creds, err := s.assume(context.Background(), "us-east-1", "arn:aws:iam::222222222222:role/lra", c.assumeRoleOptions(PermissionRulestack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *creds.AccessKeyId != "AKID2" {
+		t.Fatalf("got the credentials of the wrong role: %s", *creds.AccessKeyId)
+	}
+
+	calls := sts.calls
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 STS calls, got %d", len(calls))
+	}
+	first, last := calls[0], calls[1]
+	if first["Action"] != "AssumeRoleWithWebIdentity" || first["WebIdentityToken"] != "oidc-token" {
+		t.Fatalf("the first role should be assumed with the web identity token: %v", first)
+	}
+	if first["DurationSeconds"] != "" || last["DurationSeconds"] != "3600" {
+		t.Fatalf("duration should only be sent to the last role: %v, %v", first, last)
+	}
+	if last["Action"] != "AssumeRole" || last["ExternalId"] != "ext" || last["SourceIdentity"] != "alice" || last["Tags.member.1.Key"] != "team" {
+		t.Fatalf("the chained role should get the external ID, source identity and tags: %v", last)
+	}
+}
+
+func TestAssumeRoleOptionsValidate(t *testing.T) {
+	bad := []AssumeRoleOptions{
+		{DurationSeconds: 60},
+		{TransitiveTagKeys: []string{"team"}},
+		{Via: []string{""}},
+		{DurationSeconds: 7200, Via: []string{"arn:aws:iam::111111111111:role/jump"}},
+		{ViaExternalIDs: map[string]string{"arn:aws:iam::111111111111:role/jump": "ext"}},
+		{WebIdentityTokenFile: "token", ExternalID: "ext"},
+		{WebIdentityTokenFile: "token", SourceIdentity: "alice"},
+		{WebIdentityTokenFile: "token", Tags: map[string]string{"team": "netsec"}},
+		{
+			WebIdentityTokenFile: "token",
+			Via:                  []string{"arn:aws:iam::111111111111:role/jump"},
+			ViaExternalIDs:       map[string]string{"arn:aws:iam::111111111111:role/jump": "ext"},
+		},
+	}
+	for _, o := range bad {
+		if err := o.validate(); err == nil {
+			t.Fatalf("%+v should not be valid", o)
+		}
+	}
+}
+
+func TestTokenScopeAssumeRoleOptions(t *testing.T) {
+	c := &Client{AuthType: AuthTypeIAMRole, Arn: "arn:aws:iam::222222222222:role/lra"}
+	direct := c.tokenScope(PermissionRulestack)
+
+	c.AssumeRole = &AssumeRoleOptions{Via: []string{"arn:aws:iam::111111111111:role/jump"}}
+	if c.tokenScope(PermissionRulestack) == direct {
+		t.Fatalf("a role assumed through another should not share the cached token")
+	}
+}
@@ -0,0 +1,129 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+authFile is an auth file with named contexts. Each context holds the same
+settings as a flat auth file:
+
+	current-context: dev
+	contexts:
+	  dev:
+	    host: api.us-east-1.aws.cloudngfw.paloaltonetworks.com
+	    region: us-east-1
+	    lra-arn: arn:aws:iam::111111111111:role/dev-lra
+	  prod:
+	    region: us-west-2
+	    lra-arn: arn:aws:iam::222222222222:role/prod-lra
+	    logging: [login, action]
+
+Files without contexts are read as a single flat context.
+*/
+type authFile struct {
+	CurrentContext string                     `json:"current-context"`
+	Contexts       map[string]json.RawMessage `json:"contexts"`
+}
+
+// readAuthFile returns the contents of an auth file as JSON. Files named
+// *.yaml or *.yml are converted from YAML.
+func readAuthFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var v interface{}
+		if err = yaml.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if b, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return b, nil
+}
+
+/*
+authFileSettings returns the settings of a context of an auth file as JSON,
+along with the name of the context.
+
+The context is the given one, else the CLOUDNGFWAWS_CONTEXT env var if
+checkEnv is set, else the file's current-context, else the file's only
+context. Files without contexts are returned whole, with no name.
+*/
+func authFileSettings(path, name string, checkEnv bool) (json.RawMessage, string, error) {
+	b, err := readAuthFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var af authFile
+	if err = json.Unmarshal(b, &af); err != nil {
+		return nil, "", err
+	}
+	if af.Contexts == nil {
+		if name != "" {
+			return nil, "", fmt.Errorf("%s has no contexts, so context %q can't be used", path, name)
+		}
+		return b, "", nil
+	}
+
+	if name == "" {
+		if val := os.Getenv("CLOUDNGFWAWS_CONTEXT"); checkEnv && val != "" {
+			name = val
+		} else if af.CurrentContext != "" {
+			name = af.CurrentContext
+		} else if len(af.Contexts) == 1 {
+			for k := range af.Contexts {
+				name = k
+			}
+		} else {
+			return nil, "", fmt.Errorf("%s has several contexts and no current-context, choose one of: %s", path, strings.Join(contextNames(af), ", "))
+		}
+	}
+
+	raw, ok := af.Contexts[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%s has no context %q, choose one of: %s", path, name, strings.Join(contextNames(af), ", "))
+	}
+	return raw, name, nil
+}
+
+func contextNames(af authFile) []string {
+	names := make([]string, 0, len(af.Contexts))
+	for k := range af.Contexts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AuthFileContexts returns the names of the contexts in an auth file, sorted,
+// and its current-context.
+func AuthFileContexts(path string) ([]string, string, error) {
+	b, err := readAuthFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var af authFile
+	if err = json.Unmarshal(b, &af); err != nil {
+		return nil, "", err
+	}
+	if len(af.Contexts) == 0 {
+		return nil, "", nil
+	}
+	return contextNames(af), af.CurrentContext, nil
+}
@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+)
+
+func TestAuthFileContexts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.yaml")
+	err := ioutil.WriteFile(path, []byte(`
+current-context: dev
+contexts:
+  dev:
+    host: dev.example.com
+    region: us-east-1
+    lra-arn: arn:aws:iam::111111111111:role/dev-lra
+    headers:
+      x-team: netsec
+  prod:
+    host: prod.example.com
+    region: us-west-2
+    logging: [login]
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, current, err := AuthFileContexts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[dev prod]" || current != "dev" {
+		t.Fatalf("got contexts %v, current %q", names, current)
+	}
+
+	c := &Client{AuthFile: path}
+	if err = c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Context != "dev" || c.Host != "dev.example.com" || c.LraArn != "arn:aws:iam::111111111111:role/dev-lra" || c.Headers["x-team"] != "netsec" {
+		t.Fatalf("dev context was not loaded: %+v", c)
+	}
+
+	t.Setenv("CLOUDNGFWAWS_CONTEXT", "prod")
+	c = &Client{AuthFile: path, CheckEnvironment: true}
+	if err = c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Context != "prod" || c.Host != "prod.example.com" || c.Region != "us-west-2" || c.Logging != awsngfw.LogLogin {
+		t.Fatalf("prod context was not loaded: %+v", c)
+	}
+
+	c = &Client{AuthFile: path, Context: "staging"}
+	if err = c.Setup(); err == nil || !strings.Contains(err.Error(), "dev, prod") {
+		t.Fatalf("expected an unknown context error, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/response"
+)
+
+// minAuthBackoff is how long auth is suspended after its first failure.
+const minAuthBackoff = time.Second
+
+/*
+AuthUnavailableError is returned instead of fetching a token while auth is
+suspended after failures.
+
+Err is the error of the last attempt.
+*/
+type AuthUnavailableError struct {
+	RetryAt time.Time
+	Err     error
+}
+
+func (e *AuthUnavailableError) Error() string {
+	return fmt.Sprintf("authentication is suspended until %s after failures, last error: %s", e.RetryAt.Format(time.RFC3339), e.Err)
+}
+
+func (e *AuthUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// AuthHealth is the state of the client's authentication.
+type AuthHealth struct {
+	// Healthy is false after a failed attempt, until one succeeds.
+	Healthy bool
+
+	// Failures is the number of consecutive failed attempts.
+	Failures int
+
+	LastError   error
+	LastFailure time.Time
+	LastSuccess time.Time
+
+	// RetryAt is when the next attempt will be made, if auth is suspended.
+	RetryAt time.Time
+}
+
+/*
+authBreaker is a circuit breaker in front of a TokenSource, with one circuit
+per token key.
+
+After a transient failure, calls for that key fail fast with an
+AuthUnavailableError until a backoff delay has passed, then a single attempt
+is let through. The delay doubles with each consecutive failure, up to
+MaxBackoffTime, and is reset by a success. Other errors, such as a role
+missing from the client's config, are returned as is and leave the circuit
+alone.
+*/
+type authBreaker struct {
+	source     TokenSource
+	minBackoff time.Duration
+
+	mu       sync.Mutex
+	circuits map[string]*authCircuit
+}
+
+type authCircuit struct {
+	health  AuthHealth
+	probing bool
+}
+
+func newAuthBreaker(src TokenSource) *authBreaker {
+	return &authBreaker{
+		source:     src,
+		minBackoff: minAuthBackoff,
+		circuits:   make(map[string]*authCircuit),
+	}
+}
+
+// circuit returns the circuit for key. This must be called with b.mu taken.
+func (b *authBreaker) circuit(key string) *authCircuit {
+	ac := b.circuits[key]
+	if ac == nil {
+		ac = &authCircuit{health: AuthHealth{Healthy: true}}
+		b.circuits[key] = ac
+	}
+	return ac
+}
+
+// Token implements TokenSource.
+func (b *authBreaker) Token(ctx context.Context, permission string) (Token, error) {
+	b.mu.Lock()
+	ac := b.circuit(permission)
+	if h := ac.health; !h.Healthy {
+		if ac.probing || time.Now().Before(h.RetryAt) {
+			b.mu.Unlock()
+			return Token{}, &AuthUnavailableError{RetryAt: h.RetryAt, Err: h.LastError}
+		}
+		ac.probing = true
+	}
+	b.mu.Unlock()
+
+	tok, err := b.source.Token(ctx, permission)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ac.probing = false
+	switch {
+	case err == nil:
+		ac.health = AuthHealth{Healthy: true, LastSuccess: time.Now()}
+	case ctx.Err() != nil:
+		// The caller gave up, which says nothing about the auth endpoint.
+	case !transientAuthError(err):
+		// Retrying won't help, so there is no point in suspending auth.
+	default:
+		h := &ac.health
+		h.Healthy = false
+		h.Failures++
+		h.LastError = err
+		h.LastFailure = time.Now()
+
+		delay := b.minBackoff
+		for i := 1; i < h.Failures && delay < MaxBackoffTime; i++ {
+			delay *= 2
+		}
+		if delay > MaxBackoffTime {
+			delay = MaxBackoffTime
+		}
+		h.RetryAt = h.LastFailure.Add(delay)
+	}
+	return tok, err
+}
+
+/*
+Health returns the state of the breaker for key, or if key is empty, of the
+whole breaker: unhealthy as long as a circuit is, in which case the most
+recently failed circuit is returned.
+*/
+func (b *authBreaker) Health(key string) AuthHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if key != "" {
+		return b.circuit(key).health
+	}
+	ans := AuthHealth{Healthy: true}
+	for _, ac := range b.circuits {
+		h := ac.health
+		switch {
+		case !h.Healthy:
+			if ans.Healthy || h.LastFailure.After(ans.LastFailure) {
+				ans = h
+			}
+		case ans.Healthy && h.LastSuccess.After(ans.LastSuccess):
+			ans.LastSuccess = h.LastSuccess
+		}
+	}
+	return ans
+}
+
+/*
+transientAuthError returns true if err may go away by itself: transport
+errors, throttling and server side errors of the auth endpoints or STS.
+*/
+func transientAuthError(err error) bool {
+	var apiErr *response.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return request.IsErrorRetryable(awsErr) || request.IsErrorThrottle(awsErr)
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || DefaultRetryable(nil, err)
+}
+
+// guard puts src behind the client's auth circuit breaker.
+func (c *Client) guard(src TokenSource) TokenSource {
+	c.breakerOnce.Do(func() {
+		c.breaker = newAuthBreaker(src)
+	})
+	return c.breaker
+}
+
+/*
+AuthHealth returns the state of the client's authentication: whether the
+last attempt to fetch a token succeeded, and if not, why and when the next
+attempt will be made. If the tokens of several permissions failed, the one
+that failed last is described, see AuthHealthFor.
+
+Clients that don't fetch tokens themselves are always healthy.
+*/
+func (c *Client) AuthHealth() AuthHealth {
+	c.tokens()
+	if c.breaker == nil {
+		return AuthHealth{Healthy: true}
+	}
+	return c.breaker.Health("")
+}
+
+// AuthHealthFor returns the state of the authentication for the given
+// permission (one of the Permission constants). Permissions that share a
+// token share their state.
+func (c *Client) AuthHealthFor(permission string) AuthHealth {
+	c.tokens()
+	if c.breaker == nil {
+		return AuthHealth{Healthy: true}
+	}
+	return c.breaker.Health(c.tokenKey(permission))
+}
@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestAuthBreakerAgainstFake(t *testing.T) {
+	runAgainstFake(t, []fakeTest{
+		{
+			name: "suspends-after-outage",
+			server: func(srv *ngfwtest.Server) {
+				srv.AddFault(ngfwtest.Fault{Path: "/" + AuthEndpoint, Count: 1, StatusCode: http.StatusServiceUnavailable})
+			},
+			run: func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client) {
+				c.RetryPolicy = &RetryPolicy{MaxAttempts: 1}
+
+				authCalls := func() int {
+					var n int
+					for _, r := range srv.Requests() {
+						if strings.HasSuffix(r.Path, "/tokens/cloudmanager") {
+							n++
+						}
+					}
+					return n
+				}
+
+				if _, err := c.ListRuleStack(ctx, stack.ListInput{}); err == nil {
+					t.Fatalf("request succeeded while the auth endpoint was down")
+				}
+				h := c.AuthHealth()
+				if h.Healthy || h.Failures != 1 || h.LastError == nil || !h.RetryAt.After(h.LastFailure) {
+					t.Fatalf("wrong health after a failure: %+v", h)
+				}
+				if h = c.AuthHealthFor(PermissionAccount); !h.Healthy {
+					t.Fatalf("the account admin token should not be suspended: %+v", h)
+				}
+
+				// While suspended, auth fails fast without calling the endpoint.
+				n := authCalls()
+				_, err := c.ListRuleStack(ctx, stack.ListInput{})
+				var unavailable *AuthUnavailableError
+				if !errors.As(err, &unavailable) {
+					t.Fatalf("expected an AuthUnavailableError, got %v", err)
+				}
+				if authCalls() != n {
+					t.Fatalf("auth endpoint was called while suspended")
+				}
+
+				// Once the backoff is over, a successful attempt closes the breaker.
+				c.breaker.mu.Lock()
+				c.breaker.circuits[tokenCloudRulestack].health.RetryAt = time.Now()
+				c.breaker.mu.Unlock()
+				if _, err = c.ListRuleStack(ctx, stack.ListInput{}); err != nil {
+					t.Fatal(err)
+				}
+				if h = c.AuthHealth(); !h.Healthy || h.Failures != 0 || h.LastSuccess.IsZero() {
+					t.Fatalf("wrong health after a success: %+v", h)
+				}
+			},
+		},
+		{
+			name: "ignores-config-errors",
+			server: func(srv *ngfwtest.Server) {
+				srv.ExternalIDs = []string{"some-other-id"}
+			},
+			run: func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client) {
+				// Neither an external ID that is turned down nor a permission the auth
+				// type can't get suspends auth.
+				for i := 0; i < 2; i++ {
+					if _, err := c.ListRuleStack(ctx, stack.ListInput{}); err == nil {
+						t.Fatalf("request succeeded without a valid external ID")
+					} else if errors.As(err, new(*AuthUnavailableError)) {
+						t.Fatalf("auth was suspended after a rejected external ID: %s", err)
+					}
+				}
+				if _, err := c.tokens().Token(ctx, PermissionAccount); err == nil {
+					t.Fatalf("got an account admin token with external ID auth")
+				}
+				if h := c.AuthHealth(); !h.Healthy {
+					t.Fatalf("auth should be healthy: %+v", h)
+				}
+
+				iam := &Client{AuthType: AuthTypeIAMRole}
+				if _, err := iam.tokens().Token(ctx, PermissionAccount); err == nil {
+					t.Fatalf("got an account admin token without a role")
+				}
+				if h := iam.AuthHealthFor(PermissionAccount); !h.Healthy {
+					t.Fatalf("a missing role should not suspend auth: %+v", h)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,311 @@
+/*
+Package cassette records the HTTP traffic of an aws.Client to a JSON file and
+replays it later, so tests can exercise real responses without a network.
+
+Record by adding a Recorder as client middleware, then saving it:
+
+	rec := cassette.NewRecorder()
+	c.Middleware = append(c.Middleware, rec.Middleware)
+	... make calls ...
+	err := rec.Save("testdata/rulestack.json")
+
+Replay by loading the file and using it as the client's transport:
+
+	cas, err := cassette.Load("testdata/rulestack.json")
+	c.HttpClient = cas.Client()
+	c.SecureHttpClient = cas.Client()
+
+Secrets are scrubbed before anything is written: sensitive headers, query
+parameters and JSON values are replaced with "REDACTED". JWTs keep their
+header and claims, so the client can still read the tenant version from a
+replayed token, but lose their signature.
+*/
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+// Request is a recorded request.
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Query  string          `json:"query,omitempty"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int             `json:"status_code"`
+	Header     http.Header     `json:"header,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+}
+
+// Interaction is a request and the response it got.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is a list of interactions, in the order they happened.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+
+	mu   sync.Mutex
+	next int
+}
+
+// Load reads a cassette from a file.
+func Load(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+/*
+RoundTrip replays the next interaction.
+
+Requests must arrive in the recorded order, and must match the recorded
+method, path and scrubbed query. Headers and bodies are not compared, since
+they hold signatures, timestamps and update tokens that change from run to
+run.
+*/
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if c.next >= len(c.Interactions) {
+		return nil, fmt.Errorf("cassette: unexpected request %s %s, all %d interactions were replayed", req.Method, req.URL.Path, len(c.Interactions))
+	}
+
+	in := c.Interactions[c.next]
+	query := canonicalQuery(req)
+	if in.Request.Method != req.Method || in.Request.Path != req.URL.Path || in.Request.Query != query {
+		return nil, fmt.Errorf("cassette: interaction %d: got %s %s?%s, want %s %s?%s", c.next, req.Method, req.URL.Path, query, in.Request.Method, in.Request.Path, in.Request.Query)
+	}
+	c.next++
+
+	header := in.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Response.StatusCode, http.StatusText(in.Response.StatusCode)),
+		StatusCode:    in.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(in.Response.Body)),
+		ContentLength: int64(len(in.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Client returns an http.Client that replays the cassette.
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// Remaining returns the number of interactions not yet replayed.
+func (c *Cassette) Remaining() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.Interactions) - c.next
+}
+
+// Recorder records the interactions that pass through its Middleware.
+type Recorder struct {
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Middleware records every request sent through next, along with its
+// response. Its signature matches aws.Middleware.
+func (r *Recorder) Middleware(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		var reqBody []byte
+		if req.GetBody != nil {
+			if b, err := req.GetBody(); err == nil {
+				reqBody, _ = ioutil.ReadAll(b)
+				b.Close()
+			}
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+		r.mu.Lock()
+		r.interactions = append(r.interactions, Interaction{
+			Request: Request{
+				Method: req.Method,
+				Path:   req.URL.Path,
+				Query:  canonicalQuery(req),
+				Header: scrubHeader(req.Header),
+				Body:   scrubBody(reqBody),
+			},
+			Response: Response{
+				StatusCode: resp.StatusCode,
+				Header:     scrubHeader(resp.Header),
+				Body:       scrubBody(respBody),
+			},
+		})
+		r.mu.Unlock()
+
+		return resp, nil
+	})
+}
+
+// Cassette returns the interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ans := &Cassette{Interactions: make([]Interaction, len(r.interactions))}
+	copy(ans.Interactions, r.interactions)
+	return ans
+}
+
+// Save writes the interactions recorded so far to a file.
+func (r *Recorder) Save(path string) error {
+	b, err := json.MarshalIndent(r.Cassette(), "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// canonicalQuery returns the query with its keys sorted and sensitive values
+// scrubbed.
+func canonicalQuery(req *http.Request) string {
+	q := req.URL.Query()
+	for k, vals := range q {
+		if logging.IsSensitive(k) {
+			for i := range vals {
+				vals[i] = scrubValue(vals[i])
+			}
+		}
+	}
+	return q.Encode()
+}
+
+// volatileHeaders change on every request and are not worth recording.
+var volatileHeaders = []string{"Date", "X-Amz-Date", "Content-Length"}
+
+func scrubHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+
+	ans := make(http.Header, len(h))
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if isVolatile(k) {
+			continue
+		}
+		if logging.IsSensitive(k) {
+			ans[k] = []string{scrubValue(h.Get(k))}
+			continue
+		}
+		ans[k] = append([]string(nil), h[k]...)
+	}
+	return ans
+}
+
+func isVolatile(k string) bool {
+	for _, v := range volatileHeaders {
+		if strings.EqualFold(k, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubBody redacts sensitive values in a JSON body. Bodies that are not
+// JSON are stored as a JSON string.
+func scrubBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		b, _ := json.Marshal(string(body))
+		return b
+	}
+	b, err := json.Marshal(scrub(v))
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func scrub(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			if s, ok := val.(string); ok && logging.IsSensitive(k) {
+				x[k] = scrubValue(s)
+			} else {
+				x[k] = scrub(val)
+			}
+		}
+	case []interface{}:
+		for i := range x {
+			x[i] = scrub(x[i])
+		}
+	}
+	return v
+}
+
+// scrubValue redacts a secret. JWTs keep their header and claims.
+func scrubValue(s string) string {
+	if parts := strings.Split(s, "."); len(parts) == 3 && parts[0] != "" && parts[1] != "" {
+		return parts[0] + "." + parts[1] + "." + logging.Redacted
+	}
+	return logging.Redacted
+}
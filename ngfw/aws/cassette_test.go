@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/cassette"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+	c := newExternalIDClient(t, srv)
+	rec := cassette.NewRecorder()
+	c.Middleware = []Middleware{rec.Middleware}
+
+	session := func(c *Client) (stack.ReadOutput, error) {
+		if err := c.CreateRuleStack(ctx, stack.Info{Name: "rs1", Entry: stack.Details{Description: "recorded"}}); err != nil {
+			return stack.ReadOutput{}, err
+		}
+		return c.ReadRuleStack(ctx, stack.ReadInput{Name: "rs1"})
+	}
+	recorded, err := session(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "rulestack.json")
+	if err = rec.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "externalid=") {
+		t.Fatalf("cassette did not record the external ID token call")
+	}
+	if strings.Contains(string(b), "ext-1234") {
+		t.Fatalf("cassette contains the external ID")
+	}
+	for _, r := range srv.Requests() {
+		if tok := r.Header.Get("Authorization"); tok != "" && strings.Contains(string(b), tok) {
+			t.Fatalf("cassette contains a JWT")
+		}
+		if sk := r.Header.Get("x-api-key"); sk != "" && strings.Contains(string(b), sk) {
+			t.Fatalf("cassette contains a subscription key")
+		}
+	}
+
+	cas, err := cassette.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := &Client{AuthType: AuthTypeExternalID}
+	err = replay.SetupUsingCreds(ctx, AuthInfo{
+		ExternalID:       "ext-1234",
+		Region:           ngfwtest.DefaultRegion,
+		HttpClient:       cas.Client(),
+		SecureHttpClient: cas.Client(),
+		RegionURL:        "https://replay.invalid",
+		RegionV2URL:      "https://replay.invalid",
+		AuthURL:          "https://replay.invalid",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := session(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Response.Candidate == nil || replayed.Response.Candidate.Description != recorded.Response.Candidate.Description {
+		t.Fatalf("replayed %+v, recorded %+v", replayed.Response, recorded.Response)
+	}
+	if n := cas.Remaining(); n != 0 {
+		t.Fatalf("%d interactions were not replayed", n)
+	}
+	if _, err = replay.ListRuleStack(ctx, stack.ListInput{}); err == nil {
+		t.Fatalf("unrecorded request was not rejected")
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 // ListCertificate returns a certificate.List of objects.
 func (c *Client) ListCertificate(ctx context.Context, input certificate.ListInput) (certificate.ListOutput, error) {
+	ctx = withOperation(ctx, "ListCertificate")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return certificate.ListOutput{}, permErr
@@ -34,6 +36,8 @@ func (c *Client) ListCertificate(ctx context.Context, input certificate.ListInpu
 
 // Create creates an object.
 func (c *Client) CreateCertificate(ctx context.Context, input certificate.Info) error {
+	ctx = withOperation(ctx, "CreateCertificate")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -58,6 +62,8 @@ func (c *Client) CreateCertificate(ctx context.Context, input certificate.Info)
 
 // Read returns information on the given object.
 func (c *Client) ReadCertificate(ctx context.Context, input certificate.ReadInput) (certificate.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadCertificate")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return certificate.ReadOutput{}, permErr
@@ -83,6 +89,8 @@ func (c *Client) ReadCertificate(ctx context.Context, input certificate.ReadInpu
 
 // Update updates the given object.
 func (c *Client) UpdateCertificate(ctx context.Context, input certificate.Info) error {
+	ctx = withOperation(ctx, "UpdateCertificate")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -110,6 +118,8 @@ func (c *Client) UpdateCertificate(ctx context.Context, input certificate.Info)
 
 // Delete removes the given object from the config.
 func (c *Client) DeleteCertificate(ctx context.Context, input certificate.DeleteInput) error {
+	ctx = withOperation(ctx, "DeleteCertificate")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -0,0 +1,254 @@
+package aws
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+const (
+	// CertExpiryWarning is how long before it expires a client certificate
+	// starts being warned about.
+	CertExpiryWarning = 14 * 24 * time.Hour
+
+	// certCheckInterval is how often the certificate files are checked for
+	// changes, and the expiry warning repeated.
+	certCheckInterval = 30 * time.Second
+	certWarnInterval  = 12 * time.Hour
+)
+
+/*
+CertReloader serves an mTLS client certificate read from files, reloading
+it when the files change.
+
+The files are checked at most every 30 seconds, when a TLS handshake asks
+for the certificate. If a changed file can't be loaded, say because the
+certificate was rewritten before its key, the previous certificate is kept
+and the error is logged. Warnings are logged as the certificate nears its
+expiry.
+*/
+type CertReloader struct {
+	CertFile string
+	KeyFile  string
+
+	// CAFile, if set, holds the CAs that the server's certificate is
+	// verified against, instead of the system roots.
+	CAFile string
+
+	logger logging.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	leaf    *x509.Certificate
+	roots   *x509.CertPool
+	mtimes  [3]time.Time
+	checked time.Time
+	warned  time.Time
+}
+
+// NewCertReloader loads the given files, returning an error if they can't
+// be. The logger gets reload errors and expiry warnings, and may be nil.
+func NewCertReloader(certFile, keyFile, caFile string, logger logging.Logger) (*CertReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both a client certificate and key file are required")
+	}
+	if logger == nil {
+		logger = logging.Nop()
+	}
+
+	r := &CertReloader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		CAFile:   caFile,
+		logger:   logger.With(logging.F("cert_file", certFile)),
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	r.checkExpiry()
+	return r, nil
+}
+
+// GetClientCertificate can be used as tls.Config.GetClientCertificate.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refresh()
+	return r.cert, nil
+}
+
+/*
+TLSConfig returns a TLS config presenting the client certificate.
+
+If the reloader has a CAFile, the server's certificate is verified against
+the CAs it holds now. Go only reads RootCAs once, so use DialTLSContext to
+pick up later changes to the CAFile.
+*/
+func (r *CertReloader) TLSConfig() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tlsConfig()
+}
+
+// tlsConfig returns the TLS config for the current files. This must be
+// called with r.mu taken.
+func (r *CertReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: r.GetClientCertificate,
+		RootCAs:              r.roots,
+	}
+}
+
+// DialTLSContext can be used as http.Transport.DialTLSContext. Each
+// connection verifies the server's certificate against the latest contents
+// of the CAFile, and for the host dialled.
+func (r *CertReloader) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return r.dialTLS(ctx, d.DialContext, network, addr)
+}
+
+func (r *CertReloader) dialTLS(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error), network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.refresh()
+	cfg := r.tlsConfig()
+	r.mu.Unlock()
+	cfg.ServerName = host
+
+	raw, err := dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(raw, cfg)
+	if err = conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// refresh reloads the files if they changed. This must be called with r.mu
+// taken.
+func (r *CertReloader) refresh() {
+	if time.Since(r.checked) < certCheckInterval {
+		return
+	}
+	r.checked = time.Now()
+
+	if r.modTimes() != r.mtimes {
+		if err := r.load(); err != nil {
+			r.logger.Error("failed to reload the client certificate, keeping the previous one", logging.F("error", err))
+		} else {
+			r.logger.Info("reloaded the client certificate", logging.F("expires", r.leaf.NotAfter))
+		}
+	}
+	r.checkExpiry()
+}
+
+// load reads the files. This must be called with r.mu taken, or before r
+// is shared.
+func (r *CertReloader) load() error {
+	mtimes := r.modTimes()
+
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	var roots *x509.CertPool
+	if r.CAFile != "" {
+		b, err := ioutil.ReadFile(r.CAFile)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no CA certificates found in %s", r.CAFile)
+		}
+	}
+
+	cert.Leaf = leaf
+	r.cert, r.leaf, r.roots, r.mtimes = &cert, leaf, roots, mtimes
+	return nil
+}
+
+func (r *CertReloader) modTimes() [3]time.Time {
+	var ans [3]time.Time
+	for i, name := range []string{r.CertFile, r.KeyFile, r.CAFile} {
+		if name == "" {
+			continue
+		}
+		if fi, err := os.Stat(name); err == nil {
+			ans[i] = fi.ModTime()
+		}
+	}
+	return ans
+}
+
+// checkExpiry warns about a certificate that expires soon, at most every
+// twelve hours. This must be called with r.mu taken.
+func (r *CertReloader) checkExpiry() {
+	left := time.Until(r.leaf.NotAfter)
+	if left > CertExpiryWarning || time.Since(r.warned) < certWarnInterval {
+		return
+	}
+	r.warned = time.Now()
+
+	if left <= 0 {
+		r.logger.Error("the client certificate has expired", logging.F("expired", r.leaf.NotAfter))
+	} else {
+		r.logger.Warn("the client certificate expires soon", logging.F("expires", r.leaf.NotAfter))
+	}
+}
+
+// secureHttpClient returns an http client presenting the client's
+// certificate files for mTLS.
+func (c *Client) secureHttpClient() (*http.Client, error) {
+	r, err := NewCertReloader(c.ClientCertFile, c.ClientKeyFile, c.ClientCAFile, c.logger())
+	if err != nil {
+		return nil, err
+	}
+
+	tr := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if c.Transport != nil {
+		tr = c.Transport.Clone()
+	}
+	tr.TLSClientConfig = r.TLSConfig()
+	if c.ClientCAFile != "" {
+		// Connections through a proxy use TLSClientConfig, and so keep the
+		// CAs the client started with.
+		dial := tr.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		}
+		tr.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return r.dialTLS(ctx, dial, network, addr)
+		}
+	}
+
+	hc := &http.Client{Transport: tr}
+	if c.HttpClient != nil {
+		hc.Timeout = c.HttpClient.Timeout
+	}
+	return hc, nil
+}
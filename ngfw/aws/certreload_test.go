@@ -0,0 +1,169 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+// writeCert writes a client certificate with the given serial number,
+// signed by ca, to files in dir.
+func writeCert(t *testing.T, dir string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: kb}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the change is seen even on coarse file systems.
+	mtime := time.Now().Add(time.Duration(serial) * time.Second)
+	os.Chtimes(certFile, mtime, mtime)
+	os.Chtimes(keyFile, mtime, mtime)
+}
+
+func TestCertReloader(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].SerialNumber)
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	writeCert(t, dir, ca, caKey, 2, time.Now().Add(72*time.Hour))
+
+	var buf bytes.Buffer
+	r, err := NewCertReloader(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"), caFile, logging.Std(log.New(&buf, "", 0), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "expires soon") {
+		t.Fatalf("no expiry warning was logged: %s", buf.String())
+	}
+	hc := &http.Client{Transport: &http.Transport{DialTLSContext: r.DialTLSContext}}
+
+	serial := func() string {
+		hc.CloseIdleConnections()
+		resp, err := hc.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b)
+	}
+	if s := serial(); s != "2" {
+		t.Fatalf("server saw certificate %s", s)
+	}
+
+	// The server's certificate must be for the host dialled, even when it
+	// is an IP address.
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	redirect := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	other := &http.Client{Transport: &http.Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return r.dialTLS(ctx, redirect, network, addr)
+		},
+	}}
+	if _, err = other.Get("https://127.0.0.2:" + port); err == nil {
+		t.Fatalf("server was accepted for an IP address not in its certificate")
+	}
+
+	// Rotate the certificate, and let the reloader check the files again.
+	writeCert(t, dir, ca, caKey, 3, time.Now().Add(90*24*time.Hour))
+	if s := serial(); s != "2" {
+		t.Fatalf("certificate was reloaded before the check interval: %s", s)
+	}
+	r.mu.Lock()
+	r.checked = time.Time{}
+	r.mu.Unlock()
+	if s := serial(); s != "3" {
+		t.Fatalf("certificate was not reloaded, server saw %s", s)
+	}
+
+	// Once the CA file no longer vouches for the server, it is rejected.
+	if err = ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Minute)
+	os.Chtimes(caFile, mtime, mtime)
+	r.mu.Lock()
+	r.checked = time.Time{}
+	r.mu.Unlock()
+	hc.CloseIdleConnections()
+	if _, err = hc.Get(srv.URL); err == nil {
+		t.Fatalf("server was accepted with the wrong CA")
+	}
+}
@@ -0,0 +1,85 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenClaims are the claims of a Cloud NGFW JWT.
+type TokenClaims struct {
+	Tenant        string `json:"tenant,omitempty"`
+	TenantVersion string `json:"tenant_version,omitempty"`
+	Region        string `json:"region,omitempty"`
+
+	// Permission is the permission the token was issued for, and Scopes
+	// the OAuth scopes it grants, if any.
+	Permission string           `json:"permission,omitempty"`
+	Scopes     jwt.ClaimStrings `json:"scope,omitempty"`
+
+	jwt.RegisteredClaims
+}
+
+// Expires returns when the token expires, or the zero time if it has no
+// exp claim.
+func (tc *TokenClaims) Expires() time.Time {
+	if tc.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return tc.ExpiresAt.Time
+}
+
+// expiresOr returns when the token expires, or fallback from now if it has
+// no exp claim.
+func (tc *TokenClaims) expiresOr(fallback time.Duration) time.Time {
+	if exp := tc.Expires(); !exp.IsZero() {
+		return exp
+	}
+	return time.Now().Add(fallback)
+}
+
+/*
+ParseToken returns the claims of a JWT.
+
+If the client has a JwksURL, the token's signature and expiry are verified
+against the keys published there. Otherwise the token is trusted as is.
+*/
+func (c *Client) ParseToken(tokenStr string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	var err error
+	if j := c.keySet(); j != nil {
+		_, err = jwt.ParseWithClaims(tokenStr, claims, j.Keyfunc, jwt.WithValidMethods(jwksMethods))
+	} else {
+		_, _, err = jwt.NewParser().ParseUnverified(tokenStr, claims)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Claims returns the claims of the token the client sends for the given
+// permission, fetching one if needed.
+func (c *Client) Claims(ctx context.Context, permission string) (*TokenClaims, error) {
+	tok, err := c.token(ctx, permission)
+	if err != nil {
+		return nil, err
+	}
+	if tok.Jwt == "" {
+		return nil, fmt.Errorf("no JWT for the %s permission", permission)
+	}
+	return c.ParseToken(tok.Jwt)
+}
+
+// keySet returns the JWKS of the client, or nil if it has no JwksURL.
+func (c *Client) keySet() *JWKS {
+	if c.JwksURL == "" {
+		return nil
+	}
+	c.jwksOnce.Do(func() {
+		c.jwks = NewJWKS(c.JwksURL, c.HttpClient)
+	})
+	return c.jwks
+}
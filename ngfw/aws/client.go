@@ -5,12 +5,8 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +14,8 @@ import (
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
 
 	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/metrics"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/response"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
@@ -26,6 +24,9 @@ import (
 )
 
 // Client is the client.
+//
+// Clients are best built by NewClient from a Config, which validates all of
+// the settings at once.
 type Client struct {
 	CognitoClient   *cognito.CognitoIdentityProvider
 	Tenant          string            `json:"tenant"`
@@ -54,23 +55,104 @@ type Client struct {
 
 	AuthType string `json:"-"`
 
+	// Cognito, if set, is used to log in instead of CognitoClient.
+	Cognito CognitoAPI `json:"-"`
+
+	// CognitoAuthFlow is CognitoFlowPassword (the default) or
+	// CognitoFlowSRP. ChallengeHandler answers the challenges Cognito may
+	// issue during log in, such as MFA or NEW_PASSWORD_REQUIRED.
+	CognitoAuthFlow  string                  `json:"cognito-auth-flow"`
+	ChallengeHandler CognitoChallengeHandler `json:"-"`
+
 	LfaArn       string `json:"lfa-arn"`
 	LraArn       string `json:"lra-arn"`
 	GraArn       string `json:"gra-arn"`
 	AcctAdminArn string `json:"account-admin-arn"`
 	Arn          string `json:"arn"`
 
+	// AssumeRole configures how the roles above are assumed. The options of
+	// a specific role, if set, are used instead.
+	AssumeRole          *AssumeRoleOptions `json:"assume-role"`
+	LfaAssumeRole       *AssumeRoleOptions `json:"lfa-assume-role"`
+	LraAssumeRole       *AssumeRoleOptions `json:"lra-assume-role"`
+	GraAssumeRole       *AssumeRoleOptions `json:"gra-assume-role"`
+	AcctAdminAssumeRole *AssumeRoleOptions `json:"account-admin-assume-role"`
+
+	// TokenCacheDir, if set, is where JWTs are cached across processes,
+	// encrypted with TokenCacheKey.
+	TokenCacheDir string `json:"token-cache-dir"`
+	TokenCacheKey string `json:"token-cache-key"`
+
+	// JwksURL, if set, is where the keys signing the JWTs are published.
+	// Tokens are then verified against them before being used.
+	JwksURL string `json:"jwks-url"`
+
+	// Endpoints overrides the hosts of regions, by region. Hosts that are
+	// not configured are derived from Region and MPRegion, see
+	// EndpointResolver.
+	Endpoints map[string]Endpoints `json:"endpoints"`
+
+	// AuthFile is a JSON or YAML file holding the settings below, either
+	// flat or in named contexts. Context selects the context to use.
 	AuthFile         string `json:"auth-file"`
+	Context          string `json:"-"`
 	CheckEnvironment bool   `json:"-"`
 
+	// Waiter controls how long running operations are polled. If nil, the
+	// DefaultWaiter is used, bounded by ResourceTimeout.
+	Waiter *Waiter `json:"-"`
+
+	// RetryPolicy controls retries of failed calls. If nil, the
+	// DefaultRetryPolicy is used, which retries GET calls.
+	RetryPolicy *RetryPolicy `json:"-"`
+
+	// RateLimits budgets the calls made with each permission (one of the
+	// Permission constants). Permissions without an entry are not limited.
+	// This must be configured before the first API call.
+	RateLimits map[string]RateLimit `json:"rate-limits"`
+
+	// ClientCertFile and ClientKeyFile, if set, hold the certificate used
+	// for mTLS with the external ID, and ClientCAFile the CAs the server is
+	// verified against. The files are reloaded when they change.
+	ClientCertFile string `json:"client-cert-file"`
+	ClientKeyFile  string `json:"client-key-file"`
+	ClientCAFile   string `json:"client-ca-file"`
+
 	SkipVerifyCertificate bool            `json:"skip-verify-certificate"`
 	Transport             *http.Transport `json:"-"`
 
+	// TokenProvider hands out the JWTs sent with each request. If nil, one
+	// is chosen based on AuthType. This must be set before the first API
+	// call.
+	TokenProvider TokenProvider `json:"-"`
+
+	// BackgroundTokenRefresh makes the TokenProvider chosen based on
+	// AuthType refresh its tokens ahead of expiry in the background, until
+	// Close is called or no call was made for TokenIdleTimeout. Otherwise
+	// tokens are fetched when needed.
+	BackgroundTokenRefresh bool `json:"-"`
+
+	// Middleware wraps the transport of every request the client sends,
+	// the first entry being the outermost. This must be set before Setup.
+	Middleware []Middleware `json:"-"`
+
+	// Metrics receives measurements of API calls, retries, token refreshes
+	// and waits. If nil, nothing is recorded.
+	Metrics metrics.Metrics `json:"-"`
+
+	// Logger receives all of the SDK's log output. If nil, the global
+	// api.Logger is used if set, or the standard library logger otherwise.
+	Logger logging.Logger `json:"-"`
+
 	// Various logging params.
 	Logging               uint32   `json:"-"`
 	LoggingFromInitialize []string `json:"logging"`
 
 	// Configured by Initialize().
+	//
+	// Deprecated: The JWT fields are copies of the tokens handed out by the
+	// TokenProvider, kept for code that still reads them. They are only
+	// used for requests when the client has no AuthType.
 	FirewallAdminJwt               string     `json:"-"`
 	FirewallAdminJwtExpTime        time.Time  `json:"-"`
 	FirewallSubscriptionKey        string     `json:"-"`
@@ -96,18 +178,28 @@ type Client struct {
 	apiPrefix   string
 	mpApiPrefix string
 	v2ApiPrefix string
+	limiter     *rateLimiter
+	limiterOnce sync.Once
+
+	tokenProvider TokenProvider
+	tokenOnce     sync.Once
+	iamSource     *iamRoleSource
+	iamOnce       sync.Once
+	jwks          *JWKS
+	jwksOnce      sync.Once
+	breaker       *authBreaker
+	breakerOnce   sync.Once
+	wrapped       map[*http.Client]*http.Client
+
+	// tenantMu guards TenantVersion, which token refreshes may set while
+	// requests are being sent.
+	tenantMu sync.RWMutex
 
 	// Initialized during Setup().
 	HttpClient       *http.Client
 	SecureHttpClient *http.Client
 	AuthURL          string
 
-	// Variables for testing.
-	testData        [][]byte
-	testErrors      []error
-	testIndex       int
-	authFileContent []byte
-
 	// Used for unit tests
 	Mock       bool
 	MockedResp func() ([]byte, error)
@@ -150,297 +242,32 @@ func (c *Client) Log(method, msg string, i ...interface{}) {
 		return
 	}
 
-	log.Printf("(%s) %s", strings.ToLower(method), fmt.Sprintf(msg, i...))
+	c.logger().Info(fmt.Sprintf(msg, i...), logging.F("method", strings.ToLower(method)))
 }
 
 // Setup configures the HttpClient param according to the combination of
 // locally defined params, environment variables, and the JSON config file.
+//
+// The settings are loaded with LoadConfig, so all the problems found are
+// returned at once in a ConfigError. New code should use NewClient.
 func (c *Client) Setup() error {
-	var err error
-	var tout time.Duration
-
-	// Load up the JSON config file.
-	json_client := &Client{}
-	if c.AuthFile != "" {
-		var b []byte
-		if len(c.testData) == 0 {
-			b, err = ioutil.ReadFile(c.AuthFile)
-		} else {
-			b, err = c.authFileContent, nil
-		}
-
-		if err != nil {
-			return err
-		}
-
-		if err = json.Unmarshal(b, &json_client); err != nil {
-			return err
-		}
-	}
-
-	// Host.
-	if c.Host == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_HOST"); c.CheckEnvironment && val != "" {
-			c.Host = val
-		} else if json_client.Host != "" {
-			c.Host = json_client.Host
-		}
-	}
-	if c.Host == "" {
-		c.Host = "api.us-east-1.aws.cloudngfw.paloaltonetworks.com"
-	}
-
-	if c.V2Host == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_V2_HOST"); c.CheckEnvironment && val != "" {
-			c.V2Host = val
-		} else if json_client.V2Host != "" {
-			c.V2Host = json_client.V2Host
-		}
-	}
-	if c.V2Host == "" {
-		c.V2Host = "api.us-east-1.aws.cloudngfw.paloaltonetworks.com"
-	}
-
-	// Host.
-	if c.MPRegionHost == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_MP_REGION_HOST"); c.CheckEnvironment && val != "" {
-			c.MPRegionHost = val
-		} else if json_client.MPRegionHost != "" {
-			c.MPRegionHost = json_client.MPRegionHost
-		}
-	}
-	if c.MPRegionHost == "" {
-		c.MPRegionHost = DefaultMPRegionHost
-	}
-
-	// Access key.
-	if c.AccessKey == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_ACCESS_KEY"); c.CheckEnvironment && val != "" {
-			c.AccessKey = val
-		} else if json_client.AccessKey != "" {
-			c.AccessKey = json_client.AccessKey
-		}
-	}
-
-	// Secret key.
-	if c.SecretKey == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_SECRET_KEY"); c.CheckEnvironment && val != "" {
-			c.SecretKey = val
-		} else if json_client.SecretKey != "" {
-			c.SecretKey = json_client.SecretKey
-		}
-	}
-
-	// Profile.
-	if c.Profile == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_PROFILE"); c.CheckEnvironment && val != "" {
-			c.Profile = val
-		} else if json_client.Profile != "" {
-			c.Profile = json_client.Profile
-		}
-	}
-
-	// SyncMode.
-	if c.SyncMode == false {
-		if val := os.Getenv("CLOUDNGFWAWS_SYNC_MODE"); c.CheckEnvironment && strings.ToLower(val) == "true" {
-			c.SyncMode = true
-		} else if json_client.SyncMode == true {
-			c.SyncMode = true
-		}
-	}
-
-	// LFA ARN.
-	if c.LfaArn == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_LFA_ARN"); c.CheckEnvironment && val != "" {
-			c.LfaArn = val
-		} else if json_client.LfaArn != "" {
-			c.LfaArn = json_client.LfaArn
-		}
-	}
-
-	// LRA ARN.
-	if c.LraArn == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_LRA_ARN"); c.CheckEnvironment && val != "" {
-			c.LraArn = val
-		} else if json_client.LraArn != "" {
-			c.LraArn = json_client.LraArn
-		}
-	}
-
-	// GRA ARN.
-	if c.GraArn == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_GRA_ARN"); c.CheckEnvironment && val != "" {
-			c.GraArn = val
-		} else if json_client.GraArn != "" {
-			c.GraArn = json_client.GraArn
-		}
-	}
-
-	// Account Admin ARN.
-	if c.AcctAdminArn == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_ACCT_ADMIN_ARN"); c.CheckEnvironment && val != "" {
-			c.AcctAdminArn = val
-		} else if json_client.AcctAdminArn != "" {
-			c.AcctAdminArn = json_client.AcctAdminArn
-		}
-	}
-
-	// ARN.
-	if c.Arn == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_ARN"); c.CheckEnvironment && val != "" {
-			c.Arn = val
-		} else if json_client.Arn != "" {
-			c.Arn = json_client.Arn
-		}
-	}
-
-	// Region.
-	if c.Region == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_REGION"); c.CheckEnvironment && val != "" {
-			c.Region = val
-		} else if json_client.Region != "" {
-			c.Region = json_client.Region
-		} else {
-			return fmt.Errorf("No region was specified")
-		}
-	}
-
-	// MP Region.
-	if c.MPRegion == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_MP_REGION"); c.CheckEnvironment && val != "" {
-			c.MPRegion = val
-		} else if json_client.MPRegion != "" {
-			c.MPRegion = json_client.MPRegion
-		}
-	}
-
-	if c.MPRegion == "" {
-		c.MPRegion = DefaultMPRegion
-	}
-
-	// Protocol.
-	if c.Protocol == "" {
-		if val := os.Getenv("CLOUDNGFWAWS_PROTOCOL"); c.CheckEnvironment && val != "" {
-			c.Protocol = val
-		} else if json_client.Protocol != "" {
-			c.Protocol = json_client.Protocol
-		} else {
-			c.Protocol = "https"
-		}
-	}
-	if c.Protocol != "http" && c.Protocol != "https" {
-		return fmt.Errorf("Invalid protocol %q; expected 'https' or 'http'", c.Protocol)
-	}
-
-	// Timeout.
-	if c.Timeout == 0 {
-		if val := os.Getenv("CLOUDNGFWAWS_TIMEOUT"); c.CheckEnvironment && val != "" {
-			if ival, err := strconv.Atoi(val); err != nil {
-				return fmt.Errorf("Failed to parse timeout env var as int: %s", err)
-			} else {
-				c.Timeout = ival
-			}
-		} else if json_client.Timeout != 0 {
-			c.Timeout = json_client.Timeout
-		} else {
-			c.Timeout = 30
-		}
-	}
-	if c.Timeout <= 0 {
-		return fmt.Errorf("Timeout for %q must be a positive int", c.Host)
-	}
-	tout = time.Duration(time.Duration(c.Timeout) * time.Second)
-
-	// Resource Timeout.
-	if c.ResourceTimeout == 0 {
-		if val := os.Getenv("CLOUDNGFWAWS_RESOURCE_TIMEOUT"); c.CheckEnvironment && val != "" {
-			if ival, err := strconv.Atoi(val); err != nil {
-				return fmt.Errorf("Failed to parse resource timeout env var as int: %s", err)
-			} else {
-				c.ResourceTimeout = ival
-			}
-		} else if json_client.ResourceTimeout != 0 {
-			c.ResourceTimeout = json_client.ResourceTimeout
-		} else {
-			c.ResourceTimeout = 7200
-		}
-	}
-	if c.ResourceTimeout <= 0 {
-		return fmt.Errorf("Resource Timeout must be a positive int")
-	}
-
-	// Headers.
-	if len(c.Headers) == 0 {
-		if val := os.Getenv("CLOUDNGFWAWS_HEADERS"); c.CheckEnvironment && val != "" {
-			if err := json.Unmarshal([]byte(val), &c.Headers); err != nil {
-				return err
-			}
-		}
-		if len(c.Headers) == 0 && len(json_client.Headers) > 0 {
-			c.Headers = make(map[string]string)
-			for k, v := range json_client.Headers {
-				c.Headers[k] = v
-			}
-		}
+	cfg, err := LoadConfig(c.config())
+	if err != nil {
+		return err
 	}
+	_, err = clientFromConfig(cfg, c)
+	return err
+}
 
-	// Verify cert.
-	if !c.SkipVerifyCertificate {
-		if val := os.Getenv("CLOUDNGFWAWS_SKIP_VERIFY_CERTIFICATE"); c.CheckEnvironment && val != "" {
-			if vcb, err := strconv.ParseBool(val); err != nil {
-				return err
-			} else if vcb {
-				c.SkipVerifyCertificate = vcb
-			}
-		}
-		if !c.SkipVerifyCertificate && json_client.SkipVerifyCertificate {
-			c.SkipVerifyCertificate = json_client.SkipVerifyCertificate
-		}
-	}
+// setupRuntime builds the HTTP clients and URL prefixes from the settings,
+// which must already be loaded.
+func (c *Client) setupRuntime() error {
+	var err error
 
-	// Logging.
-	if c.Logging == 0 {
-		var ll []string
-		if val := os.Getenv("CLOUDNGFWAWS_LOGGING"); c.CheckEnvironment && val != "" {
-			ll = strings.Split(val, ",")
-		} else {
-			ll = json_client.LoggingFromInitialize
-		}
-		if len(ll) > 0 {
-			var lv uint32
-			for _, x := range ll {
-				switch x {
-				case "quiet":
-					lv |= awsngfw.LogQuiet
-				case "login":
-					lv |= awsngfw.LogLogin
-				case "get":
-					lv |= awsngfw.LogGet
-				case "patch":
-					lv |= awsngfw.LogPatch
-				case "post":
-					lv |= awsngfw.LogPost
-				case "put":
-					lv |= awsngfw.LogPut
-				case "delete":
-					lv |= awsngfw.LogDelete
-				case "action":
-					lv |= awsngfw.LogPatch | awsngfw.LogPost | awsngfw.LogPut | awsngfw.LogDelete
-				case "path":
-					lv |= awsngfw.LogPath
-				case "send":
-					lv |= awsngfw.LogSend
-				case "receive":
-					lv |= awsngfw.LogReceive
-				default:
-					return fmt.Errorf("Unknown logging requested: %s", x)
-				}
-			}
-			c.Logging = lv
-		} else {
-			c.Logging = awsngfw.LogLogin | awsngfw.LogGet | awsngfw.LogAction
-		}
+	if err = c.resolveHosts(); err != nil {
+		return err
 	}
+	tout := time.Duration(c.Timeout) * time.Second
 
 	// Setup the https client.
 	if c.Transport == nil {
@@ -455,6 +282,13 @@ func (c *Client) Setup() error {
 		Transport: c.Transport,
 		Timeout:   tout,
 	}
+	if c.SecureHttpClient == nil && c.ClientCertFile != "" {
+		if c.SecureHttpClient, err = c.secureHttpClient(); err != nil {
+			return err
+		}
+	}
+
+	c.setupMiddleware()
 
 	// Configure the uri prefix.
 	c.apiPrefix = fmt.Sprintf("%s://%s", c.Protocol, c.Host)
@@ -463,6 +297,41 @@ func (c *Client) Setup() error {
 	return nil
 }
 
+// parseLogging turns the names of log categories into the awsngfw.Log*
+// flags.
+func parseLogging(ll []string) (uint32, error) {
+	var lv uint32
+	for _, x := range ll {
+		switch x {
+		case "quiet":
+			lv |= awsngfw.LogQuiet
+		case "login":
+			lv |= awsngfw.LogLogin
+		case "get":
+			lv |= awsngfw.LogGet
+		case "patch":
+			lv |= awsngfw.LogPatch
+		case "post":
+			lv |= awsngfw.LogPost
+		case "put":
+			lv |= awsngfw.LogPut
+		case "delete":
+			lv |= awsngfw.LogDelete
+		case "action":
+			lv |= awsngfw.LogPatch | awsngfw.LogPost | awsngfw.LogPut | awsngfw.LogDelete
+		case "path":
+			lv |= awsngfw.LogPath
+		case "send":
+			lv |= awsngfw.LogSend
+		case "receive":
+			lv |= awsngfw.LogReceive
+		default:
+			return 0, fmt.Errorf("Unknown logging requested: %s", x)
+		}
+	}
+	return lv, nil
+}
+
 // Path holds the V1 and V2 API paths for a given resource.
 type Path struct {
 	V1Path []string
@@ -512,11 +381,10 @@ be ignored.
 
 This function returns the content of the body from the API call and any errors
 that may have been present.  If this function got all the way to invoking the
-API and getting a response, then the error passed back will be a `api.Status`
-if an error was detected.
+API and getting a response, then the error passed back will be a
+`*response.APIError` if an error was detected.
 */
 func (c *Client) Communicate(ctx context.Context, auth, method string, path Path, queryParams url.Values, input interface{}, output response.Failure, creds ...*sts.Credentials) (s []byte, e error) {
-	log.Printf("path:%s", path)
 	// Sanity check the input.
 	if len(creds) > 1 {
 		return nil, fmt.Errorf("[tenant:%s][region:%s] Only one credentials is allowed",
@@ -535,8 +403,12 @@ func (c *Client) Communicate(ctx context.Context, auth, method string, path Path
 		}
 	}
 
+	rlog := c.logger().With(
+		logging.F("permission", auth),
+		logging.F("method", method),
+	)
 	if c.Logging&awsngfw.LogSend == awsngfw.LogSend {
-		log.Printf("sending: %s", data)
+		rlog.Info("sending", logging.F("body", data))
 	}
 
 	// Create the API request.
@@ -549,7 +421,7 @@ func (c *Client) Communicate(ctx context.Context, auth, method string, path Path
 		apiPrefix = c.mpApiPrefix
 	}
 	if c.Logging&awsngfw.LogPath == awsngfw.LogPath {
-		log.Printf("path: %s/%s%s", apiPrefix, strings.Join(path.V1Path, "/"), qp)
+		rlog.Info("path", logging.F("url", fmt.Sprintf("%s/%s%s", apiPrefix, strings.Join(path.V1Path, "/"), qp)))
 	}
 	req, err := http.NewRequestWithContext(
 		ctx,
@@ -567,154 +439,75 @@ func (c *Client) Communicate(ctx context.Context, auth, method string, path Path
 	}
 
 	// Configure standard headers.
-	permErr := "[tenant:%s][region:%s]This connection does not have the required JWT:%s err:%s"
+	permErr := "[tenant:%s][region:%s]This connection does not have the required JWT:%s err:%w"
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", c.Agent)
 	switch auth {
 	case "", PermissionAccountAdminJWT:
-	case PermissionFirewall:
-		switch c.AuthType {
-		case AuthTypeIAMRole:
-			err := c.RefreshFirewallAdminJwt(ctx)
-			if err != nil {
-				return nil, fmt.Errorf(permErr, c.ExternalID, c.Region, PermissionFirewall, err)
-			}
-			req.Header.Set("Authorization", c.FirewallAdminJwt)
-			req.Header.Set("x-api-key", c.FirewallSubscriptionKey)
-		case AuthTypeExternalID:
-			err := c.RefreshCloudRulestackAdminJwt(ctx)
-			if err != nil {
-				return nil, fmt.Errorf(permErr, c.ExternalID, c.Region, PermissionFirewall, err)
-			}
-			req.Header.Set("Authorization", c.CloudRulestackAdminJwt)
-			req.Header.Set("x-api-key", c.CloudRulestackSubscriptionKey)
-		default:
-			req.Header.Set("Authorization", c.FirewallAdminJwt)
-			req.Header.Set("x-api-key", c.FirewallSubscriptionKey)
-		}
-	case PermissionRulestack:
-		switch c.AuthType {
-		case AuthTypeIAMRole:
-			err := c.RefreshRulestackAdminJwt(ctx)
-			if err != nil {
-				return nil, fmt.Errorf(permErr, c.ExternalID, c.Region, PermissionRulestack, err)
-			}
-			req.Header.Set("Authorization", c.RulestackAdminJwt)
-			req.Header.Set("x-api-key", c.RulestackSubscriptionKey)
-		case AuthTypeExternalID:
-			err := c.RefreshCloudRulestackAdminJwt(ctx)
-			if err != nil {
-				return nil, fmt.Errorf(permErr, c.ExternalID, c.Region, PermissionRulestack, err)
-			}
-			req.Header.Set("Authorization", c.CloudRulestackAdminJwt)
-			req.Header.Set("x-api-key", c.CloudRulestackSubscriptionKey)
-		default:
-			req.Header.Set("Authorization", c.RulestackAdminJwt)
-			req.Header.Set("x-api-key", c.RulestackSubscriptionKey)
-		}
-	case PermissionGlobalRulestack:
-		switch c.AuthType {
-		case AuthTypeIAMRole:
-			err := c.RefreshGlobalRulestackAdminJwt(ctx)
-			if err != nil {
-				return nil, fmt.Errorf(permErr, c.ExternalID, c.Region, PermissionGlobalRulestack, err)
-			}
-			req.Header.Set("Authorization", c.GlobalRulestackAdminJwt)
-			req.Header.Set("x-api-key", c.GlobalRulestackSubscriptionKey)
-		case AuthTypeExternalID:
-			err := c.RefreshCloudRulestackAdminJwt(ctx)
-			if err != nil {
-				return nil, fmt.Errorf(permErr, c.ExternalID, c.Region, PermissionGlobalRulestack, err)
-			}
-			req.Header.Set("Authorization", c.CloudRulestackAdminJwt)
-			req.Header.Set("x-api-key", c.CloudRulestackSubscriptionKey)
-		default:
-			req.Header.Set("Authorization", c.GlobalRulestackAdminJwt)
-			req.Header.Set("x-api-key", c.GlobalRulestackSubscriptionKey)
-		}
-	case PermissionAccount:
-		switch c.AuthType {
-		case AuthTypeIAMRole:
-			err := c.RefreshAccountAdminJwt(ctx)
-			if err != nil {
-				return nil, fmt.Errorf(permErr, c.ExternalID, c.MPRegion, PermissionAccount, err)
+	case PermissionFirewall, PermissionRulestack, PermissionGlobalRulestack, PermissionAccount:
+		tok, err := c.token(ctx, auth)
+		if err != nil {
+			region := c.Region
+			if auth == PermissionAccount {
+				region = c.MPRegion
 			}
-			req.Header.Set("Authorization", c.AccountAdminJwt)
-			req.Header.Set("x-api-key", c.AccountAdminSubscriptionKey)
-		default:
-			req.Header.Set("Authorization", c.AccountAdminJwt)
-			req.Header.Set("x-api-key", c.AccountAdminSubscriptionKey)
+			return nil, fmt.Errorf(permErr, c.ExternalID, region, auth, err)
 		}
+		req.Header.Set("Authorization", tok.Jwt)
+		req.Header.Set("x-api-key", tok.SubscriptionKey)
 	default:
 		return nil, fmt.Errorf("[tenant:%s][region:%s] Unknown permission required: %q",
 			c.ExternalID, c.MPRegion, auth)
 	}
-	api.Logger.Debugf("SDK Path: %v", path)
 
-	if c.TenantVersion == awsngfw.TenantVersionV2 && path.V2Path != nil {
+	tenantVersion := c.tenantVersion()
+	if tenantVersion == awsngfw.TenantVersionV2 && path.V2Path != nil {
 		if err := setV2Path(c, path.V2Path, req, queryParams); err != nil {
 			return nil, err
 		}
 	}
-	if c.TenantVersion == TenantVersionV1 && queryParams.Has("v1route") {
+	if tenantVersion == TenantVersionV1 && queryParams.Has("v1route") {
 		queryParams.Del("v1route")
 	}
-	api.Logger.Debugf("SDK Request URL: %s", req.URL.String())
+	rlog = rlog.With(logging.F("path", req.URL.Path))
 
 	// Optional: v4 sign the request.
+	var sign func(*http.Request) error
 	if len(creds) == 1 {
-		prov := provider{
-			Value: credentials.Value{
-				AccessKeyID:     *creds[0].AccessKeyId,
-				SecretAccessKey: *creds[0].SecretAccessKey,
-				SessionToken:    *creds[0].SessionToken,
-			},
-		}
-		signer := v4.NewSigner(credentials.NewCredentials(prov))
 		region := c.Region
 		if auth == PermissionAccountAdminJWT || auth == PermissionAccount {
 			region = c.MPRegion
 		}
-		_, err = signer.Sign(req, strings.NewReader(string(data)), "execute-api", region, time.Now())
-		if err != nil {
-			return nil, err
-		}
+		sign = v4Signer(creds[0], region, data)
 	}
 
 	// Perform the API action.
-	if len(c.testData) > 0 {
-		body = []byte(`{"test"}`)
-	} else {
-		resp, err := c.HttpClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-		body, err = ioutil.ReadAll(resp.Body)
-
-		if resp.StatusCode >= http.StatusBadRequest {
-			if err == nil {
-				log.Printf("body: %s", string(body))
-			}
-			log.Printf("http status: %s code: %d",
-				resp.Status, resp.StatusCode)
-			return nil, fmt.Errorf("API request failed with status code %d",
-				resp.StatusCode)
-		}
-	}
-
+	start := time.Now()
+	resp, body, err := c.do(withPermission(ctx, auth), c.HttpClient, req, data, sign, c.isIdempotent(method, data))
 	if err != nil {
+		rlog.Error("api call failed", logging.F("latency", time.Since(start)), logging.F("error", err))
 		return nil, err
 	}
+	rlog = rlog.With(
+		logging.F("status", resp.StatusCode),
+		logging.F("latency", time.Since(start)),
+		logging.F("request_id", resp.Header.Get("x-amzn-RequestId")),
+	)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		rlog.Warn("api call failed", logging.F("body", body))
+		return body, response.NewAPIError(resp, body)
+	}
+	rlog.Debug("api call")
 
 	// Log the response.
 	if c.Logging&awsngfw.LogReceive == awsngfw.LogReceive {
-		log.Printf("received: %s", body)
+		rlog.Info("received", logging.F("body", body))
 	}
 
 	// Check for unknown path error first.
 	if err := response.IsResponseWithError(body); err != nil {
-		return body, err
+		return body, response.FromStatus(resp, err.(*response.Status))
 	}
 
 	// Check for errors and unmarshal the response into the given interface.
@@ -727,7 +520,7 @@ func (c *Client) Communicate(ctx context.Context, auth, method string, path Path
 
 		if e2 := output.Failed(); e2 != nil {
 			c.Log(method, "Error unmarshaling response output: %v", e2)
-			return body, e2
+			return body, response.FromStatus(resp, e2)
 		}
 	} else {
 		c.Log(method, "generic response")
@@ -737,7 +530,7 @@ func (c *Client) Communicate(ctx context.Context, auth, method string, path Path
 		}
 
 		if e2 := generic.Failed(); e2 != nil {
-			return body, e2
+			return body, response.FromStatus(resp, e2)
 		}
 	}
 
@@ -762,7 +555,7 @@ func (c *Client) RequestJwt(ctx context.Context, method string, path []string, q
 		}
 	}
 	if c.Logging&awsngfw.LogSend == awsngfw.LogSend {
-		log.Printf("sending: %s", data)
+		c.logger().Info("sending", logging.F("body", data))
 	}
 
 	// Create the API request.
@@ -771,7 +564,7 @@ func (c *Client) RequestJwt(ctx context.Context, method string, path []string, q
 		qp = fmt.Sprintf("?%s", queryParams.Encode())
 	}
 	if c.Logging&awsngfw.LogPath == awsngfw.LogPath {
-		log.Printf("path: %s/%s%s", c.apiPrefix, strings.Join(path, "/"), qp)
+		c.logger().Info("path", logging.F("url", fmt.Sprintf("%s/%s%s", c.apiPrefix, strings.Join(path, "/"), qp)))
 	}
 	req, err := http.NewRequestWithContext(
 		ctx,
@@ -793,46 +586,31 @@ func (c *Client) RequestJwt(ctx context.Context, method string, path []string, q
 	req.Header.Set("User-Agent", c.Agent)
 
 	// v4 sign the request.
+	var sign func(*http.Request) error
 	if len(creds) == 1 {
-		prov := provider{
-			Value: credentials.Value{
-				AccessKeyID:     *creds[0].AccessKeyId,
-				SecretAccessKey: *creds[0].SecretAccessKey,
-				SessionToken:    *creds[0].SessionToken,
-			},
-		}
-		signer := v4.NewSigner(credentials.NewCredentials(prov))
-		_, err = signer.Sign(req, strings.NewReader(string(data)), "execute-api", c.Region, time.Now())
-		if err != nil {
-			return nil, err
-		}
+		sign = v4Signer(creds[0], c.Region, data)
 	}
 
-	var resp *http.Response
-	// Perform the API action.
-	if len(c.testData) > 0 {
-		body = []byte(`{"test"}`)
-	} else {
-		resp, err = c.HttpClient.Do(req)
-		if err != nil {
-			return nil, err
-		}
-		defer resp.Body.Close()
-		body, err = ioutil.ReadAll(resp.Body)
-	}
+	// Perform the API action. Fetching a JWT has no side effects, so it is
+	// always retried.
+	resp, body, err := c.do(ctx, c.HttpClient, req, data, sign, true)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+		return body, response.NewAPIError(resp, body)
+	}
+
 	// Log the response.
 	if c.Logging&awsngfw.LogReceive == awsngfw.LogReceive {
-		log.Printf("received: %s", body)
+		c.logger().Info("received", logging.F("body", body))
 	}
 
 	// Check for unknown path error first.
 	if err := response.IsResponseWithError(body); err != nil {
-		return body, err
+		return body, response.FromStatus(resp, err.(*response.Status))
 	}
 
 	// Check for errors and unmarshal the response into the given interface.
@@ -842,7 +620,7 @@ func (c *Client) RequestJwt(ctx context.Context, method string, path []string, q
 		}
 
 		if e2 := output.Failed(); e2 != nil {
-			return body, e2
+			return body, response.FromStatus(resp, e2)
 		}
 	} else {
 		var generic Response
@@ -851,17 +629,53 @@ func (c *Client) RequestJwt(ctx context.Context, method string, path []string, q
 		}
 
 		if e2 := generic.Failed(); e2 != nil {
-			return body, e2
+			return body, response.FromStatus(resp, e2)
 		}
 	}
 
 	return body, nil
 }
 
+// v4Signer returns a func that v4 signs a request with the given credentials.
+func v4Signer(creds *sts.Credentials, region string, data []byte) func(*http.Request) error {
+	prov := provider{
+		Value: credentials.Value{
+			AccessKeyID:     *creds.AccessKeyId,
+			SecretAccessKey: *creds.SecretAccessKey,
+			SessionToken:    *creds.SessionToken,
+		},
+	}
+	signer := v4.NewSigner(credentials.NewCredentials(prov))
+	return func(req *http.Request) error {
+		_, err := signer.Sign(req, strings.NewReader(string(data)), "execute-api", region, time.Now())
+		return err
+	}
+}
+
+// SetEndpoint sets the URLs used by the client. Those left empty are
+// resolved from the client's Region. The v2 URL is derived from the auth
+// URL, unless the Endpoints of the Region override its V2Host.
 func (c *Client) SetEndpoint(ctx context.Context, input api.EndPointInput) error {
+	v2URL := strings.ReplaceAll(input.ApiAuthEndpoint, "-auth", "")
+	v2Override := c.Endpoints[c.Region].V2Host != ""
+	if input.ApiEndpoint == "" || input.ApiAuthEndpoint == "" || v2Override {
+		regionURL, regionV2URL, authURL, err := c.regionURLs(c.Region)
+		if err != nil {
+			return err
+		}
+		if input.ApiEndpoint == "" {
+			input.ApiEndpoint = regionURL
+		}
+		if input.ApiAuthEndpoint == "" || v2Override {
+			v2URL = regionV2URL
+		}
+		if input.ApiAuthEndpoint == "" {
+			input.ApiAuthEndpoint = authURL
+		}
+	}
 	c.apiPrefix = input.ApiEndpoint
 	c.AuthURL = input.ApiAuthEndpoint
-	c.v2ApiPrefix = strings.ReplaceAll(input.ApiAuthEndpoint, "-auth", "")
+	c.v2ApiPrefix = v2URL
 	return nil
 }
 
@@ -0,0 +1,267 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/prefix"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/response"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/security"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+var setLogger sync.Once
+
+func testContext() context.Context {
+	setLogger.Do(func() {
+		api.SetLogger(zap.NewNop().Sugar())
+	})
+	return context.WithValue(context.Background(), "SchemaVersion", awsngfw.SchemaVersionV2)
+}
+
+func newExternalIDClient(t *testing.T, srv *ngfwtest.Server) *Client {
+	t.Helper()
+
+	c := &Client{AuthType: AuthTypeExternalID}
+	err := c.SetupUsingCreds(context.Background(), AuthInfo{
+		ExternalID:       "ext-1234",
+		Region:           ngfwtest.DefaultRegion,
+		HttpClient:       srv.Client(),
+		SecureHttpClient: srv.Client(),
+		RegionURL:        srv.URL,
+		RegionV2URL:      srv.URL,
+		AuthURL:          srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// fakeTest is a test run against a fake server of its own, through an
+// external ID client.
+type fakeTest struct {
+	name string
+
+	// server, if set, configures the server before the client is set up.
+	server func(srv *ngfwtest.Server)
+
+	run func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client)
+}
+
+// runAgainstFake runs each of the tests as a subtest.
+func runAgainstFake(t *testing.T, tests []fakeTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := ngfwtest.NewServer()
+			defer srv.Close()
+			if tt.server != nil {
+				tt.server(srv)
+			}
+			tt.run(t, testContext(), srv, newExternalIDClient(t, srv))
+		})
+	}
+}
+
+func TestClientAgainstFake(t *testing.T) {
+	runAgainstFake(t, []fakeTest{
+		{
+			name: "workflow",
+			run: func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client) {
+				rs := stack.Info{
+					Name: "rs1",
+					Entry: stack.Details{
+						Scope:       LocalScope,
+						Description: "workflow",
+					},
+				}
+				if err := c.CreateRuleStack(ctx, rs); err != nil {
+					t.Fatalf("create rulestack: %s", err)
+				}
+				pl := prefix.Info{
+					Rulestack:  "rs1",
+					Name:       "pl1",
+					PrefixList: []string{"10.0.0.0/8"},
+				}
+				if err := c.CreatePrefixList(ctx, pl); err != nil {
+					t.Fatalf("create prefix list: %s", err)
+				}
+				rule := security.Info{
+					Rulestack: "rs1",
+					RuleList:  security.LOCAL_RULE,
+					Priority:  10,
+					Entry: security.Details{
+						Name:         "allow-internal",
+						Enabled:      true,
+						Source:       security.SourceDetails{PrefixLists: []string{"pl1"}},
+						Destination:  security.DestinationDetails{Cidrs: []string{"any"}},
+						Applications: []string{"any"},
+						Action:       "Allow",
+					},
+				}
+				if err := c.CreateSecurityRule(ctx, rule); err != nil {
+					t.Fatalf("create security rule: %s", err)
+				}
+
+				si := stack.SimpleInput{Name: "rs1"}
+				if err := c.CommitRuleStack(ctx, si); err != nil {
+					t.Fatalf("commit: %s", err)
+				}
+				status, err := c.PollCommitRuleStack(ctx, si)
+				if err != nil {
+					t.Fatalf("poll commit: %s", err)
+				}
+				if status.Response.CommitStatus != api.RsCommitStatusSuccess {
+					t.Fatalf("commit status is %q", status.Response.CommitStatus)
+				}
+
+				fw, err := c.CreateFirewallWithWait(ctx, firewall.Info{
+					Name:      "fw1",
+					AccountId: "123456789012",
+					VpcId:     "vpc-1",
+				})
+				if err != nil {
+					t.Fatalf("create firewall: %s", err)
+				}
+				err = c.AssociateRulestackWithWait(ctx, firewall.AssociateInput{
+					Firewall:   "fw1",
+					FirewallId: fw.Response.Id,
+					Rulestack:  "rs1",
+					AccountId:  "123456789012",
+				})
+				if err != nil {
+					t.Fatalf("associate: %s", err)
+				}
+
+				ans, err := c.ReadFirewall(ctx, firewall.ReadInput{FirewallId: fw.Response.Id})
+				if err != nil {
+					t.Fatalf("read firewall: %s", err)
+				}
+				if ans.Response.Firewall.Rulestack != "rs1" {
+					t.Fatalf("firewall rulestack is %q", ans.Response.Firewall.Rulestack)
+				}
+				if c.TenantVersion != ngfwtest.DefaultTenantVersion {
+					t.Fatalf("tenant version is %q", c.TenantVersion)
+				}
+
+				var auths, v2 int
+				for _, req := range srv.Requests() {
+					if req.Path == "/v1/mgmt/tokens/cloudmanager" {
+						auths++
+					}
+					if req.Path == "/v2/config/ngfirewalls/"+fw.Response.Id && req.Query.Get("region") == ngfwtest.DefaultRegion {
+						v2++
+					}
+				}
+				if auths != 1 {
+					t.Fatalf("expected the JWT to be fetched once, got %d", auths)
+				}
+				if v2 == 0 {
+					t.Fatalf("no v2 firewall reads with a region were seen")
+				}
+			},
+		},
+		{
+			name: "errors",
+			run: func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client) {
+				_, err := c.ReadRuleStack(ctx, stack.ReadInput{Name: "missing"})
+				if !errors.Is(err, response.ErrNotFound) {
+					t.Fatalf("expected ErrNotFound, got %v", err)
+				}
+				var apiErr *response.APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("expected an APIError, got %T", err)
+				}
+				if apiErr.StatusCode != http.StatusNotFound || apiErr.RequestID == "" || apiErr.Method != http.MethodGet {
+					t.Fatalf("unexpected error details: %#v", apiErr)
+				}
+				if status := apiErr.Failed(); status == nil || status.Reason != apiErr.Reason {
+					t.Fatalf("Failed() does not carry the reason: %v", status)
+				}
+
+				if err = c.CreateRuleStack(ctx, stack.Info{Name: "rs1"}); err != nil {
+					t.Fatal(err)
+				}
+				err = c.UpdateRuleStack(ctx, stack.Info{Name: "rs1", Entry: stack.Details{UpdateToken: "stale"}})
+				if !errors.Is(err, response.ErrConflict) {
+					t.Fatalf("expected ErrConflict, got %v", err)
+				}
+				if errors.Is(err, response.ErrNotFound) {
+					t.Fatalf("a conflict should not match ErrNotFound")
+				}
+			},
+		},
+		{
+			name: "paginator",
+			run: func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client) {
+				client := api.NewAPIClient(c, ctx, 1, "", false)
+
+				for _, name := range []string{"rs1", "rs2", "rs3", "rs4", "rs5"} {
+					if err := c.CreateRuleStack(ctx, stack.Info{Name: name}); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				pages, err := client.NewRuleStackPaginator(stack.ListInput{MaxResults: 2}, api.WithPrefetch()).All(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var names []string
+				for _, page := range pages {
+					names = append(names, page.Response.Candidates...)
+				}
+				if len(pages) != 3 || len(names) != 5 {
+					t.Fatalf("expected 5 rulestacks over 3 pages, got %d over %d pages", len(names), len(pages))
+				}
+
+				var seen int
+				p := client.NewRuleStackPaginator(stack.ListInput{MaxResults: 2}, api.WithPrefetch())
+				err = p.Pages(ctx, func(stack.ListOutput) bool {
+					seen++
+					return false
+				})
+				if err != nil || seen != 1 || p.HasMorePages() {
+					t.Fatalf("early stop: err=%v seen=%d more=%t", err, seen, p.HasMorePages())
+				}
+			},
+		},
+	})
+}
+
+func TestSetupAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	c := &Client{
+		Host:     srv.Host(),
+		V2Host:   srv.Host(),
+		Protocol: "http",
+		Region:   ngfwtest.DefaultRegion,
+	}
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	c.TenantVersion = awsngfw.TenantVersionV2
+	c.FirewallAdminJwt, c.FirewallSubscriptionKey = srv.Token(ngfwtest.PermFirewall)
+	c.RulestackAdminJwt, c.RulestackSubscriptionKey = srv.Token(ngfwtest.PermRulestack)
+
+	if _, err := c.ListFirewall(ctx, firewall.ListInput{}); err != nil {
+		t.Fatalf("list firewalls: %s", err)
+	}
+	if err := c.CreateRuleStack(ctx, stack.Info{Name: "rs1"}); err != nil {
+		t.Fatalf("create rulestack: %s", err)
+	}
+	if _, err := c.ListRuleStack(ctx, stack.ListInput{Scope: GlobalScope}); err == nil {
+		t.Fatalf("listing global rulestacks with a local rulestack token should fail")
+	}
+}
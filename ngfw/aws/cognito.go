@@ -4,26 +4,34 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/response"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
 
-	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	cognito "github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 const (
 	flowUsernamePassword = "USER_PASSWORD_AUTH"
+
+	// Cognito auth flows.
+	CognitoFlowPassword = flowUsernamePassword
+	CognitoFlowSRP      = "USER_SRP_AUTH"
+
 	AuthEndpoint         = "v1/mgmt/tokens/cloudmanager"
 	PanoramaEndpoint     = "v1/mgmt/cloudservicetokens/panorama"
 	DefaultExpiryTime    = 30 * time.Minute
 	MaxBackoffTime       = 10 * time.Minute
+
+	// TokenIdleTimeout stops the background token refreshes of a client
+	// that made no call for that long.
+	TokenIdleTimeout = time.Hour
 )
 
 // externalID based auth over mTLS
@@ -35,123 +43,333 @@ type AuthInfo struct {
 	HttpClient       *http.Client
 	SecureHttpClient *http.Client
 	Region           string
-	RegionURL        string
-	AuthURL          string
-	RegionV2URL      string
+
+	// The URLs left empty are resolved from Region.
+	RegionURL   string
+	AuthURL     string
+	RegionV2URL string
 }
 
-// Setup configures the HttpClient param according to the combination of
-// locally defined params, environment variables, and the JSON config file.
+/*
+SetupUsingCredentials configures the client to log in to Cognito with its
+UserName and Password, using its Cognito or CognitoClient and its
+CognitoAuthFlow.
+
+The first token is fetched before returning. It is refreshed when needed,
+or in the background with BackgroundTokenRefresh.
+*/
 func (c *Client) SetupUsingCredentials(regionURL string, httpClient *http.Client) error {
-	var wg sync.WaitGroup
 	c.HttpClient = httpClient
+	c.setupMiddleware()
 	// Configure the uri prefix.
 	c.apiPrefix = regionURL
-	go func() {
-		for {
-			wg.Add(1)
-			flow := aws.String(flowUsernamePassword)
-			params := map[string]*string{
-				"USERNAME": aws.String(c.UserName),
-				"PASSWORD": aws.String(c.Password),
-			}
-
-			authTry := &cognito.InitiateAuthInput{
-				AuthFlow:       flow,
-				AuthParameters: params,
-				ClientId:       aws.String(c.AppClientID),
-			}
-
-			res, err := c.CognitoClient.InitiateAuth(authTry)
-			if err != nil {
-				log.Printf("error occured in refreshing token:%+v", err)
-				wg.Done()
-				continue
-			}
-
-			c.FirewallAdminMutex.Lock()
-			c.FirewallAdminJwt = *res.AuthenticationResult.IdToken
-			c.FirewallAdminMutex.Unlock()
-			c.RulestackAdminMutex.Lock()
-			c.RulestackAdminJwt = *res.AuthenticationResult.IdToken
-			c.RulestackAdminMutex.Unlock()
-			c.GlobalRulestackAdminMutex.Lock()
-			c.GlobalRulestackAdminJwt = *res.AuthenticationResult.IdToken
-			c.GlobalRulestackAdminMutex.Unlock()
-			wg.Done()
-			log.Printf("refreshed token.")
-			<-time.After(8 * time.Minute)
-		}
-	}()
-	log.Printf("waiting for token intialization")
-	<-time.After(1 * time.Second)
-	wg.Wait()
-	log.Printf("done")
+	c.AuthType = AuthTypeCognito
+
+	for _, perm := range []string{PermissionFirewall, PermissionRulestack, PermissionGlobalRulestack} {
+		if _, err := c.token(context.Background(), perm); err != nil {
+			return err
+		}
+	}
+	c.logger().Debug("token initialized")
 	return nil
 }
 
-// this API should be called with c.CloudRulestackAdminMutex taken
-func (c *Client) doAuth(ctx context.Context, info AuthInfo) error {
-	api.Logger.Debugf("[tenant:%s][region:%s] refreshing token...",
-		c.ExternalID, c.Region)
+// doAuth exchanges the external ID for a cloud rulestack admin JWT.
+func (c *Client) doAuth(ctx context.Context, info AuthInfo) (Token, error) {
+	c.logger().Debug("refreshing token")
 	req, err := http.NewRequestWithContext(
-		ctx,
+		withOperation(ctx, "RefreshJwt"),
 		http.MethodGet,
 		fmt.Sprintf("%s/%s?externalid=%s", info.AuthURL, AuthEndpoint, c.ExternalID),
 		nil,
 	)
-	api.Logger.Debugf("[tenant:%s][region:%s] req:%+v", c.ExternalID, c.Region, req)
 	if err != nil {
-		api.Logger.Errorf("[tenant:%s][region:%s] err:%+v", c.ExternalID, c.Region, err)
-		return c.updateToken(c.ExternalID, stack.AuthOutputDetails{}, err)
+		return Token{}, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", c.Agent)
-	resp, err := c.SecureHttpClient.Do(req)
+	resp, body, err := c.do(req.Context(), c.SecureHttpClient, req, nil, nil, true)
 	if err != nil {
-		api.Logger.Errorf("[tenant:%s][region:%s] err:%+v", c.ExternalID, c.Region, err)
-		return c.updateToken(c.ExternalID, stack.AuthOutputDetails{}, err)
+		return Token{}, err
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return c.updateToken(c.ExternalID, stack.AuthOutputDetails{}, fmt.Errorf(string(body)))
+		return Token{}, response.NewAPIError(resp, body)
 	}
 	var output stack.AuthOutput
 	if err = json.Unmarshal(body, &output); err != nil {
-		return c.updateToken(c.ExternalID, output.Response, err)
+		return Token{}, err
 	}
 	if e2 := output.Failed(); e2 != nil {
-		return c.updateToken(c.ExternalID, output.Response, err)
+		return Token{}, response.FromStatus(resp, e2)
+	}
+
+	claims, err := c.ParseToken(output.Response.TokenId)
+	if err != nil {
+		c.logger().Error("failed to parse token", logging.F("error", err))
+		return Token{}, err
+	}
+	if err = c.setTenantVersion(claims); err != nil {
+		return Token{}, err
 	}
+	tok := Token{
+		Jwt:             output.Response.TokenId,
+		SubscriptionKey: output.Response.SubscriptionKey,
+		Expires:         claims.expiresOr(DefaultExpiryTime),
+	}
+	c.logger().Debug("token refreshed", logging.F("expires", tok.Expires))
+	return tok, nil
+}
 
-	return c.updateToken(c.ExternalID, output.Response, nil)
+// externalIDSource fetches the cloud rulestack admin JWT with the external
+// ID, over mTLS.
+type externalIDSource struct {
+	c *Client
 }
 
-// this API should be called with c.CloudRulestackAdminMutex taken
-func (c *Client) updateToken(externalID string, resp stack.AuthOutputDetails, err error) error {
-	if err == nil {
-		c.CloudRulestackAdminJwtExpTime = time.Now().Add(30 * time.Minute)
-		c.CloudRulestackAdminJwt = resp.TokenId
-		c.CloudRulestackSubscriptionKey = resp.SubscriptionKey
-		if err := c.SetTenantVersion(resp.TokenId); err != nil {
-			return err
+// Token implements TokenSource.
+func (s *externalIDSource) Token(ctx context.Context, permission string) (Token, error) {
+	if permission != tokenCloudRulestack {
+		return Token{}, fmt.Errorf("the %s permission is not available with external ID auth", permission)
+	}
+
+	c := s.c
+	return c.doAuth(ctx, AuthInfo{
+		ExternalID:       c.ExternalID,
+		HttpClient:       c.HttpClient,
+		SecureHttpClient: c.SecureHttpClient,
+		Region:           c.Region,
+		RegionURL:        c.apiPrefix,
+		AuthURL:          c.AuthURL,
+	})
+}
+
+// CognitoAPI is the part of the Cognito user pools API used to log in. It
+// is implemented by *cognitoidentityprovider.CognitoIdentityProvider.
+type CognitoAPI interface {
+	InitiateAuthWithContext(aws.Context, *cognito.InitiateAuthInput, ...request.Option) (*cognito.InitiateAuthOutput, error)
+	RespondToAuthChallengeWithContext(aws.Context, *cognito.RespondToAuthChallengeInput, ...request.Option) (*cognito.RespondToAuthChallengeOutput, error)
+}
+
+// CognitoChallenge is a challenge Cognito issued during log in, such as
+// SMS_MFA, SOFTWARE_TOKEN_MFA or NEW_PASSWORD_REQUIRED.
+type CognitoChallenge struct {
+	Name       string
+	Username   string
+	Parameters map[string]string
+}
+
+/*
+CognitoChallengeHandler answers a challenge, returning its challenge
+responses, such as SMS_MFA_CODE or NEW_PASSWORD. USERNAME and SECRET_HASH
+are added by the client.
+*/
+type CognitoChallengeHandler func(ctx context.Context, ch CognitoChallenge) (map[string]string, error)
+
+// maxCognitoChallenges bounds the number of challenges answered in a log in.
+const maxCognitoChallenges = 5
+
+// cognito returns the Cognito API the client logs in with.
+func (c *Client) cognito() CognitoAPI {
+	if c.Cognito != nil {
+		return c.Cognito
+	}
+	if c.CognitoClient != nil {
+		return c.CognitoClient
+	}
+	return nil
+}
+
+/*
+cognitoSource logs in to Cognito with the client's user name and password.
+
+After the first log in, tokens are renewed with the refresh token, so the
+password is only sent again if the refresh token is rejected.
+*/
+type cognitoSource struct {
+	c *Client
+
+	mu           sync.Mutex
+	username     string
+	refreshToken string
+}
+
+// Token implements TokenSource.
+func (s *cognitoSource) Token(ctx context.Context, permission string) (Token, error) {
+	if permission != tokenCognito {
+		return Token{}, fmt.Errorf("the %s permission is not available with Cognito auth", permission)
+	}
+
+	c := s.c
+	api := c.cognito()
+	if api == nil {
+		return Token{}, fmt.Errorf("no Cognito client is configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refreshToken != "" {
+		res, err := api.InitiateAuthWithContext(ctx, &cognito.InitiateAuthInput{
+			AuthFlow:       aws.String(cognito.AuthFlowTypeRefreshTokenAuth),
+			AuthParameters: s.params(s.username, map[string]string{"REFRESH_TOKEN": s.refreshToken}),
+			ClientId:       aws.String(c.AppClientID),
+		})
+		if err == nil && res.AuthenticationResult != nil {
+			return s.token(res.AuthenticationResult)
+		}
+		c.logger().Info("Cognito refresh token rejected, logging in again", logging.F("error", err))
+		s.refreshToken = ""
+	}
+
+	res, err := s.login(ctx, api)
+	if err != nil {
+		return Token{}, err
+	}
+	if res.RefreshToken != nil {
+		s.refreshToken = *res.RefreshToken
+	}
+	return s.token(res)
+}
+
+// login runs the client's auth flow, answering any challenges.
+func (s *cognitoSource) login(ctx context.Context, api CognitoAPI) (*cognito.AuthenticationResultType, error) {
+	c := s.c
+	s.username = c.UserName
+
+	flow := c.CognitoAuthFlow
+	if flow == "" {
+		flow = CognitoFlowPassword
+	}
+	var srp *srpSession
+	params := map[string]string{"USERNAME": c.UserName}
+	switch flow {
+	case CognitoFlowPassword:
+		params["PASSWORD"] = c.Password
+	case CognitoFlowSRP:
+		var err error
+		if srp, err = newSRPSession(c.UserPoolID); err != nil {
+			return nil, err
+		}
+		params["SRP_A"] = srp.A()
+	default:
+		return nil, fmt.Errorf("unsupported Cognito auth flow %q", flow)
+	}
+
+	init, err := api.InitiateAuthWithContext(ctx, &cognito.InitiateAuthInput{
+		AuthFlow:       aws.String(flow),
+		AuthParameters: s.params(c.UserName, params),
+		ClientId:       aws.String(c.AppClientID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, name, session, chParams := init.AuthenticationResult, init.ChallengeName, init.Session, init.ChallengeParameters
+
+	for i := 0; res == nil; i++ {
+		if name == nil {
+			return nil, fmt.Errorf("Cognito returned neither tokens nor a challenge")
+		}
+		if i == maxCognitoChallenges {
+			return nil, fmt.Errorf("too many Cognito challenges, the last one being %s", *name)
+		}
+
+		ch := CognitoChallenge{Name: *name, Username: s.username, Parameters: aws.StringValueMap(chParams)}
+		if id := ch.Parameters["USER_ID_FOR_SRP"]; id != "" {
+			ch.Username = id
+		}
+		if u := ch.Parameters["USERNAME"]; u != "" {
+			ch.Username = u
+		}
+		s.username = ch.Username
+
+		var answers map[string]string
+		switch {
+		case ch.Name == cognito.ChallengeNameTypePasswordVerifier && srp != nil:
+			answers, err = srp.verify(c.Password, ch.Parameters)
+		case c.ChallengeHandler != nil:
+			answers, err = c.ChallengeHandler(ctx, ch)
+		default:
+			return nil, fmt.Errorf("Cognito issued the %s challenge, but no ChallengeHandler is configured", ch.Name)
+		}
+		if err != nil {
+			return nil, err
 		}
-		api.Logger.Debugf("[tenant:%s][region:%s] jwt:%s", externalID, c.Region, c.CloudRulestackAdminJwt)
-	} else {
-		c.CloudRulestackAdminJwtExpTime = time.Now().Add(-time.Minute * 30)
-		c.CloudRulestackAdminJwt = ""
-		c.CloudRulestackSubscriptionKey = ""
-		api.Logger.Errorf("[tenant:%s][region:%s] no jwt retrieved, err:%+v", externalID, c.Region, err)
+		if answers == nil {
+			answers = make(map[string]string)
+		}
+		answers["USERNAME"] = ch.Username
+
+		out, err := api.RespondToAuthChallengeWithContext(ctx, &cognito.RespondToAuthChallengeInput{
+			ChallengeName:      name,
+			ChallengeResponses: s.params(ch.Username, answers),
+			ClientId:           aws.String(c.AppClientID),
+			Session:            session,
+		})
+		if err != nil {
+			return nil, err
+		}
+		res, name, session, chParams = out.AuthenticationResult, out.ChallengeName, out.Session, out.ChallengeParameters
+	}
+
+	return res, nil
+}
+
+// params returns the auth parameters with a SECRET_HASH for username if
+// the app client has a secret.
+func (s *cognitoSource) params(username string, params map[string]string) map[string]*string {
+	if s.c.AppClientSecret != "" {
+		params["SECRET_HASH"] = secretHash(username, s.c.AppClientID, s.c.AppClientSecret)
+	}
+	return aws.StringMap(params)
+}
+
+// token turns the result of a log in into a Token.
+func (s *cognitoSource) token(res *cognito.AuthenticationResultType) (Token, error) {
+	if res.IdToken == nil {
+		return Token{}, fmt.Errorf("Cognito did not return an ID token")
+	}
+	claims, err := s.c.ParseToken(*res.IdToken)
+	if err != nil {
+		return Token{}, err
+	}
+
+	exp := DefaultExpiryTime
+	if res.ExpiresIn != nil {
+		exp = time.Duration(*res.ExpiresIn) * time.Second
 	}
-	return err
+	return Token{
+		Jwt:     *res.IdToken,
+		Expires: claims.expiresOr(exp),
+	}, nil
 }
 
 // externalID based auth over mTLS
 func (c *Client) SetupUsingCreds(ctx context.Context, info AuthInfo) error {
 	c.HttpClient = info.HttpClient
 	c.SecureHttpClient = info.SecureHttpClient
+	if c.SecureHttpClient == nil && c.ClientCertFile != "" {
+		var err error
+		if c.SecureHttpClient, err = c.secureHttpClient(); err != nil {
+			return err
+		}
+	}
+	c.setupMiddleware()
+	if info.RegionURL == "" || info.RegionV2URL == "" || info.AuthURL == "" {
+		regionURL, v2URL, authURL, err := c.regionURLs(info.Region)
+		if err != nil {
+			return err
+		}
+		if info.RegionURL == "" {
+			info.RegionURL = regionURL
+		}
+		if info.RegionV2URL == "" {
+			info.RegionV2URL = v2URL
+		}
+		if info.AuthURL == "" {
+			info.AuthURL = authURL
+		}
+	}
 	c.apiPrefix = info.RegionURL
 	c.v2ApiPrefix = info.RegionV2URL
 	c.ExternalID = info.ExternalID
@@ -162,6 +380,8 @@ func (c *Client) SetupUsingCreds(ctx context.Context, info AuthInfo) error {
 
 // Reads cloud ngfw service token for panorama integration
 func (c *Client) GetCloudNGFWServiceToken(ctx context.Context, info stack.AuthInput) (stack.AuthOutput, error) {
+	ctx = withOperation(ctx, "GetCloudNGFWServiceToken")
+
 	// we don't expect externalID to change per tenant. Hence it's cached in the client.
 	// build the request
 	req, err := http.NewRequestWithContext(
@@ -171,81 +391,74 @@ func (c *Client) GetCloudNGFWServiceToken(ctx context.Context, info stack.AuthIn
 		nil,
 	)
 	if err != nil {
-		api.Logger.Errorf("[tenant:%s][region:%s] err:%+v", c.ExternalID, c.Region, err)
+		c.logger().Error("failed to build service token request", logging.F("error", err))
 		return stack.AuthOutput{}, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", c.Agent)
 
 	// invoke the API
-	api.Logger.Debugf("[tenant:%s][region:%s] req:%+v authURL:%s", c.ExternalID, c.Region, req)
-	resp, err := c.SecureHttpClient.Do(req)
+	resp, body, err := c.do(ctx, c.SecureHttpClient, req, nil, nil, true)
 	if err != nil {
-		api.Logger.Errorf(
-			"[tenant:%s][region:%s] err:%+v", c.ExternalID, c.Region, err)
+		c.logger().Error("service token request failed", logging.F("error", err))
 		return stack.AuthOutput{}, err
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return stack.AuthOutput{}, fmt.Errorf(string(body))
+		return stack.AuthOutput{}, response.NewAPIError(resp, body)
 	}
 	var output stack.AuthOutput
 	if err = json.Unmarshal(body, &output); err != nil {
 		return stack.AuthOutput{}, err
 	}
 	if e2 := output.Failed(); e2 != nil {
-		return stack.AuthOutput{}, err
+		return stack.AuthOutput{}, response.FromStatus(resp, e2)
 	}
 	return output, err
 }
 
-// RefreshJwts refreshes all JWTs and stores them for future API calls.
+// RefreshCloudRulestackAdminJwt makes sure the client holds a valid cloud
+// rulestack admin JWT.
+//
+// Deprecated: Communicate fetches tokens as needed. Use the client's
+// TokenProvider to get one directly.
 func (c *Client) RefreshCloudRulestackAdminJwt(ctx context.Context) error {
-	c.CloudRulestackAdminMutex.Lock()
-	defer c.CloudRulestackAdminMutex.Unlock()
-
-	if c.CloudRulestackAdminJwtExpTime.Sub(time.Now()) > 60*time.Second {
-		// the jwt is valid for 60 or more seconds. let's not replenish it now
-		api.Logger.Debugf("[tenant:%s][region:%s] using existing token:%+v",
-			c.ExternalID, c.Region, c.CloudRulestackAdminJwt)
-		return nil
-	}
-	info := AuthInfo{
-		ExternalID:       c.ExternalID,
-		HttpClient:       c.HttpClient,
-		SecureHttpClient: c.SecureHttpClient,
-		Region:           c.Region,
-		RegionURL:        c.apiPrefix,
-		AuthURL:          c.AuthURL,
+	if c.AuthType == AuthTypeExternalID {
+		_, err := c.token(ctx, PermissionRulestack)
+		return err
 	}
-	c.doAuth(ctx, info)
-	return nil
+	return c.refreshJwt(ctx, tokenCloudRulestack, &externalIDSource{c: c})
 }
 
+// SetTenantVersion sets the client's TenantVersion from the claims of the
+// given JWT.
 func (c *Client) SetTenantVersion(tokenStr string) error {
-	parser := jwt.NewParser()
-	token, _, err := parser.ParseUnverified(tokenStr, jwt.MapClaims{})
+	claims, err := c.ParseToken(tokenStr)
 	if err != nil {
-		api.Logger.Errorf("[tenant:%s][region:%s] failed to parse token: %v", c.ExternalID, c.Region, err)
+		c.logger().Error("failed to parse token", logging.F("error", err))
 		return err
 	}
-	api.Logger.Debug("setting tenant version")
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return fmt.Errorf("Failed to parse token claims")
-		api.Logger.Errorf("[tenant:%s][region:%s] failed to parse token claims: %v", c.ExternalID, c.Region, err)
-	}
-	if tenantVersion, ok := claims["tenant_version"]; !ok {
+	return c.setTenantVersion(claims)
+}
+
+// tenantVersion returns the client's TenantVersion.
+func (c *Client) tenantVersion() string {
+	c.tenantMu.RLock()
+	defer c.tenantMu.RUnlock()
+	return c.TenantVersion
+}
+
+func (c *Client) setTenantVersion(claims *TokenClaims) error {
+	if claims.TenantVersion == "" {
+		c.logger().Error("tenant_version claim not found in token")
 		return fmt.Errorf("tenant_version claim not found in token")
-		api.Logger.Errorf("[tenant:%s][region:%s] tenant_version claim not found in token", c.ExternalID, c.Region)
-	} else {
-		c.TenantVersion = tenantVersion.(string)
-		api.Logger.Errorf("[tenant:%s][region:%s] tenant version:%s", c.ExternalID, c.Region, c.TenantVersion)
-	}
-	api.Logger.Debugf("[tenant:%s][region:%s] set tenant version to: %s", c.ExternalID, c.Region, c.TenantVersion)
-	if c.TenantVersion == TenantVersionV1 && c.Origin == OriginPA {
-		api.Logger.Errorf("[tenant:%s][region:%s] unsupported provider version, please use provider version 2.0.20 or below", c.ExternalID, c.Region)
+	}
+	c.tenantMu.Lock()
+	c.TenantVersion = claims.TenantVersion
+	c.tenantMu.Unlock()
+	c.logger().Debug("set tenant version", logging.F("tenant_version", claims.TenantVersion))
+
+	if claims.TenantVersion == TenantVersionV1 && c.Origin == OriginPA {
+		c.logger().Error("unsupported provider version, please use provider version 2.0.20 or below")
 		return fmt.Errorf("unsupported provider version, please use provider version 2.0.20 or below")
 	}
 	return nil
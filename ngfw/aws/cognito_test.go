@@ -0,0 +1,181 @@
+package aws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	cognito "github.com/aws/aws-sdk-go/service/cognitoidentityprovider"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeCognito is a stand-in for a Cognito user pool with a single user, who
+// logs in with SRP and then answers an MFA challenge.
+type fakeCognito struct {
+	t             *testing.T
+	pool          string
+	clientID      string
+	secret        string
+	user          string
+	password      string
+	rejectRefresh bool
+
+	flows []string
+	salt  *big.Int
+	v     *big.Int
+	b     *big.Int
+	bigA  *big.Int
+	bigB  *big.Int
+	block string
+}
+
+func (f *fakeCognito) checkSecretHash(username string, params map[string]*string) {
+	if got, want := aws.StringValue(params["SECRET_HASH"]), secretHash(username, f.clientID, f.secret); got != want {
+		f.t.Errorf("SECRET_HASH for %s is %q, not %q", username, got, want)
+	}
+}
+
+func (f *fakeCognito) idToken() *cognito.AuthenticationResultType {
+	tok, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"tenant": "t1",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("key"))
+	return &cognito.AuthenticationResultType{IdToken: aws.String(tok), ExpiresIn: aws.Int64(3600)}
+}
+
+func (f *fakeCognito) InitiateAuthWithContext(_ aws.Context, in *cognito.InitiateAuthInput, _ ...request.Option) (*cognito.InitiateAuthOutput, error) {
+	flow := aws.StringValue(in.AuthFlow)
+	f.flows = append(f.flows, flow)
+
+	switch flow {
+	case cognito.AuthFlowTypeRefreshTokenAuth:
+		f.checkSecretHash(f.user, in.AuthParameters)
+		if f.rejectRefresh || aws.StringValue(in.AuthParameters["REFRESH_TOKEN"]) != "rt" {
+			return nil, fmt.Errorf("NotAuthorizedException: Invalid Refresh Token")
+		}
+		return &cognito.InitiateAuthOutput{AuthenticationResult: f.idToken()}, nil
+	case CognitoFlowSRP:
+		f.checkSecretHash(f.user, in.AuthParameters)
+		f.bigA, _ = new(big.Int).SetString(aws.StringValue(in.AuthParameters["SRP_A"]), 16)
+
+		// The server side of SRP: v = g^x, B = k*v + g^b.
+		f.salt = big.NewInt(0x5a17)
+		up := sha256.Sum256([]byte(strings.SplitN(f.pool, "_", 2)[1] + f.user + ":" + f.password))
+		x := hashHex(padHex(f.salt) + hex.EncodeToString(up[:]))
+		f.v = new(big.Int).Exp(srpG, x, srpBigN)
+		f.b = big.NewInt(0x1234567)
+		f.bigB = new(big.Int).Mul(srpK, f.v)
+		f.bigB.Add(f.bigB, new(big.Int).Exp(srpG, f.b, srpBigN))
+		f.bigB.Mod(f.bigB, srpBigN)
+		f.block = base64.StdEncoding.EncodeToString([]byte("secret block"))
+
+		return &cognito.InitiateAuthOutput{
+			ChallengeName: aws.String(cognito.ChallengeNameTypePasswordVerifier),
+			ChallengeParameters: aws.StringMap(map[string]string{
+				"USER_ID_FOR_SRP": f.user,
+				"SRP_B":           f.bigB.Text(16),
+				"SALT":            f.salt.Text(16),
+				"SECRET_BLOCK":    f.block,
+			}),
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected auth flow %s", flow)
+}
+
+func (f *fakeCognito) RespondToAuthChallengeWithContext(_ aws.Context, in *cognito.RespondToAuthChallengeInput, _ ...request.Option) (*cognito.RespondToAuthChallengeOutput, error) {
+	r := aws.StringValueMap(in.ChallengeResponses)
+	f.checkSecretHash(f.user, in.ChallengeResponses)
+
+	switch aws.StringValue(in.ChallengeName) {
+	case cognito.ChallengeNameTypePasswordVerifier:
+		// S = (A * v^u)^b
+		u := hashHex(padHex(f.bigA) + padHex(f.bigB))
+		bigS := new(big.Int).Mul(f.bigA, new(big.Int).Exp(f.v, u, srpBigN))
+		bigS.Exp(bigS, f.b, srpBigN)
+
+		mac := hmac.New(sha256.New, srpKey(bigS, u))
+		mac.Write([]byte(strings.SplitN(f.pool, "_", 2)[1] + f.user + "secret block" + r["TIMESTAMP"]))
+		if r["PASSWORD_CLAIM_SIGNATURE"] != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+			return nil, fmt.Errorf("NotAuthorizedException: Incorrect username or password")
+		}
+		return &cognito.RespondToAuthChallengeOutput{
+			ChallengeName: aws.String(cognito.ChallengeNameTypeSoftwareTokenMfa),
+			Session:       aws.String("mfa-session"),
+		}, nil
+	case cognito.ChallengeNameTypeSoftwareTokenMfa:
+		if aws.StringValue(in.Session) != "mfa-session" || r["SOFTWARE_TOKEN_MFA_CODE"] != "123456" {
+			return nil, fmt.Errorf("CodeMismatchException: Invalid code")
+		}
+		res := f.idToken()
+		res.RefreshToken = aws.String("rt")
+		return &cognito.RespondToAuthChallengeOutput{AuthenticationResult: res}, nil
+	}
+	return nil, fmt.Errorf("unexpected challenge %s", aws.StringValue(in.ChallengeName))
+}
+
+func TestCognitoSRPWithMFA(t *testing.T) {
+	f := &fakeCognito{
+		t:        t,
+		pool:     "us-east-1_AbCdEf",
+		clientID: "client",
+		secret:   "client secret",
+		user:     "alice",
+		password: "hunter2",
+	}
+	var challenges []string
+	c := &Client{
+		AuthType:        AuthTypeCognito,
+		Cognito:         f,
+		CognitoAuthFlow: CognitoFlowSRP,
+		UserName:        f.user,
+		Password:        f.password,
+		UserPoolID:      f.pool,
+		AppClientID:     f.clientID,
+		AppClientSecret: f.secret,
+		ChallengeHandler: func(ctx context.Context, ch CognitoChallenge) (map[string]string, error) {
+			challenges = append(challenges, ch.Name)
+			return map[string]string{"SOFTWARE_TOKEN_MFA_CODE": "123456"}, nil
+		},
+	}
+	src := &cognitoSource{c: c}
+
+	tok, err := src.Token(context.Background(), tokenCognito)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tok.Valid(time.Minute) {
+		t.Fatalf("token is not valid: %+v", tok)
+	}
+	if len(challenges) != 1 || challenges[0] != cognito.ChallengeNameTypeSoftwareTokenMfa {
+		t.Fatalf("handler got challenges %v", challenges)
+	}
+
+	// Renewals use the refresh token, and log in again once it is rejected.
+	if _, err = src.Token(context.Background(), tokenCognito); err != nil {
+		t.Fatal(err)
+	}
+	f.rejectRefresh = true
+	if _, err = src.Token(context.Background(), tokenCognito); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{CognitoFlowSRP, cognito.AuthFlowTypeRefreshTokenAuth, cognito.AuthFlowTypeRefreshTokenAuth, CognitoFlowSRP}
+	if fmt.Sprint(f.flows) != fmt.Sprint(want) {
+		t.Fatalf("auth flows were %v, not %v", f.flows, want)
+	}
+
+	// A wrong password fails the verifier.
+	c.Password = "wrong"
+	src = &cognitoSource{c: c}
+	if _, err = src.Token(context.Background(), tokenCognito); err == nil {
+		t.Fatalf("logged in with the wrong password")
+	}
+}
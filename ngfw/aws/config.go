@@ -0,0 +1,476 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+// Provenance of config values.
+const (
+	SourceExplicit = "explicit"
+	SourceDefault  = "default"
+)
+
+/*
+Config is the configuration of a Client, without its runtime state.
+
+Each setting has a json tag naming it in the auth file, an env tag naming
+the env var it can be read from, and possibly a default. Settings tagged
+secret are hidden by Redacted.
+
+Build one with LoadConfig, and a Client from it with NewClient.
+*/
+type Config struct {
+	// AuthFile, Context and CheckEnvironment control where LoadConfig
+	// looks for settings, see Client.
+	AuthFile         string `json:"-"`
+	Context          string `json:"-"`
+	CheckEnvironment bool   `json:"-"`
+
+	Host            string            `json:"host" env:"CLOUDNGFWAWS_HOST"`
+	V2Host          string            `json:"v2_host" env:"CLOUDNGFWAWS_V2_HOST"`
+	MPRegionHost    string            `json:"mp_region_host" env:"CLOUDNGFWAWS_MP_REGION_HOST"`
+	Region          string            `json:"region" env:"CLOUDNGFWAWS_REGION"`
+	MPRegion        string            `json:"mp_region" env:"CLOUDNGFWAWS_MP_REGION" default:"us-east-1"`
+	Protocol        string            `json:"protocol" env:"CLOUDNGFWAWS_PROTOCOL" default:"https"`
+	Timeout         int               `json:"timeout" env:"CLOUDNGFWAWS_TIMEOUT" default:"30"`
+	ResourceTimeout int               `json:"resource_timeout" env:"CLOUDNGFWAWS_RESOURCE_TIMEOUT" default:"7200"`
+	Headers         map[string]string `json:"headers" env:"CLOUDNGFWAWS_HEADERS"`
+	Agent           string            `json:"agent"`
+	SyncMode        bool              `json:"sync_mode" env:"CLOUDNGFWAWS_SYNC_MODE"`
+	Logging         []string          `json:"logging" env:"CLOUDNGFWAWS_LOGGING"`
+
+	SkipVerifyCertificate bool   `json:"skip-verify-certificate" env:"CLOUDNGFWAWS_SKIP_VERIFY_CERTIFICATE"`
+	ClientCertFile        string `json:"client-cert-file" env:"CLOUDNGFWAWS_CLIENT_CERT_FILE"`
+	ClientKeyFile         string `json:"client-key-file" env:"CLOUDNGFWAWS_CLIENT_KEY_FILE"`
+	ClientCAFile          string `json:"client-ca-file" env:"CLOUDNGFWAWS_CLIENT_CA_FILE"`
+	JwksURL               string `json:"jwks-url" env:"CLOUDNGFWAWS_JWKS_URL"`
+
+	// The hosts left empty are resolved from Region and MPRegion.
+	Endpoints map[string]Endpoints `json:"endpoints" env:"CLOUDNGFWAWS_ENDPOINTS"`
+
+	Profile   string `json:"profile" env:"CLOUDNGFWAWS_PROFILE"`
+	AccessKey string `json:"access-key" env:"CLOUDNGFWAWS_ACCESS_KEY"`
+	SecretKey string `json:"secret-key" env:"CLOUDNGFWAWS_SECRET_KEY" secret:"true"`
+
+	LfaArn              string             `json:"lfa-arn" env:"CLOUDNGFWAWS_LFA_ARN"`
+	LraArn              string             `json:"lra-arn" env:"CLOUDNGFWAWS_LRA_ARN"`
+	GraArn              string             `json:"gra-arn" env:"CLOUDNGFWAWS_GRA_ARN"`
+	AcctAdminArn        string             `json:"account-admin-arn" env:"CLOUDNGFWAWS_ACCT_ADMIN_ARN"`
+	Arn                 string             `json:"arn" env:"CLOUDNGFWAWS_ARN"`
+	AssumeRole          *AssumeRoleOptions `json:"assume-role" env:"CLOUDNGFWAWS_ASSUME_ROLE"`
+	LfaAssumeRole       *AssumeRoleOptions `json:"lfa-assume-role" env:"CLOUDNGFWAWS_LFA_ASSUME_ROLE"`
+	LraAssumeRole       *AssumeRoleOptions `json:"lra-assume-role" env:"CLOUDNGFWAWS_LRA_ASSUME_ROLE"`
+	GraAssumeRole       *AssumeRoleOptions `json:"gra-assume-role" env:"CLOUDNGFWAWS_GRA_ASSUME_ROLE"`
+	AcctAdminAssumeRole *AssumeRoleOptions `json:"account-admin-assume-role" env:"CLOUDNGFWAWS_ACCT_ADMIN_ASSUME_ROLE"`
+
+	Tenant          string `json:"tenant"`
+	ExternalID      string `json:"externalID" secret:"true"`
+	UserName        string `json:"userName"`
+	Password        string `json:"b64" secret:"true"`
+	UserPoolID      string `json:"userPoolID"`
+	AppClientID     string `json:"appClientID"`
+	AppClientSecret string `json:"appClientSecret" secret:"true"`
+	CognitoAuthFlow string `json:"cognito-auth-flow" env:"CLOUDNGFWAWS_COGNITO_AUTH_FLOW"`
+
+	TokenCacheDir string               `json:"token-cache-dir" env:"CLOUDNGFWAWS_TOKEN_CACHE_DIR"`
+	TokenCacheKey string               `json:"token-cache-key" env:"CLOUDNGFWAWS_TOKEN_CACHE_KEY" secret:"true"`
+	RateLimits    map[string]RateLimit `json:"rate-limits" env:"CLOUDNGFWAWS_RATE_LIMITS"`
+
+	// Provenance records where LoadConfig found each setting, by json
+	// name: SourceExplicit, SourceDefault, "env NAME" or "auth file PATH".
+	Provenance map[string]string `json:"-"`
+}
+
+// ConfigError lists all the problems found in a Config.
+type ConfigError struct {
+	Errors []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d config errors: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+func (e *ConfigError) add(format string, a ...interface{}) {
+	e.Errors = append(e.Errors, fmt.Errorf(format, a...))
+}
+
+func (e *ConfigError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// configSettings returns the fields of Config that are settings, as
+// opposed to the ones controlling the loading.
+func configSettings() []reflect.StructField {
+	t := reflect.TypeOf(Config{})
+	ans := make([]reflect.StructField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); settingName(f) != "" {
+			ans = append(ans, f)
+		}
+	}
+	return ans
+}
+
+func settingName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+/*
+LoadConfig fills in the settings that are not set in explicit, taking each
+from the first place it is found in: the env vars (if CheckEnvironment is
+set), the selected context of the AuthFile, and the defaults.
+
+The result is validated, and all the problems found are returned at once in
+a ConfigError, along with the config so they can be looked into.
+*/
+func LoadConfig(explicit Config) (*Config, error) {
+	cfg := explicit
+	cfg.Provenance = make(map[string]string)
+	var errs ConfigError
+
+	var file Config
+	var fileSource string
+	if cfg.AuthFile != "" {
+		b, name, err := authFileSettings(cfg.AuthFile, cfg.Context, cfg.CheckEnvironment)
+		if err != nil {
+			errs.add("auth file: %s", err)
+		} else if err = json.Unmarshal(b, &file); err != nil {
+			errs.add("auth file %s: %s", cfg.AuthFile, err)
+		}
+		cfg.Context = name
+		fileSource = "auth file " + cfg.AuthFile
+		if name != "" {
+			fileSource += " context " + name
+		}
+	}
+
+	cv := reflect.ValueOf(&cfg).Elem()
+	fv := reflect.ValueOf(file)
+	for _, f := range configSettings() {
+		name := settingName(f)
+		v := cv.FieldByIndex(f.Index)
+
+		if !v.IsZero() {
+			cfg.Provenance[name] = SourceExplicit
+			continue
+		}
+		if env := f.Tag.Get("env"); env != "" && cfg.CheckEnvironment {
+			if val := os.Getenv(env); val != "" {
+				if err := parseSetting(v, val); err != nil {
+					errs.add("env %s: %s", env, err)
+				}
+				cfg.Provenance[name] = "env " + env
+				continue
+			}
+		}
+		if fval := fv.FieldByIndex(f.Index); !fval.IsZero() {
+			v.Set(fval)
+			cfg.Provenance[name] = fileSource
+			continue
+		}
+		if def, ok := f.Tag.Lookup("default"); ok {
+			if err := parseSetting(v, def); err != nil {
+				panic(fmt.Sprintf("bad default for %s: %s", name, err))
+			}
+			cfg.Provenance[name] = SourceDefault
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		errs.Errors = append(errs.Errors, err.(*ConfigError).Errors...)
+	}
+	return &cfg, errs.err()
+}
+
+// parseSetting sets v from the text of an env var or default: lists are
+// comma separated, and maps and structs are JSON.
+func parseSetting(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		p := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), p.Interface()); err != nil {
+			return err
+		}
+		v.Set(p.Elem())
+	}
+	return nil
+}
+
+// Validate checks the config, returning a ConfigError with all the problems
+// found.
+func (c *Config) Validate() error {
+	var errs ConfigError
+
+	if c.Region == "" {
+		errs.add("region is required")
+	}
+	for _, h := range []struct{ name, host string }{{"host", c.Host}, {"v2_host", c.V2Host}, {"mp_region_host", c.MPRegionHost}} {
+		if strings.Contains(h.host, "://") {
+			errs.add("%s %q must not include a scheme, set protocol instead", h.name, h.host)
+		}
+	}
+	for region, ep := range c.Endpoints {
+		for _, h := range []string{ep.Host, ep.V2Host, ep.AuthHost} {
+			if strings.Contains(h, "://") {
+				errs.add("endpoints: host %q of %s must not include a scheme", h, region)
+			}
+		}
+	}
+	r := EndpointResolver{Overrides: c.Endpoints}
+	if c.Region != "" && (c.Host == "" || c.V2Host == "") {
+		if _, err := r.Resolve(c.Region); err != nil {
+			errs.add("region: %s", err)
+		}
+	}
+	if c.MPRegion != "" && c.MPRegionHost == "" {
+		if _, err := r.Resolve(c.MPRegion); err != nil {
+			errs.add("mp_region: %s", err)
+		}
+	}
+	if c.Protocol != "" && c.Protocol != "http" && c.Protocol != "https" {
+		errs.add("protocol %q must be 'https' or 'http'", c.Protocol)
+	}
+	if c.Timeout < 0 {
+		errs.add("timeout must be a positive int, not %d", c.Timeout)
+	}
+	if c.ResourceTimeout < 0 {
+		errs.add("resource_timeout must be a positive int, not %d", c.ResourceTimeout)
+	}
+	if _, err := parseLogging(c.Logging); err != nil {
+		errs.add("logging: %s", err)
+	}
+
+	for k, v := range c.RateLimits {
+		switch k {
+		case PermissionFirewall, PermissionRulestack, PermissionGlobalRulestack, PermissionAccount:
+		default:
+			errs.add("rate-limits: unknown permission %q", k)
+		}
+		if v.Rate < 0 || v.Burst < 0 {
+			errs.add("rate-limits: limit for %q must not be negative", k)
+		}
+	}
+
+	roles := []struct {
+		name string
+		opts *AssumeRoleOptions
+	}{
+		{"assume-role", c.AssumeRole},
+		{"lfa-assume-role", c.LfaAssumeRole},
+		{"lra-assume-role", c.LraAssumeRole},
+		{"gra-assume-role", c.GraAssumeRole},
+		{"account-admin-assume-role", c.AcctAdminAssumeRole},
+	}
+	for _, r := range roles {
+		if err := r.opts.validate(); err != nil {
+			errs.add("%s: %s", r.name, err)
+		}
+	}
+
+	switch c.CognitoAuthFlow {
+	case "", CognitoFlowPassword, CognitoFlowSRP:
+	default:
+		errs.add("cognito-auth-flow %q must be %s or %s", c.CognitoAuthFlow, CognitoFlowPassword, CognitoFlowSRP)
+	}
+	if c.CognitoAuthFlow == CognitoFlowSRP && c.UserPoolID == "" {
+		errs.add("userPoolID is required for %s", CognitoFlowSRP)
+	}
+
+	if (c.ClientCertFile == "") != (c.ClientKeyFile == "") {
+		errs.add("client-cert-file and client-key-file must be set together")
+	}
+	if c.TokenCacheDir != "" && c.TokenCacheKey == "" {
+		errs.add("token-cache-key is required to cache tokens in %s", c.TokenCacheDir)
+	}
+	if c.JwksURL != "" {
+		if u, err := url.Parse(c.JwksURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			errs.add("jwks-url %q must be an http or https URL", c.JwksURL)
+		}
+	}
+
+	return errs.err()
+}
+
+/*
+Redacted returns the settings and where they came from, one per line, with
+secrets hidden, including the external IDs of the assume role options. It is
+meant to be attached to support tickets.
+*/
+func (c *Config) Redacted() string {
+	var b strings.Builder
+	if c.AuthFile != "" {
+		fmt.Fprintf(&b, "auth file: %s\n", c.AuthFile)
+	}
+	if c.Context != "" {
+		fmt.Fprintf(&b, "context: %s\n", c.Context)
+	}
+
+	v := reflect.ValueOf(*c)
+	for _, f := range configSettings() {
+		name := settingName(f)
+		fv := v.FieldByIndex(f.Index)
+		if fv.IsZero() {
+			continue
+		}
+
+		var val string
+		switch x := fv.Interface().(type) {
+		case string:
+			val = x
+			if f.Tag.Get("secret") == "true" {
+				val = logging.Redacted
+			}
+		case map[string]string:
+			keys := make([]string, 0, len(x))
+			for k := range x {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			parts := make([]string, 0, len(keys))
+			for _, k := range keys {
+				hv := x[k]
+				if logging.IsSensitive(k) {
+					hv = logging.Redacted
+				}
+				parts = append(parts, k+"="+hv)
+			}
+			val = strings.Join(parts, ", ")
+		case []string:
+			val = strings.Join(x, ",")
+		case int, bool:
+			val = fmt.Sprint(x)
+		case *AssumeRoleOptions:
+			jb, _ := json.Marshal(x.redacted())
+			val = string(jb)
+		default:
+			jb, _ := json.Marshal(x)
+			val = string(jb)
+		}
+
+		if src := c.Provenance[name]; src != "" {
+			fmt.Fprintf(&b, "%s: %s (%s)\n", name, val, src)
+		} else {
+			fmt.Fprintf(&b, "%s: %s\n", name, val)
+		}
+	}
+	return b.String()
+}
+
+/*
+NewClient validates the config and builds a Client from it, ready to use.
+
+A config that didn't come from LoadConfig is loaded first, so its AuthFile
+and the env vars are taken into account.
+*/
+func NewClient(cfg Config) (*Client, error) {
+	return newClient(cfg, &Client{})
+}
+
+// newClient loads or validates cfg, then sets up c from it.
+func newClient(cfg Config, c *Client) (*Client, error) {
+	ans := &cfg
+	if cfg.Provenance == nil {
+		var err error
+		if ans, err = LoadConfig(cfg); err != nil {
+			return nil, err
+		}
+	} else if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return clientFromConfig(ans, c)
+}
+
+// config returns the settings set on c, for LoadConfig to fill in.
+func (c *Client) config() Config {
+	cfg := Config{AuthFile: c.AuthFile, Context: c.Context, CheckEnvironment: c.CheckEnvironment}
+	cv := reflect.ValueOf(&cfg).Elem()
+	v := reflect.ValueOf(c).Elem()
+	for _, f := range configSettings() {
+		if f.Name == "Logging" {
+			// The client holds the parsed flags, see clientFromConfig.
+			continue
+		}
+		cv.FieldByIndex(f.Index).Set(v.FieldByName(f.Name))
+	}
+	return cfg
+}
+
+// clientFromConfig sets up c, which may already hold runtime state such as
+// a Transport, from a loaded and validated config.
+func clientFromConfig(ans *Config, c *Client) (*Client, error) {
+	c.Context = ans.Context
+	cv := reflect.ValueOf(c).Elem()
+	v := reflect.ValueOf(*ans)
+	for _, f := range configSettings() {
+		if f.Name == "Logging" {
+			// The client holds the parsed flags, see below.
+			continue
+		}
+		dst := cv.FieldByName(f.Name)
+		if !dst.IsValid() || dst.Type() != f.Type {
+			panic(fmt.Sprintf("config setting %s has no matching client field", f.Name))
+		}
+		dst.Set(v.FieldByIndex(f.Index))
+	}
+
+	// Flags already set on the client, which Setup allows, win over the
+	// config.
+	if c.Logging == 0 {
+		lv, err := parseLogging(ans.Logging)
+		if err != nil {
+			return nil, err
+		}
+		if lv == 0 {
+			lv = awsngfw.LogLogin | awsngfw.LogGet | awsngfw.LogAction
+		}
+		c.Logging = lv
+	}
+	c.LoggingFromInitialize = ans.Logging
+
+	if err := c.setupRuntime(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
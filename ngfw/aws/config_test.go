@@ -0,0 +1,199 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	err := ioutil.WriteFile(path, []byte(`{"host": "file.example.com", "region": "us-west-2", "secret-key": "hush", "timeout": 10,
+		"assume-role": {"external-id": "ext-hush", "via": ["arn:aws:iam::111111111111:role/jump"], "via-external-ids": {"arn:aws:iam::111111111111:role/jump": "via-hush"}}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLOUDNGFWAWS_TIMEOUT", "60")
+
+	cfg, err := LoadConfig(Config{AuthFile: path, CheckEnvironment: true, Protocol: "http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "file.example.com" || cfg.Timeout != 60 || cfg.Protocol != "http" || cfg.ResourceTimeout != 7200 {
+		t.Fatalf("wrong config: %+v", cfg)
+	}
+	want := map[string]string{
+		"host":             "auth file " + path,
+		"timeout":          "env CLOUDNGFWAWS_TIMEOUT",
+		"protocol":         SourceExplicit,
+		"resource_timeout": SourceDefault,
+	}
+	for k, v := range want {
+		if cfg.Provenance[k] != v {
+			t.Fatalf("%s came from %q, not %q", k, cfg.Provenance[k], v)
+		}
+	}
+
+	dump := cfg.Redacted()
+	if strings.Contains(dump, "hush") || !strings.Contains(dump, "secret-key: "+logging.Redacted) || !strings.Contains(dump, "role/jump") {
+		t.Fatalf("secret was not redacted:\n%s", dump)
+	}
+	if !strings.Contains(dump, "timeout: 60 (env CLOUDNGFWAWS_TIMEOUT)") {
+		t.Fatalf("provenance is missing:\n%s", dump)
+	}
+
+	c, err := NewClient(*cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "file.example.com" || c.SecretKey != "hush" || c.Logging != awsngfw.LogLogin|awsngfw.LogGet|awsngfw.LogAction {
+		t.Fatalf("client was not built from the config: %+v", c)
+	}
+
+	// All problems are reported at once.
+	_, err = LoadConfig(Config{Protocol: "ftp", Logging: []string{"everything"}, ClientCertFile: "c.pem"})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || len(ce.Errors) != 4 {
+		t.Fatalf("expected 4 config errors, got %v", err)
+	}
+}
+
+func TestSetupReportsAllErrors(t *testing.T) {
+	c := &Client{Protocol: "ftp", Timeout: -1, ClientCertFile: "c.pem"}
+	err := c.Setup()
+	var ce *ConfigError
+	if !errors.As(err, &ce) || len(ce.Errors) != 4 {
+		t.Fatalf("expected 4 config errors, got %v", err)
+	}
+}
+
+func TestConfigRedactedExternalID(t *testing.T) {
+	dump := (&Config{ExternalID: "ext-secret-1234"}).Redacted()
+	if strings.Contains(dump, "ext-secret-1234") || !strings.Contains(dump, "externalID: "+logging.Redacted) {
+		t.Fatalf("external ID was not redacted:\n%s", dump)
+	}
+}
+
+// configSamples holds a valid value for every Config setting, by json name,
+// as found in an auth file.
+var configSamples = map[string]interface{}{
+	"host":                      "host.example.com",
+	"v2_host":                   "v2.example.com",
+	"mp_region_host":            "mp.example.com",
+	"region":                    "us-west-2",
+	"mp_region":                 "eu-west-1",
+	"protocol":                  "http",
+	"timeout":                   12,
+	"resource_timeout":          34,
+	"headers":                   map[string]string{"X-Team": "netsec"},
+	"agent":                     "tests",
+	"sync_mode":                 true,
+	"logging":                   []string{"get", "path"},
+	"skip-verify-certificate":   true,
+	"client-cert-file":          "client.crt",
+	"client-key-file":           "client.key",
+	"client-ca-file":            "ca.pem",
+	"jwks-url":                  "https://example.com/jwks",
+	"endpoints":                 map[string]Endpoints{"us-west-2": {AuthHost: "auth.example.com"}},
+	"profile":                   "ci",
+	"access-key":                "AKID",
+	"secret-key":                "hush",
+	"lfa-arn":                   "arn:aws:iam::111111111111:role/lfa",
+	"lra-arn":                   "arn:aws:iam::111111111111:role/lra",
+	"gra-arn":                   "arn:aws:iam::111111111111:role/gra",
+	"account-admin-arn":         "arn:aws:iam::111111111111:role/admin",
+	"arn":                       "arn:aws:iam::111111111111:role/any",
+	"assume-role":               AssumeRoleOptions{SessionName: "any"},
+	"lfa-assume-role":           AssumeRoleOptions{SessionName: "lfa"},
+	"lra-assume-role":           AssumeRoleOptions{SessionName: "lra"},
+	"gra-assume-role":           AssumeRoleOptions{SessionName: "gra"},
+	"account-admin-assume-role": AssumeRoleOptions{SessionName: "admin"},
+	"tenant":                    "tenant-1",
+	"externalID":                "ext-1",
+	"userName":                  "alice",
+	"b64":                       "cGFzcw==",
+	"userPoolID":                "us-west-2_abc",
+	"appClientID":               "app",
+	"appClientSecret":           "app-secret",
+	"cognito-auth-flow":         CognitoFlowPassword,
+	"token-cache-dir":           "/tmp/tokens",
+	"token-cache-key":           "cache-key",
+	"rate-limits":               map[string]RateLimit{PermissionRulestack: {Rate: 1, Burst: 2}},
+}
+
+// TestSetupMatchesConfig makes sure that every Config setting reaches the
+// Client the same way through Setup as through NewClient, whether it comes
+// from the auth file or the env.
+func TestSetupMatchesConfig(t *testing.T) {
+	for _, f := range configSettings() {
+		if _, ok := configSamples[settingName(f)]; !ok {
+			t.Fatalf("no sample value for %s", settingName(f))
+		}
+	}
+
+	b, err := json.Marshal(configSamples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err = ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The certificate files are not read with a SecureHttpClient.
+	secure := &http.Client{}
+	check := func(source string, explicit Config) {
+		setup := &Client{AuthFile: explicit.AuthFile, CheckEnvironment: explicit.CheckEnvironment, SecureHttpClient: secure}
+		if err := setup.Setup(); err != nil {
+			t.Fatalf("%s: Setup: %s", source, err)
+		}
+		loaded, err := newClient(explicit, &Client{SecureHttpClient: secure})
+		if err != nil {
+			t.Fatalf("%s: NewClient: %s", source, err)
+		}
+
+		sv, lv := reflect.ValueOf(setup).Elem(), reflect.ValueOf(loaded).Elem()
+		for _, f := range configSettings() {
+			a, b := sv.FieldByName(f.Name).Interface(), lv.FieldByName(f.Name).Interface()
+			if !reflect.DeepEqual(a, b) {
+				t.Errorf("%s: %s is %v with Setup, but %v with NewClient", source, settingName(f), a, b)
+			}
+			if reflect.ValueOf(b).IsZero() {
+				t.Errorf("%s: %s was not loaded", source, settingName(f))
+			}
+		}
+	}
+
+	check("auth file", Config{AuthFile: path})
+
+	for _, f := range configSettings() {
+		env := f.Tag.Get("env")
+		if env == "" {
+			continue
+		}
+		var val string
+		switch x := configSamples[settingName(f)].(type) {
+		case string:
+			val = x
+		case []string:
+			val = strings.Join(x, ",")
+		case int, bool:
+			val = fmt.Sprint(x)
+		default:
+			jb, _ := json.Marshal(x)
+			val = string(jb)
+		}
+		t.Setenv(env, val)
+	}
+	// The settings without an env var still come from the auth file.
+	check("env", Config{AuthFile: path, CheckEnvironment: true})
+}
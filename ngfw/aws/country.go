@@ -9,6 +9,8 @@ import (
 
 // List returns a list of objects.
 func (c *Client) ListCountry(ctx context.Context, input country.ListInput) (country.ListOutput, error) {
+	ctx = withOperation(ctx, "ListCountry")
+
 	c.Log(http.MethodGet, "list countries")
 	path := Path{
 		V1Path: []string{"v1", "config", "countries"},
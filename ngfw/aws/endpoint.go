@@ -0,0 +1,142 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultEndpointSuffixes are the DNS suffixes of the Cloud NGFW endpoints
+// in each AWS partition.
+var DefaultEndpointSuffixes = map[string]string{
+	"aws": "aws.cloudngfw.paloaltonetworks.com",
+}
+
+// Endpoints are the hosts serving a region.
+type Endpoints struct {
+	// Host serves the config API, V2Host the v2 API, and AuthHost the
+	// external ID auth endpoints.
+	Host     string `json:"host"`
+	V2Host   string `json:"v2_host"`
+	AuthHost string `json:"auth_host"`
+}
+
+// Partition returns the AWS partition a region belongs to.
+func Partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "aws-iso-b"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "aws-iso"
+	}
+	return "aws"
+}
+
+/*
+EndpointResolver derives the endpoints of a region from its partition's DNS
+suffix:
+
+	Host:     api.<region>.<suffix>
+	V2Host:   api.<region>.<suffix>
+	AuthHost: api-auth.<region>.<suffix>
+
+Overrides take precedence, host by host, for regions that don't follow the
+pattern.
+*/
+type EndpointResolver struct {
+	// Overrides maps regions to their endpoints. Hosts left empty are
+	// derived as usual.
+	Overrides map[string]Endpoints
+
+	// Suffixes maps partitions to their DNS suffix. If nil,
+	// DefaultEndpointSuffixes is used.
+	Suffixes map[string]string
+}
+
+// Resolve returns the endpoints of a region.
+func (r EndpointResolver) Resolve(region string) (Endpoints, error) {
+	if region == "" {
+		return Endpoints{}, fmt.Errorf("no region to resolve the endpoints of")
+	}
+
+	ans := r.Overrides[region]
+	if ans.Host != "" && ans.V2Host != "" && ans.AuthHost != "" {
+		return ans, nil
+	}
+
+	suffixes := r.Suffixes
+	if suffixes == nil {
+		suffixes = DefaultEndpointSuffixes
+	}
+	part := Partition(region)
+	suffix, ok := suffixes[part]
+	if !ok {
+		return Endpoints{}, fmt.Errorf("the endpoints of %s in partition %s are unknown, set them in the endpoint overrides", region, part)
+	}
+
+	if ans.Host == "" {
+		ans.Host = fmt.Sprintf("api.%s.%s", region, suffix)
+	}
+	if ans.V2Host == "" {
+		ans.V2Host = fmt.Sprintf("api.%s.%s", region, suffix)
+	}
+	if ans.AuthHost == "" {
+		ans.AuthHost = fmt.Sprintf("api-auth.%s.%s", region, suffix)
+	}
+	return ans, nil
+}
+
+// resolver returns the client's endpoint resolver.
+func (c *Client) resolver() EndpointResolver {
+	return EndpointResolver{Overrides: c.Endpoints}
+}
+
+// resolveHosts fills in the hosts not configured from the client's Region
+// and MPRegion.
+func (c *Client) resolveHosts() error {
+	if c.Host == "" || c.V2Host == "" {
+		ep, err := c.resolver().Resolve(c.Region)
+		if err != nil {
+			return err
+		}
+		if c.Host == "" {
+			c.Host = ep.Host
+		}
+		if c.V2Host == "" {
+			c.V2Host = ep.V2Host
+		}
+	}
+	if c.MPRegionHost == "" {
+		ep, err := c.resolver().Resolve(c.MPRegion)
+		if err != nil {
+			return err
+		}
+		c.MPRegionHost = ep.Host
+	}
+	return nil
+}
+
+// regionURLs returns the config, v2 and auth URLs of a region.
+func (c *Client) regionURLs(region string) (string, string, string, error) {
+	ep, err := c.resolver().Resolve(region)
+	if err != nil {
+		return "", "", "", err
+	}
+	proto := c.Protocol
+	if proto == "" {
+		proto = "https"
+	}
+	return fmt.Sprintf("%s://%s", proto, ep.Host),
+		fmt.Sprintf("%s://%s", proto, ep.V2Host),
+		fmt.Sprintf("%s://%s", proto, ep.AuthHost),
+		nil
+}
+
+// ResolveEndpoints returns the endpoints of a region, taking the client's
+// Endpoints overrides into account.
+func (c *Client) ResolveEndpoints(region string) (Endpoints, error) {
+	return c.resolver().Resolve(region)
+}
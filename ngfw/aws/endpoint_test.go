@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+)
+
+func TestEndpointResolution(t *testing.T) {
+	c := &Client{
+		Region: "eu-west-1",
+		Endpoints: map[string]Endpoints{
+			"ap-south-2": {Host: "api.ap-south-2.example.com"},
+		},
+	}
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetApiPrefix(testContext()) != "https://api.eu-west-1.aws.cloudngfw.paloaltonetworks.com" || c.MPRegionHost != DefaultMPRegionHost {
+		t.Fatalf("hosts not resolved from the region: %q %q %q", c.Host, c.V2Host, c.MPRegionHost)
+	}
+
+	ep, err := c.ResolveEndpoints("ap-south-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Host != "api.ap-south-2.example.com" || ep.AuthHost != "api-auth.ap-south-2.aws.cloudngfw.paloaltonetworks.com" {
+		t.Fatalf("override not applied: %+v", ep)
+	}
+	if _, err = c.ResolveEndpoints("cn-north-1"); err == nil || !strings.Contains(err.Error(), "aws-cn") {
+		t.Fatalf("expected an unknown partition error, got %v", err)
+	}
+
+	if err = c.SetEndpoint(testContext(), api.EndPointInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if c.AuthURL != "https://api-auth.eu-west-1.aws.cloudngfw.paloaltonetworks.com" || c.v2ApiPrefix != "https://api.eu-west-1.aws.cloudngfw.paloaltonetworks.com" {
+		t.Fatalf("SetEndpoint did not resolve the URLs: %q %q", c.AuthURL, c.v2ApiPrefix)
+	}
+
+	// A V2Host configured for the region wins over the one derived from
+	// the auth URL, as in Setup.
+	c.Endpoints = map[string]Endpoints{"eu-west-1": {V2Host: "v2.eu-west-1.example.com"}}
+	err = c.SetEndpoint(testContext(), api.EndPointInput{
+		ApiEndpoint:     "https://api.eu-west-1.example.com",
+		ApiAuthEndpoint: "https://api-auth.eu-west-1.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AuthURL != "https://api-auth.eu-west-1.example.com" || c.v2ApiPrefix != "https://v2.eu-west-1.example.com" {
+		t.Fatalf("SetEndpoint ignored the V2Host override: %q %q", c.AuthURL, c.v2ApiPrefix)
+	}
+
+	c = &Client{}
+	if err = c.SetupUsingCreds(testContext(), AuthInfo{ExternalID: "ext", Region: "us-west-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.apiPrefix != "https://api.us-west-2.aws.cloudngfw.paloaltonetworks.com" || c.AuthURL != "https://api-auth.us-west-2.aws.cloudngfw.paloaltonetworks.com" {
+		t.Fatalf("SetupUsingCreds did not resolve the URLs: %q %q", c.apiPrefix, c.AuthURL)
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 // List returns a list of objects.
 func (c *Client) ListFeed(ctx context.Context, input feed.ListInput) (feed.ListOutput, error) {
+	ctx = withOperation(ctx, "ListFeed")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return feed.ListOutput{}, permErr
@@ -34,6 +36,8 @@ func (c *Client) ListFeed(ctx context.Context, input feed.ListInput) (feed.ListO
 
 // Create creates an object.
 func (c *Client) CreateFeed(ctx context.Context, input feed.Info) error {
+	ctx = withOperation(ctx, "CreateFeed")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -58,6 +62,8 @@ func (c *Client) CreateFeed(ctx context.Context, input feed.Info) error {
 
 // Read returns information on the given object.
 func (c *Client) ReadFeed(ctx context.Context, input feed.ReadInput) (feed.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadFeed")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return feed.ReadOutput{}, permErr
@@ -83,6 +89,8 @@ func (c *Client) ReadFeed(ctx context.Context, input feed.ReadInput) (feed.ReadO
 
 // Update updates the given object.
 func (c *Client) UpdateFeed(ctx context.Context, input feed.Info) error {
+	ctx = withOperation(ctx, "UpdateFeed")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -110,6 +118,8 @@ func (c *Client) UpdateFeed(ctx context.Context, input feed.Info) error {
 
 // Delete removes the given object from the config.
 func (c *Client) DeleteFeed(ctx context.Context, input feed.DeleteInput) error {
+	ctx = withOperation(ctx, "DeleteFeed")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -3,7 +3,6 @@ package aws
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -15,6 +14,7 @@ import (
 
 	cloudngfwgosdk "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/response"
 	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/tag"
 )
@@ -36,6 +36,8 @@ func getSchemaVersion(ctx context.Context) string {
 
 // List returns a list of firewalls.
 func (c *Client) ListFirewall(ctx context.Context, input firewall.ListInput) (firewall.ListOutput, error) {
+	ctx = withOperation(ctx, "ListFirewall")
+
 	if len(input.VpcIds) == 0 {
 		c.Log(http.MethodGet, "list NGFirewalls in all the VPCs")
 	} else {
@@ -52,11 +54,14 @@ func (c *Client) ListFirewall(ctx context.Context, input firewall.ListInput) (fi
 	if input.Region != "" {
 		uv.Set("region", input.Region)
 	}
+	if input.NextToken != "" {
+		uv.Set("nexttoken", input.NextToken)
+	}
 	if input.MaxResults != 0 {
 		maxResults := strconv.Itoa(input.MaxResults)
 		uv.Set("maxresults", maxResults)
 	}
-	c.Log(http.MethodGet, "list firewalls, tenant version: %s", c.TenantVersion)
+	c.Log(http.MethodGet, "list firewalls, tenant version: %s", c.tenantVersion())
 	path := Path{
 		V1Path: []string{"v1", "config", "ngfirewalls"},
 		V2Path: []string{"v2", "config", "ngfirewalls"},
@@ -77,6 +82,8 @@ func (c *Client) ListFirewall(ctx context.Context, input firewall.ListInput) (fi
 
 // Create creates an object.
 func (c *Client) CreateFirewall(ctx context.Context, input firewall.Info) (firewall.CreateOutput, error) {
+	ctx = withOperation(ctx, "CreateFirewall")
+
 	c.Log(http.MethodPost, "create firewall %q", input.Name)
 
 	var ans firewall.CreateOutput
@@ -110,6 +117,8 @@ func (c *Client) CreateFirewallWithWait(ctx context.Context, input firewall.Info
 }
 
 func (c *Client) ListTagsForFirewall(ctx context.Context, input firewall.ListTagsInput) (firewall.ListTagsOutput, error) {
+	ctx = withOperation(ctx, "ListTagsForFirewall")
+
 	c.Log(http.MethodGet, "list tags for firewall: %s", input.Firewall)
 
 	var uv url.Values
@@ -145,6 +154,8 @@ func (c *Client) ListTagsForFirewall(ctx context.Context, input firewall.ListTag
 
 // UpdateDescription updates the description of the firewall.
 func (c *Client) UpdateFirewallDescription(ctx context.Context, input firewall.UpdateDescriptionInput) error {
+	ctx = withOperation(ctx, "UpdateFirewallDescription")
+
 	c.Log(http.MethodPut, "updating firewall description: %s", input.Firewall)
 	uv := url.Values{}
 	uv.Set("v1route", "true")
@@ -166,6 +177,8 @@ func (c *Client) UpdateFirewallDescription(ctx context.Context, input firewall.U
 
 // UpdateSubnetMappings updates the subnet mappings of the firewall.
 func (c *Client) UpdateFirewallSubnetMappings(ctx context.Context, input firewall.UpdateSubnetMappingsInput) error {
+	ctx = withOperation(ctx, "UpdateFirewallSubnetMappings")
+
 	c.Log(http.MethodPut, "updating firewall subnet mappings: %s", input.Firewall)
 	uv := url.Values{}
 	uv.Set("v1route", "true")
@@ -185,6 +198,8 @@ func (c *Client) UpdateFirewallSubnetMappings(ctx context.Context, input firewal
 }
 
 func (c *Client) RemoveTagsForFirewall(ctx context.Context, input firewall.RemoveTagsInput) error {
+	ctx = withOperation(ctx, "RemoveTagsForFirewall")
+
 	c.Log(http.MethodDelete, "removing tags from firewall: %s", input.Firewall)
 	uv := url.Values{}
 	uv.Set("v1route", "true")
@@ -206,6 +221,8 @@ func (c *Client) RemoveTagsForFirewall(ctx context.Context, input firewall.Remov
 
 // AddTags adds the given tags to the firewall.
 func (c *Client) AddTagsForFirewall(ctx context.Context, input firewall.AddTagsInput) error {
+	ctx = withOperation(ctx, "AddTagsForFirewall")
+
 	c.Log(http.MethodPost, "adding tags to the firewall: %s", input.Firewall)
 	uv := url.Values{}
 	uv.Set("v1route", "true")
@@ -227,6 +244,8 @@ func (c *Client) AddTagsForFirewall(ctx context.Context, input firewall.AddTagsI
 
 // UpdateRulestack updates the rulestack for the given firewall.
 func (c *Client) UpdateFirewallRulestackV1(ctx context.Context, input firewall.UpdateRulestackInput) error {
+	ctx = withOperation(ctx, "UpdateFirewallRulestackV1")
+
 	c.Log(http.MethodPost, "updating firewall rulestack: %s", input.Firewall)
 	uv := url.Values{}
 	uv.Set("v1route", "true")
@@ -247,6 +266,8 @@ func (c *Client) UpdateFirewallRulestackV1(ctx context.Context, input firewall.U
 }
 
 func (c *Client) UpdateFirewallFeatures(ctx context.Context, input firewall.UpdateFeaturesAPIInput) error {
+	ctx = withOperation(ctx, "UpdateFirewallFeatures")
+
 	c.Log(http.MethodPut, "updating firewall features: %+v", input.Features)
 	uv := url.Values{}
 	uv.Set("v1route", "true")
@@ -298,8 +319,11 @@ func (c *Client) ModifyFirewallV1(ctx context.Context, input firewall.Info) erro
 		}
 	}
 
-	log.Printf("current subnet mappings: %+v", cur.SubnetMappings)
-	log.Printf("input subnet mappings: %+v", input.SubnetMappings)
+	c.logger().Debug("updating subnet mappings",
+		logging.F("firewall", input.Name),
+		logging.F("current", cur.SubnetMappings),
+		logging.F("input", input.SubnetMappings),
+	)
 	// Update subnet mappings.
 	assoc := make([]firewall.SubnetMapping, 0, len(input.SubnetMappings))
 	disassoc := make([]firewall.SubnetMapping, 0, len(cur.SubnetMappings))
@@ -452,6 +476,8 @@ func (c *Client) ModifyFirewallV1(ctx context.Context, input firewall.Info) erro
 }
 
 func (c *Client) ModifyFirewall(ctx context.Context, input firewall.Info) (firewall.UpdateOutput, error) {
+	ctx = withOperation(ctx, "ModifyFirewall")
+
 	c.Log(http.MethodPut, "updating firewall: %s", input.Id)
 	//var ans firewall.CreateOutput
 	path := Path{
@@ -584,9 +610,9 @@ func (c *Client) ModifyFirewallWithWait(ctx context.Context, input firewall.Info
 	timeStamp := time.Now().UTC().Unix()
 	genUpdate := false
 	drsCommit := false
-	log.Printf("===============ModifyFirewallWithWait================")
+	c.logger().Debug("modifying firewall", logging.F("firewall_id", input.Id))
 
-	_, err := c.retryOnTokenConflict(ctx, func() (interface{}, error) {
+	_, err := c.retryOnTokenConflict(ctx, "ModifyFirewallWithWait", func() (interface{}, error) {
 		return nil, c.UpdateFirewallRulestack(ctx, input)
 	})
 	if err != nil {
@@ -605,7 +631,7 @@ func (c *Client) ModifyFirewallWithWait(ctx context.Context, input firewall.Info
 			UpdateToken: input.UpdateToken,
 			FirewallId:  input.Id,
 		}
-		_, err := c.retryOnTokenConflict(ctx, func() (interface{}, error) {
+		_, err := c.retryOnTokenConflict(ctx, "ModifyFirewallWithWait", func() (interface{}, error) {
 			return nil, c.DisassociateRuleStackWithWait(ctx, disassociateInput)
 		})
 		if err != nil {
@@ -615,7 +641,7 @@ func (c *Client) ModifyFirewallWithWait(ctx context.Context, input firewall.Info
 
 	readOutput, _ := c.ReadFirewall(ctx, readInput)
 
-	result, err := c.retryOnTokenConflict(ctx, func() (interface{}, error) {
+	result, err := c.retryOnTokenConflict(ctx, "ModifyFirewallWithWait", func() (interface{}, error) {
 		return c.ReadAndModifyFirewall(ctx, input)
 	})
 	if err != nil {
@@ -638,7 +664,6 @@ func (c *Client) ModifyFirewallWithWait(ctx context.Context, input firewall.Info
 	}
 
 	// TODO: Build firewall update struct based on modified properties.
-	log.Printf("===============TOKEN POST CHECK================")
 	if updateFirewall.GeneralUpdate {
 		err := c.WaitForFirewallStatus(ctx, c, input.Id, []string{FirewallStatusUpdateComplete.String(), FirewallStatusUpdateFail.String()})
 		if err != nil {
@@ -678,6 +703,8 @@ func (c *Client) ReadAndModifyFirewall(ctx context.Context, input firewall.Info)
 
 // Read returns information on the given object.
 func (c *Client) ReadFirewall(ctx context.Context, input firewall.ReadInput) (firewall.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadFirewall")
+
 	name := input.Name
 	schemaVersion := getSchemaVersion(ctx)
 	fwId := input.FirewallId
@@ -714,6 +741,8 @@ func (c *Client) ReadFirewall(ctx context.Context, input firewall.ReadInput) (fi
 
 // Delete the given firewall.
 func (c *Client) DeleteFirewall(ctx context.Context, input firewall.DeleteInput) (firewall.DeleteOutput, error) {
+	ctx = withOperation(ctx, "DeleteFirewall")
+
 	name := input.Name
 	c.Log(http.MethodDelete, "delete firewall: %s", input.Name)
 	schemaVersion := getSchemaVersion(ctx)
@@ -758,6 +787,8 @@ func (c *Client) DeleteFirewallWithWait(ctx context.Context, input firewall.Dele
 
 // AssociateRulestack updates the local rulestack for the given firewall.
 func (c *Client) AssociateRulestack(ctx context.Context, input firewall.AssociateInput) (firewall.AssociateOutput, error) {
+	ctx = withOperation(ctx, "AssociateRulestack")
+
 	c.Log(http.MethodPost, "associating firewall rulestack: %s", input.Firewall)
 	name := input.Firewall
 	schemaVersion := getSchemaVersion(ctx)
@@ -832,6 +863,8 @@ func (c *Client) UpdateFirewallRulestack(ctx context.Context, input firewall.Inf
 
 // Disassociate local Firewall to Global rulestack
 func (c *Client) DisassociateRuleStack(ctx context.Context, input firewall.DisAssociateInput) (firewall.DisAssociateOutput, error) {
+	ctx = withOperation(ctx, "DisassociateRuleStack")
+
 	c.Log(http.MethodDelete, "disassociating firewall to local rulestack: %s", input.Firewall)
 	var uv url.Values
 	path := Path{
@@ -868,6 +901,8 @@ func (c *Client) DisassociateRuleStackWithWait(ctx context.Context, input firewa
 
 // Associate Firewall to Global rulestack
 func (c *Client) AssociateGlobalRuleStack(ctx context.Context, input firewall.AssociateInput) (firewall.AssociateOutput, error) {
+	ctx = withOperation(ctx, "AssociateGlobalRuleStack")
+
 	c.Log(http.MethodPut, "associating firewall to global rulestack: %s", input.Firewall)
 	c.Log(http.MethodPost, "associating firewall rulestack: %s", input.Firewall)
 	name := input.Firewall
@@ -900,6 +935,8 @@ func (c *Client) AssociateGlobalRuleStack(ctx context.Context, input firewall.As
 
 // Disassociate Firewall to Global rulestack
 func (c *Client) DisAssociateGlobalRuleStack(ctx context.Context, input firewall.DisAssociateInput) (firewall.DisAssociateOutput, error) {
+	ctx = withOperation(ctx, "DisAssociateGlobalRuleStack")
+
 	c.Log(http.MethodDelete, "disassociating firewall to global rulestack: %s", input.Firewall)
 	var uv url.Values
 	path := Path{
@@ -920,37 +957,39 @@ func (c *Client) DisAssociateGlobalRuleStack(ctx context.Context, input firewall
 	return ans, err
 }
 
-func verifyCommitStatus(commitInfo *firewall.RuleStackCommitData, commitStatus string, timestamp int64, name string) (bool, error) {
+func verifyCommitStatus(l logging.Logger, commitInfo *firewall.RuleStackCommitData, commitStatus string, timestamp int64, name string) (bool, error) {
 	commitProcessFinished := false
 	if commitStatus == CommitStateFailed.String() {
 		messages := commitInfo.CommitMessages
 		return commitProcessFinished, fmt.Errorf("commit failed, %v", messages)
 	}
 	if commitStatus != CommitStateSuccess.String() {
-		log.Printf("rulestack %s commit is in progress, status: %s", name, commitStatus)
+		l.Debug("rulestack commit is in progress", logging.F("rulestack", name), logging.F("status", commitStatus))
 		return commitProcessFinished, nil
 	}
 
 	commitTimestamp := commitInfo.CommitTS
 	if len(commitTimestamp) == 0 {
-		log.Printf("rulestack commit timestamp is empty: %s", name)
+		l.Debug("rulestack commit timestamp is empty", logging.F("rulestack", name))
 		return commitProcessFinished, nil
 	}
 	epochTimestamp, err := ConvertToUTCEpoch(commitTimestamp)
 	if err != nil {
 		return commitProcessFinished, fmt.Errorf("failed to convert to epoch timestamp, err: %s, timestamp: %d", err, epochTimestamp)
 	}
-	log.Printf("current epoch: %d", timestamp)
-	log.Printf("commit epoch: %d", epochTimestamp)
 	if epochTimestamp <= timestamp {
-		log.Printf("rulestack commit timestamp validation failed: %s", name)
+		l.Debug("rulestack commit predates the request",
+			logging.F("rulestack", name),
+			logging.F("requested", timestamp),
+			logging.F("committed", epochTimestamp),
+		)
 		return commitProcessFinished, nil
 	}
 	return true, nil
 }
 
 func (c *Client) WaitForDRSCommit(ctx context.Context, svc *Client, fid string, timestamp int64) error {
-	return WaitForOperation(ctx, func(ctx context.Context) (bool, error) {
+	return c.wait(ctx, "WaitForDRSCommit", func(ctx context.Context) (bool, error) {
 		req := firewall.ReadInput{
 			FirewallId: fid,
 		}
@@ -960,7 +999,7 @@ func (c *Client) WaitForDRSCommit(ctx context.Context, svc *Client, fid string,
 		}
 		commitInfo := res.Response.Status.DeviceRuleStackCommitInfo
 		commitStatus := res.Response.Status.DeviceRuleStackCommitStatus
-		completed, err := verifyCommitStatus(commitInfo, commitStatus, timestamp, "drs")
+		completed, err := verifyCommitStatus(svc.logger(), commitInfo, commitStatus, timestamp, "drs")
 		if err != nil {
 			return false, err
 		}
@@ -973,7 +1012,7 @@ func (c *Client) WaitForDRSCommit(ctx context.Context, svc *Client, fid string,
 }
 
 func (c *Client) WaitForLRSCommit(ctx context.Context, svc *Client, fid string, timestamp int64) error {
-	return WaitForOperation(ctx, func(ctx context.Context) (bool, error) {
+	return c.wait(ctx, "WaitForLRSCommit", func(ctx context.Context) (bool, error) {
 		req := firewall.ReadInput{
 			FirewallId: fid,
 		}
@@ -983,12 +1022,11 @@ func (c *Client) WaitForLRSCommit(ctx context.Context, svc *Client, fid string,
 		}
 		commitInfo := res.Response.Status.RuleStackCommitInfo
 		commitStatus := res.Response.Status.RulestackStatus
-		completed, err := verifyCommitStatus(commitInfo, commitStatus, timestamp, "lrs")
+		completed, err := verifyCommitStatus(svc.logger(), commitInfo, commitStatus, timestamp, "lrs")
 		if err != nil {
 			return false, err
 		}
 		if !completed {
-			log.Printf("Waiting for LRS commit to be completed..")
 			svc.Log("Waiting for LRS commit to be completed..: %s ", fid)
 			return true, fmt.Errorf("LRS commit is not yet completed, retrying...")
 		}
@@ -997,7 +1035,7 @@ func (c *Client) WaitForLRSCommit(ctx context.Context, svc *Client, fid string,
 }
 
 func (c *Client) WaitForFirewallStatus(ctx context.Context, svc *Client, fid string, expStatus []string) error {
-	return WaitForOperation(ctx, func(ctx context.Context) (bool, error) {
+	return c.wait(ctx, "WaitForFirewallStatus", func(ctx context.Context) (bool, error) {
 		req := firewall.ReadInput{
 			FirewallId: fid,
 		}
@@ -1014,25 +1052,22 @@ func (c *Client) WaitForFirewallStatus(ctx context.Context, svc *Client, fid str
 	})
 }
 
-func (c *Client) retryOnTokenConflict(ctx context.Context, operation func() (interface{}, error)) (interface{}, error) {
+func (c *Client) retryOnTokenConflict(ctx context.Context, name string, operation func() (interface{}, error)) (interface{}, error) {
 	var result interface{}
 	var err error
 
-	err = WaitForOperation(ctx, func(ctx context.Context) (bool, error) {
+	err = c.wait(ctx, name, func(ctx context.Context) (bool, error) {
 		result, err = operation()
 		if err != nil {
 			if failureResponse, ok := err.(response.Failure); ok {
 				if status := failureResponse.Failed(); status != nil && status.TokenConflict() {
-					log.Printf("Retrying operation due to token conflict")
+					c.logger().Debug("retrying operation due to token conflict")
 					return true, err
 				}
 			}
-			log.Printf("Token conflict not found, returning original error")
 			return false, err
 		}
-		log.Printf("Operation successful, returning result")
 		return false, nil
 	})
-	log.Printf("retry operation count exceeded, returning final error")
 	return result, err
 }
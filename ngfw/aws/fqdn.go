@@ -9,6 +9,8 @@ import (
 
 // ListFqdn returns a fqdn.List of objects.
 func (c *Client) ListFqdn(ctx context.Context, input fqdn.ListInput) (fqdn.ListOutput, error) {
+	ctx = withOperation(ctx, "ListFqdn")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return fqdn.ListOutput{}, permErr
@@ -34,6 +36,8 @@ func (c *Client) ListFqdn(ctx context.Context, input fqdn.ListInput) (fqdn.ListO
 
 // Create creates an object.
 func (c *Client) CreateFqdn(ctx context.Context, input fqdn.Info) error {
+	ctx = withOperation(ctx, "CreateFqdn")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -58,6 +62,8 @@ func (c *Client) CreateFqdn(ctx context.Context, input fqdn.Info) error {
 
 // Read returns information on the given object.
 func (c *Client) ReadFqdn(ctx context.Context, input fqdn.ReadInput) (fqdn.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadFqdn")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return fqdn.ReadOutput{}, permErr
@@ -83,6 +89,8 @@ func (c *Client) ReadFqdn(ctx context.Context, input fqdn.ReadInput) (fqdn.ReadO
 
 // Update updates the given object.
 func (c *Client) UpdateFqdn(ctx context.Context, input fqdn.Info) error {
+	ctx = withOperation(ctx, "UpdateFqdn")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -110,6 +118,8 @@ func (c *Client) UpdateFqdn(ctx context.Context, input fqdn.Info) error {
 
 // Delete removes the given object from the config.
 func (c *Client) DeleteFqdn(ctx context.Context, input fqdn.DeleteInput) error {
+	ctx = withOperation(ctx, "DeleteFqdn")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
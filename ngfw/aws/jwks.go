@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefetchInterval is how often a JWKS may be fetched again because a
+// token was signed with an unknown key.
+const jwksRefetchInterval = time.Minute
+
+// jwksMethods are the signing methods accepted for tokens verified against
+// a JWKS.
+var jwksMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+/*
+JWKS verifies JWT signatures against the JSON Web Key Set published at a
+URL.
+
+The keys are fetched the first time a token is verified, and fetched again
+when a token names a key that is not in the set, at most once every minute.
+RSA and EC keys are supported.
+*/
+type JWKS struct {
+	URL    string
+	Client *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]jwk
+	fetched time.Time
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	key interface{}
+}
+
+// NewJWKS returns a JWKS for the key set at url, fetched with client. If
+// client is nil, http.DefaultClient is used.
+func NewJWKS(url string, client *http.Client) *JWKS {
+	return &JWKS{URL: url, Client: client}
+}
+
+// Keyfunc returns the key that signed the token. It can be passed to the
+// jwt package's parse functions.
+func (j *JWKS) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid header")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	k, ok := j.keys[kid]
+	if !ok && time.Since(j.fetched) > jwksRefetchInterval {
+		if err := j.fetch(); err != nil {
+			return nil, err
+		}
+		k, ok = j.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("key %q is not in the JWKS at %s", kid, j.URL)
+	}
+	if k.Alg != "" && k.Alg != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q is for %s, not %s", kid, k.Alg, token.Method.Alg())
+	}
+	return k.key, nil
+}
+
+// fetch reads the key set. This must be called with j.mu taken.
+func (j *JWKS) fetch() error {
+	j.fetched = time.Now()
+
+	client := j.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching the JWKS at %s: %s", j.URL, resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err = json.Unmarshal(body, &set); err != nil {
+		return fmt.Errorf("parsing the JWKS at %s: %w", j.URL, err)
+	}
+
+	keys := make(map[string]jwk, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of types we don't know are skipped, so one odd key doesn't
+		// break verification with the others.
+		if k.key, err = k.publicKey(); err == nil {
+			keys[k.Kid] = k
+		}
+	}
+	j.keys = keys
+	return nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("key %q is not on curve %s", k.Kid, k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParseTokenWithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer jwks.Close()
+
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"tenant":         "t1",
+		"tenant_version": "V2",
+		"region":         "us-east-1",
+		"permission":     "rulestack",
+		"scope":          "read write",
+		"exp":            exp.Unix(),
+	})
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Client{JwksURL: jwks.URL}
+	claims, err := c.ParseToken(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Tenant != "t1" || claims.TenantVersion != "V2" || claims.Region != "us-east-1" || claims.Permission != "rulestack" {
+		t.Fatalf("wrong claims: %+v", claims)
+	}
+	if len(claims.Scopes) != 1 || claims.Scopes[0] != "read write" {
+		t.Fatalf("wrong scopes: %q", claims.Scopes)
+	}
+	if !claims.Expires().Equal(exp) {
+		t.Fatalf("expires at %s, not %s", claims.Expires(), exp)
+	}
+
+	// A token with a forged signature is rejected, unless nothing is
+	// verified.
+	parts := strings.Split(signed, ".")
+	forged := parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString([]byte("forged"))
+	if _, err = c.ParseToken(forged); err == nil {
+		t.Fatalf("forged token was accepted")
+	}
+	if _, err = (&Client{}).ParseToken(forged); err != nil {
+		t.Fatalf("unverified parse failed: %s", err)
+	}
+}
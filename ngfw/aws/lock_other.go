@@ -0,0 +1,32 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package aws
+
+import (
+	"os"
+	"time"
+)
+
+// staleLock is how old a lock file must be to be considered abandoned by a
+// process that died while holding it.
+const staleLock = 2 * time.Minute
+
+// tryLock creates path as a lock file without waiting. Platforms without
+// flock fall back to exclusive file creation.
+func tryLock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, serr := os.Stat(path); serr == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(path)
+		}
+		return nil, errLocked
+	}
+	f.Close()
+
+	return func() {
+		os.Remove(path)
+	}, nil
+}
@@ -0,0 +1,31 @@
+package aws
+
+import (
+	"log"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+/*
+logger returns the client's Logger, annotated with the tenant and region.
+
+If the client has no Logger, the global api.Logger is used when it is set,
+and the standard library logger otherwise.
+*/
+func (c *Client) logger() logging.Logger {
+	l := c.Logger
+	if l == nil {
+		if api.Logger != nil {
+			l = logging.Zap(api.Logger)
+		} else {
+			l = logging.Std(log.Default(), false)
+		}
+	}
+
+	tenant := c.ExternalID
+	if tenant == "" {
+		tenant = c.Tenant
+	}
+	return l.With(logging.F("tenant", tenant), logging.F("region", c.Region))
+}
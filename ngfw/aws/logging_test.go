@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestLoggingRedactsTokens(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	c := &Client{
+		Host:     srv.Host(),
+		V2Host:   srv.Host(),
+		Protocol: "http",
+		Region:   ngfwtest.DefaultRegion,
+		Logging:  awsngfw.LogSend | awsngfw.LogReceive,
+		Logger:   logging.Std(log.New(&buf, "", 0), true),
+	}
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ans authResponse
+	_, err := c.RequestJwt(ctx, http.MethodGet, []string{"v1", "mgmt", "tokens", "cloudrulestackadmin"}, nil, getJwt{Expires: 120}, &ans, &sts.Credentials{
+		AccessKeyId:     aws.String("AKID"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("session"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ans.Resp.Jwt == "" || ans.Resp.SubscriptionKey == "" {
+		t.Fatalf("no token in response: %+v", ans.Resp)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, logging.Redacted) {
+		t.Fatalf("response was not logged: %s", out)
+	}
+	for _, secret := range []string{ans.Resp.Jwt, ans.Resp.SubscriptionKey} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log contains a secret: %s", out)
+		}
+	}
+}
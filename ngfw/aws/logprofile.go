@@ -11,6 +11,8 @@ import (
 
 // Read returns information on the given object.
 func (c *Client) ReadFirewallLogprofile(ctx context.Context, input logprofile.ReadInput) (logprofile.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadFirewallLogprofile")
+
 	name := input.Firewall
 	fwId := input.FirewallId
 	uv := url.Values{}
@@ -43,6 +45,8 @@ func (c *Client) ReadFirewallLogprofile(ctx context.Context, input logprofile.Re
 
 // Update updates the given object.
 func (c *Client) UpdateFirewallLogprofile(ctx context.Context, input logprofile.Info) error {
+	ctx = withOperation(ctx, "UpdateFirewallLogprofile")
+
 	fwId := input.FirewallId
 	name := input.Firewall
 	uv := url.Values{}
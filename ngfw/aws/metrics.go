@@ -0,0 +1,13 @@
+package aws
+
+import (
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/metrics"
+)
+
+// metrics returns the client's Metrics, or a no-op one.
+func (c *Client) metrics() metrics.Metrics {
+	if c.Metrics != nil {
+		return c.Metrics
+	}
+	return metrics.Nop()
+}
@@ -0,0 +1,69 @@
+package aws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/metrics"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestMetricsAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	srv.PendingPolls = 1
+	defer srv.Close()
+	c := newExternalIDClient(t, srv)
+	c.Waiter = &Waiter{MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	c.RetryPolicy = &RetryPolicy{MaxAttempts: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	reg := metrics.NewRegistry("")
+	c.Metrics = reg
+
+	if err := c.CreateRuleStack(ctx, stack.Info{Name: "rs1"}); err != nil {
+		t.Fatal(err)
+	}
+	srv.AddFault(ngfwtest.Fault{Method: http.MethodGet, Path: "/v1/config/rulestacks/rs1", Count: 1, StatusCode: http.StatusServiceUnavailable, RetryAfter: "0"})
+	if _, err := c.ReadRuleStack(ctx, stack.ReadInput{Name: "rs1"}); err != nil {
+		t.Fatal(err)
+	}
+	si := stack.SimpleInput{Name: "rs1"}
+	if err := c.CommitRuleStack(ctx, si); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PollCommitRuleStack(ctx, si); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"api_calls_total", []string{"CreateRuleStack", http.MethodPost, "200"}, 1},
+		{"api_calls_total", []string{"ReadRuleStack", http.MethodGet, "200"}, 1},
+		{"api_calls_total", []string{"CommitStatusRuleStack", http.MethodGet, "200"}, 2},
+		{"api_retries_total", []string{"ReadRuleStack", http.MethodGet}, 1},
+		{"token_refreshes_total", []string{tokenCloudRulestack, "success"}, 1},
+	}
+	for _, chk := range checks {
+		if got := reg.Counter(chk.name, chk.labels...); got != chk.want {
+			t.Errorf("%s%v: got %v, want %v", chk.name, chk.labels, got, chk.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		`cloudngfw_api_calls_total{operation="CreateRuleStack",method="POST",status="200"} 1`,
+		`cloudngfw_wait_duration_seconds_count{operation="PollCommitRuleStack",result="success"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+)
+
+/*
+Middleware wraps the transport used for every request sent by the client.
+
+Middleware is applied to each attempt after the request has been signed, so
+it sees exactly what is sent on the wire. Use RequestInfoFromContext on the
+request's context to find out which operation is being performed.
+
+	c.Middleware = append(c.Middleware, func(next http.RoundTripper) http.RoundTripper {
+		return aws.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Correlation-Id", newID())
+			return next.RoundTrip(req)
+		})
+	})
+*/
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc lets an ordinary function be used as a http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// RequestInfo describes the request a middleware is handling.
+type RequestInfo struct {
+	// Operation is the name of the Client function that made the request,
+	// such as "CreateRuleStack", or empty for requests made directly with
+	// Communicate.
+	Operation string
+
+	// Permission is the permission used to authorize the request, or empty
+	// for requests that fetch tokens.
+	Permission string
+
+	// Attempt is the attempt number, starting at 1.
+	Attempt int
+}
+
+type requestInfoKey struct{}
+
+// RequestInfoFromContext returns the RequestInfo stored in ctx by the client.
+func RequestInfoFromContext(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info, ok
+}
+
+func withRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// withOperation names the operation of the requests made with ctx. Every
+// API call sets it on entry.
+func withOperation(ctx context.Context, name string) context.Context {
+	return withRequestInfo(ctx, RequestInfo{Operation: name})
+}
+
+// withPermission records the permission of the request about to be made.
+func withPermission(ctx context.Context, auth string) context.Context {
+	info, _ := RequestInfoFromContext(ctx)
+	info.Permission = auth
+	return withRequestInfo(ctx, info)
+}
+
+// wrap returns hc with the client's middleware wrapped around its transport.
+func (c *Client) wrap(hc *http.Client) *http.Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	var rt http.RoundTripper = http.DefaultTransport
+	if hc.Transport != nil {
+		rt = hc.Transport
+	}
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		rt = c.Middleware[i](rt)
+	}
+
+	ans := *hc
+	ans.Transport = rt
+	return &ans
+}
+
+// setupMiddleware wraps the client's HttpClient and SecureHttpClient with
+// its middleware, once, for httpClient to hand out.
+func (c *Client) setupMiddleware() {
+	c.wrapped = nil
+	if len(c.Middleware) == 0 {
+		return
+	}
+
+	c.wrapped = make(map[*http.Client]*http.Client, 2)
+	for _, hc := range []*http.Client{c.HttpClient, c.SecureHttpClient} {
+		if hc != nil {
+			c.wrapped[hc] = c.wrap(hc)
+		}
+	}
+}
+
+// httpClient returns hc with the client's middleware wrapped around its
+// transport.
+func (c *Client) httpClient(hc *http.Client) *http.Client {
+	if len(c.Middleware) == 0 {
+		return hc
+	}
+	if ans, ok := c.wrapped[hc]; ok {
+		return ans
+	}
+
+	// Clients swapped in after Setup.
+	return c.wrap(hc)
+}
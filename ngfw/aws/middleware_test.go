@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestMiddlewareAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	var seen []RequestInfo
+	c := &Client{AuthType: AuthTypeExternalID}
+	c.RetryPolicy = &RetryPolicy{MaxAttempts: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	c.Middleware = []Middleware{
+		func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				info, ok := RequestInfoFromContext(req.Context())
+				if !ok {
+					t.Errorf("no request info for %s", req.URL.Path)
+				}
+				seen = append(seen, info)
+				req.Header.Set("X-Correlation-Id", "abc")
+				return next.RoundTrip(req)
+			})
+		},
+	}
+	err := c.SetupUsingCreds(context.Background(), AuthInfo{
+		ExternalID:       "ext-1234",
+		Region:           ngfwtest.DefaultRegion,
+		HttpClient:       srv.Client(),
+		SecureHttpClient: srv.Client(),
+		RegionURL:        srv.URL,
+		RegionV2URL:      srv.URL,
+		AuthURL:          srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.httpClient(c.HttpClient) != c.httpClient(c.HttpClient) {
+		t.Fatalf("the middleware chain should be built once")
+	}
+
+	srv.AddFault(ngfwtest.Fault{Method: http.MethodGet, Path: "/v1/config/rulestacks", Count: 1, StatusCode: http.StatusServiceUnavailable, RetryAfter: "0"})
+	if _, err := c.ListRuleStack(ctx, stack.ListInput{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []RequestInfo{
+		{Operation: "RefreshJwt", Attempt: 1},
+		{Operation: "ListRuleStack", Permission: PermissionRulestack, Attempt: 1},
+		{Operation: "ListRuleStack", Permission: PermissionRulestack, Attempt: 2},
+	}
+	if len(seen) != len(want) {
+		t.Fatalf("saw %+v, want %+v", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("request %d: saw %+v, want %+v", i, seen[i], want[i])
+		}
+	}
+
+	reqs := srv.Requests()
+	if got := reqs[len(reqs)-1].Header.Get("X-Correlation-Id"); got != "abc" {
+		t.Fatalf("middleware header was not sent, got %q", got)
+	}
+}
@@ -0,0 +1,344 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logprofile"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+)
+
+// RegionError is the error of a call made in one region.
+type RegionError struct {
+	Region string
+
+	// Firewall is the name of the firewall the call was about, for the
+	// calls made per firewall.
+	Firewall string
+
+	Err error
+}
+
+func (e *RegionError) Error() string {
+	if e.Firewall != "" {
+		return fmt.Sprintf("%s: firewall %s: %s", e.Region, e.Firewall, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Region, e.Err)
+}
+
+func (e *RegionError) Unwrap() error {
+	return e.Err
+}
+
+/*
+MultiRegionError is returned by the calls of a MultiRegionClient that failed
+in some regions. The results of the other regions are still returned.
+*/
+type MultiRegionError struct {
+	Errors []*RegionError
+}
+
+func (e *MultiRegionError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("failed in %d regions: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Regions returns the regions that failed, each once.
+func (e *MultiRegionError) Regions() []string {
+	ans := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		if !slices.Contains(ans, err.Region) {
+			ans = append(ans, err.Region)
+		}
+	}
+	return ans
+}
+
+// RegionalFirewall is a firewall listed by a MultiRegionClient.
+type RegionalFirewall struct {
+	Region   string
+	Firewall firewall.ListFirewall
+}
+
+// RegionalRuleStacks are the rulestacks of a region listed by a
+// MultiRegionClient, all pages merged.
+type RegionalRuleStacks struct {
+	Region     string
+	RuleStacks stack.ListOutputDetails
+}
+
+// RegionalLogProfile is the log profile of a firewall read by a
+// MultiRegionClient.
+type RegionalLogProfile struct {
+	Region     string
+	Firewall   firewall.ListFirewall
+	LogProfile *logprofile.Info
+}
+
+/*
+MultiRegionClient runs list and read calls concurrently in several regions,
+for fleet-wide reads.
+
+It holds a Client per region, built from the same Config so they share their
+credentials, and sharing their connections. The hosts of each region are
+resolved from the region, see EndpointResolver; the Config's Host and V2Host
+are ignored.
+
+Calls return the merged results of the regions that succeeded, tagged with
+their region and in the order of the regions, and a MultiRegionError for the
+regions that failed.
+*/
+type MultiRegionClient struct {
+	regions []string
+	clients map[string]*Client
+}
+
+// NewMultiRegionClient returns a client for the given regions, which must be
+// closed once done with.
+func NewMultiRegionClient(cfg Config, regions ...string) (*MultiRegionClient, error) {
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no regions given")
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = regions[0]
+	}
+	loaded, err := LoadConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MultiRegionClient{clients: make(map[string]*Client, len(regions))}
+	var first *Client
+	for _, region := range regions {
+		if m.clients[region] != nil {
+			continue
+		}
+
+		rcfg := *loaded
+		rcfg.Region = region
+		rcfg.Host, rcfg.V2Host = "", ""
+		if err = rcfg.Validate(); err != nil {
+			m.Close()
+			return nil, fmt.Errorf("%s: %w", region, err)
+		}
+
+		c := &Client{}
+		if first != nil {
+			c.Transport = first.Transport
+			c.SecureHttpClient = first.SecureHttpClient
+		}
+		if c, err = clientFromConfig(&rcfg, c); err != nil {
+			m.Close()
+			return nil, fmt.Errorf("%s: %w", region, err)
+		}
+		if first == nil {
+			first = c
+		}
+		m.regions = append(m.regions, region)
+		m.clients[region] = c
+	}
+	return m, nil
+}
+
+// Regions returns the regions of the client.
+func (m *MultiRegionClient) Regions() []string {
+	return append([]string(nil), m.regions...)
+}
+
+// Client returns the client of a region, or nil.
+func (m *MultiRegionClient) Client(region string) *Client {
+	return m.clients[region]
+}
+
+/*
+Do calls fn concurrently in each region with the region's client.
+
+The regions where fn fails are returned in a MultiRegionError.
+*/
+func (m *MultiRegionClient) Do(ctx context.Context, fn func(ctx context.Context, region string, c *Client) error) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []*RegionError
+
+	for _, region := range m.regions {
+		wg.Add(1)
+		go func(region string, c *Client) {
+			defer wg.Done()
+			if err := fn(ctx, region, c); err != nil {
+				mu.Lock()
+				errs = append(errs, &RegionError{Region: region, Err: err})
+				mu.Unlock()
+			}
+		}(region, m.clients[region])
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Region < errs[j].Region
+	})
+	return &MultiRegionError{Errors: errs}
+}
+
+// ListFirewalls lists the firewalls of all the regions, walking all the
+// pages from input's NextToken.
+func (m *MultiRegionClient) ListFirewalls(ctx context.Context, input firewall.ListInput) ([]RegionalFirewall, error) {
+	var mu sync.Mutex
+	found := make(map[string][]RegionalFirewall)
+
+	err := m.Do(ctx, func(ctx context.Context, region string, c *Client) error {
+		var list []RegionalFirewall
+		p := api.NewPaginator(input.NextToken, func(ctx context.Context, token string) (firewall.ListOutput, string, error) {
+			in := input
+			in.NextToken = token
+			out, err := c.ListFirewall(ctx, in)
+			return out, out.Response.NextToken, err
+		})
+		err := p.Pages(ctx, func(out firewall.ListOutput) bool {
+			for _, fw := range out.Response.Firewalls {
+				list = append(list, RegionalFirewall{Region: region, Firewall: fw})
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		found[region] = list
+		mu.Unlock()
+		return nil
+	})
+
+	var ans []RegionalFirewall
+	for _, region := range m.regions {
+		ans = append(ans, found[region]...)
+	}
+	return ans, err
+}
+
+// ListRuleStacks lists the rulestacks of all the regions, walking all the
+// pages from input's NextToken.
+func (m *MultiRegionClient) ListRuleStacks(ctx context.Context, input stack.ListInput) ([]RegionalRuleStacks, error) {
+	var mu sync.Mutex
+	found := make(map[string]RegionalRuleStacks)
+
+	err := m.Do(ctx, func(ctx context.Context, region string, c *Client) error {
+		rs := RegionalRuleStacks{Region: region}
+		p := api.NewPaginator(input.NextToken, func(ctx context.Context, token string) (stack.ListOutput, string, error) {
+			in := input
+			in.NextToken = token
+			out, err := c.ListRuleStack(ctx, in)
+			if err != nil || out.Response == nil {
+				return out, "", err
+			}
+			return out, out.Response.NextToken, nil
+		})
+		err := p.Pages(ctx, func(out stack.ListOutput) bool {
+			if out.Response != nil {
+				rs.RuleStacks.Candidates = append(rs.RuleStacks.Candidates, out.Response.Candidates...)
+				rs.RuleStacks.Running = append(rs.RuleStacks.Running, out.Response.Running...)
+				rs.RuleStacks.Uncommitted = append(rs.RuleStacks.Uncommitted, out.Response.Uncommitted...)
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		found[region] = rs
+		mu.Unlock()
+		return nil
+	})
+
+	var ans []RegionalRuleStacks
+	for _, region := range m.regions {
+		if rs, ok := found[region]; ok {
+			ans = append(ans, rs)
+		}
+	}
+	return ans, err
+}
+
+/*
+ReadLogProfiles reads the log profiles of the given firewalls, as returned
+by ListFirewalls, concurrently in their regions.
+
+Each firewall that can't be read, including those of regions the client
+doesn't have, gets its own RegionError. The log profiles of the other
+firewalls are still returned.
+*/
+func (m *MultiRegionClient) ReadLogProfiles(ctx context.Context, firewalls []RegionalFirewall) ([]RegionalLogProfile, error) {
+	byRegion := make(map[string][]firewall.ListFirewall)
+	var errs []*RegionError
+	for _, fw := range firewalls {
+		if m.clients[fw.Region] == nil {
+			errs = append(errs, &RegionError{Region: fw.Region, Firewall: fw.Firewall.Name, Err: fmt.Errorf("the client has no such region")})
+			continue
+		}
+		byRegion[fw.Region] = append(byRegion[fw.Region], fw.Firewall)
+	}
+
+	var mu sync.Mutex
+	found := make(map[string][]RegionalLogProfile)
+
+	// The errors are kept per firewall, so fn never fails.
+	m.Do(ctx, func(ctx context.Context, region string, c *Client) error {
+		var list []RegionalLogProfile
+		for _, fw := range byRegion[region] {
+			out, err := c.ReadFirewallLogprofile(ctx, logprofile.ReadInput{
+				Firewall:   fw.Name,
+				FirewallId: fw.FirewallId,
+				AccountId:  fw.AccountId,
+			})
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, &RegionError{Region: region, Firewall: fw.Name, Err: err})
+				mu.Unlock()
+				continue
+			}
+			list = append(list, RegionalLogProfile{Region: region, Firewall: fw, LogProfile: out.Response})
+		}
+
+		mu.Lock()
+		found[region] = list
+		mu.Unlock()
+		return nil
+	})
+
+	var ans []RegionalLogProfile
+	for _, region := range m.regions {
+		ans = append(ans, found[region]...)
+	}
+	if len(errs) == 0 {
+		return ans, nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Region != errs[j].Region {
+			return errs[i].Region < errs[j].Region
+		}
+		return errs[i].Firewall < errs[j].Firewall
+	})
+	return ans, &MultiRegionError{Errors: errs}
+}
+
+// Close closes the clients of all the regions.
+func (m *MultiRegionClient) Close() error {
+	for _, c := range m.clients {
+		c.Close()
+	}
+	return nil
+}
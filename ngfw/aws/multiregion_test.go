@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestMultiRegionClientAgainstFake(t *testing.T) {
+	ctx := testContext()
+	east, west := ngfwtest.NewServer(), ngfwtest.NewServer()
+	defer east.Close()
+	defer west.Close()
+
+	m, err := NewMultiRegionClient(Config{
+		Protocol: "http",
+		Endpoints: map[string]Endpoints{
+			"us-east-1":  {Host: east.Host(), V2Host: east.Host()},
+			"eu-west-1":  {Host: west.Host(), V2Host: west.Host()},
+			"ap-south-1": {Host: west.Host(), V2Host: west.Host()},
+		},
+	}, "us-east-1", "eu-west-1", "ap-south-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, x := range []struct {
+		region string
+		srv    *ngfwtest.Server
+		names  []string
+	}{
+		{"us-east-1", east, []string{"fw1", "fw2"}},
+		{"eu-west-1", west, []string{"fw3"}},
+	} {
+		c := m.Client(x.region)
+		c.TenantVersion = awsngfw.TenantVersionV2
+		c.FirewallAdminJwt, c.FirewallSubscriptionKey = x.srv.Token(ngfwtest.PermFirewall)
+		c.RulestackAdminJwt, c.RulestackSubscriptionKey = x.srv.Token(ngfwtest.PermRulestack)
+		for _, name := range x.names {
+			if _, err = c.CreateFirewall(ctx, firewall.Info{Name: name, AccountId: "123456789012", VpcId: "vpc-1"}); err != nil {
+				t.Fatalf("create %s in %s: %s", name, x.region, err)
+			}
+		}
+	}
+
+	// ap-south-1 has no credentials, so it fails on its own.
+	fws, err := m.ListFirewalls(ctx, firewall.ListInput{MaxResults: 1})
+	var merr *MultiRegionError
+	if !errors.As(err, &merr) || strings.Join(merr.Regions(), ",") != "ap-south-1" {
+		t.Fatalf("expected ap-south-1 to fail alone, got %v", err)
+	}
+	var got []string
+	for _, fw := range fws {
+		got = append(got, fw.Region+"/"+fw.Firewall.Name)
+	}
+	// Firewalls are listed in region order, but by ID within a region.
+	sort.Strings(got[:2])
+	if strings.Join(got, ",") != "us-east-1/fw1,us-east-1/fw2,eu-west-1/fw3" {
+		t.Fatalf("unexpected firewalls: %v", got)
+	}
+
+	lps, err := m.ReadLogProfiles(ctx, fws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lps) != 3 || lps[2].Region != "eu-west-1" || lps[2].LogProfile == nil {
+		t.Fatalf("unexpected log profiles: %+v", lps)
+	}
+
+	// A firewall that can't be read doesn't take the others of its region
+	// down with it.
+	east.AddFault(ngfwtest.Fault{Path: "/v2/config/ngfirewalls/" + fws[0].Firewall.FirewallId, Count: 10, StatusCode: http.StatusBadRequest})
+	lps, err = m.ReadLogProfiles(ctx, fws)
+	if !errors.As(err, &merr) || len(merr.Errors) != 1 || merr.Errors[0].Firewall != fws[0].Firewall.Name {
+		t.Fatalf("expected %s to fail alone, got %v", fws[0].Firewall.Name, err)
+	}
+	if len(lps) != 2 || lps[0].Firewall.Name != fws[1].Firewall.Name || lps[1].Region != "eu-west-1" {
+		t.Fatalf("unexpected log profiles: %+v", lps)
+	}
+}
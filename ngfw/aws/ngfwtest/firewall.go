@@ -0,0 +1,451 @@
+package ngfwtest
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logprofile"
+)
+
+// Firewall states reported by the fake.
+const (
+	FirewallCreating       = "CREATING"
+	FirewallUpdating       = "UPDATING"
+	FirewallDeleting       = "DELETING"
+	FirewallCreateComplete = "CREATE_COMPLETE"
+	FirewallUpdateComplete = "UPDATE_COMPLETE"
+	FirewallDeleteComplete = "DELETE_COMPLETE"
+)
+
+// commitTimeLayout matches the layout expected by aws.ConvertToUTCEpoch.
+const commitTimeLayout = "2006-01-02T15:04:05 MST"
+
+type firewallState struct {
+	info         firewall.Info
+	region       string
+	status       firewall.FirewallStatus
+	target       string
+	pendingReads int
+	deleted      bool
+	logProfile   logprofile.Info
+}
+
+// transition moves the firewall into an in progress status that completes
+// with the given target status after Server.PendingPolls reads.
+func (o *firewallState) transition(s *Server, inProgress, target string) {
+	o.target = target
+	o.pendingReads = s.PendingPolls
+	o.status.FirewallStatus = inProgress
+	if o.pendingReads <= 0 {
+		o.status.FirewallStatus = target
+	}
+}
+
+// observe is invoked on every read of the firewall.
+func (o *firewallState) observe() {
+	if o.status.FirewallStatus == o.target {
+		return
+	}
+	if o.pendingReads > 0 {
+		o.pendingReads--
+		return
+	}
+	o.status.FirewallStatus = o.target
+}
+
+// rulestackCommitted records that the given rulestack was pushed to the
+// firewall.
+//
+// Commit timestamps are rounded up to the next second so that callers
+// comparing against a time.Now().Unix() taken before the request always
+// observe the commit as newer.
+func (o *firewallState) rulestackCommitted(s *Server, rs *rulestack) {
+	ts := s.Now().UTC().Truncate(time.Second).Add(time.Second).Format(commitTimeLayout)
+	info := &firewall.RuleStackCommitData{
+		CommitMessages: []string{},
+		CommitTS:       ts,
+	}
+	status := CommitSuccess
+	if rs.running == nil {
+		status = CommitPending
+	}
+	if rs.scope() == scopeGlobal {
+		o.status.GlobalRuleStackStatus = status
+		o.status.GlobalRuleStackCommitInfo = info
+	} else {
+		o.status.RulestackStatus = status
+		o.status.RuleStackCommitInfo = info
+	}
+}
+
+func (o *firewallState) readResponse() firewall.ReadResponse {
+	return firewall.ReadResponse{
+		Firewall: o.info,
+		Status:   o.status,
+	}
+}
+
+func (s *Server) handleFirewalls(req *request) {
+	v2 := req.path[0] == "v2"
+	if len(req.path) == 3 {
+		switch req.r.Method {
+		case http.MethodGet:
+			s.listFirewalls(req)
+		case http.MethodPost:
+			s.createFirewall(req)
+		default:
+			req.methodNotAllowed()
+		}
+		return
+	}
+
+	fw := s.findFirewall(req.path[3], v2)
+	if fw == nil {
+		req.notFound("firewall does not exist: %s", req.path[3])
+		return
+	}
+
+	if len(req.path) == 4 {
+		switch req.r.Method {
+		case http.MethodGet:
+			fw.observe()
+			req.ok(map[string]interface{}{"Response": fw.readResponse()})
+		case http.MethodPatch:
+			s.updateFirewall(req, fw)
+		case http.MethodDelete:
+			s.deleteFirewall(req, fw)
+		default:
+			req.methodNotAllowed()
+		}
+		return
+	}
+	if fw.deleted {
+		req.notFound("firewall does not exist: %s", req.path[3])
+		return
+	}
+
+	switch req.path[4] {
+	case "rulestack", "globalrulestack":
+		s.handleFirewallRulestack(req, fw)
+	case "logprofile":
+		s.handleLogProfile(req, fw)
+	case "description":
+		if req.r.Method != http.MethodPut {
+			req.methodNotAllowed()
+			return
+		}
+		var input firewall.UpdateDescriptionInput
+		if !req.decode(&input) {
+			return
+		}
+		fw.info.Description = input.Description
+		fw.info.UpdateToken = s.nextID("token")
+		req.ok(map[string]interface{}{})
+	case "tags":
+		s.handleFirewallTags(req, fw)
+	default:
+		req.unknownPath()
+	}
+}
+
+// findFirewall looks up a firewall by ID for v2 paths and by name for v1
+// paths.
+func (s *Server) findFirewall(key string, v2 bool) *firewallState {
+	if v2 {
+		return s.firewalls[key]
+	}
+	for _, fw := range s.firewalls {
+		if fw.info.Name == key && !fw.deleted {
+			return fw
+		}
+	}
+	return nil
+}
+
+func (s *Server) listFirewalls(req *request) {
+	rsName := req.query.Get("rulestackname")
+	region := req.query.Get("region")
+	ids := make([]string, 0, len(s.firewalls))
+	for id, fw := range s.firewalls {
+		if fw.deleted {
+			continue
+		}
+		if rsName != "" && fw.info.Rulestack != rsName && fw.info.GlobalRulestack != rsName {
+			continue
+		}
+		if region != "" && fw.region != region {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	token, max := req.page()
+	ids, next, ok := paginate(ids, token, max)
+	if !ok {
+		req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+		return
+	}
+
+	ans := firewall.ListOutputDetails{
+		Firewalls: make([]firewall.ListFirewall, 0, len(ids)),
+		NextToken: next,
+	}
+	describe := req.flag("Describe")
+	for _, id := range ids {
+		fw := s.firewalls[id]
+		ans.Firewalls = append(ans.Firewalls, firewall.ListFirewall{
+			Name:       fw.info.Name,
+			AccountId:  fw.info.AccountId,
+			FirewallId: id,
+			Region:     fw.region,
+		})
+		if describe {
+			ans.Describe = append(ans.Describe, fw.readResponse())
+		}
+	}
+
+	req.ok(map[string]interface{}{"Response": ans})
+}
+
+func (s *Server) createFirewall(req *request) {
+	var input firewall.Info
+	if !req.decode(&input) {
+		return
+	}
+	if input.Name == "" {
+		req.fail(http.StatusBadRequest, "invalid request: FirewallName is required")
+		return
+	}
+	for _, fw := range s.firewalls {
+		if !fw.deleted && fw.info.Name == input.Name && fw.info.AccountId == input.AccountId {
+			req.fail(http.StatusConflict, "firewall already exists: %s", input.Name)
+			return
+		}
+	}
+	if input.Rulestack != "" {
+		if _, ok := s.rulestacks[input.Rulestack]; !ok {
+			req.notFound("rulestack does not exist: %s", input.Rulestack)
+			return
+		}
+	}
+
+	region := req.query.Get("region")
+	if region == "" {
+		region = DefaultRegion
+	}
+	input.Id = s.nextID("fw")
+	input.UpdateToken = s.nextID("token")
+	input.DeploymentUpdateToken = s.nextID("deploy")
+	fw := &firewallState{
+		info:   input,
+		region: region,
+	}
+	fw.transition(s, FirewallCreating, FirewallCreateComplete)
+	if rs := s.rulestacks[input.Rulestack]; rs != nil {
+		fw.rulestackCommitted(s, rs)
+	}
+	s.firewalls[input.Id] = fw
+
+	req.ok(map[string]interface{}{"Response": fw.info})
+}
+
+func (s *Server) updateFirewall(req *request, fw *firewallState) {
+	if fw.deleted {
+		req.notFound("firewall does not exist: %s", fw.info.Id)
+		return
+	}
+	var input firewall.Info
+	if !req.decode(&input) {
+		return
+	}
+	if !s.checkToken(req, input.UpdateToken, fw.info.UpdateToken) {
+		return
+	}
+
+	cur := &fw.info
+	cur.Description = input.Description
+	if input.Tags != nil {
+		cur.Tags = input.Tags
+	}
+	if input.AllowListAccounts != nil {
+		cur.AllowListAccounts = input.AllowListAccounts
+	}
+	if input.ChangeProtection != nil {
+		cur.ChangeProtection = input.ChangeProtection
+	}
+	if input.AppIdVersion != "" {
+		cur.AppIdVersion = input.AppIdVersion
+	}
+	cur.EgressNAT = input.EgressNAT
+	cur.UserID = input.UserID
+	cur.PrivateAccess = input.PrivateAccess
+	if input.SecurityZones != nil {
+		cur.SecurityZones = input.SecurityZones
+	}
+	if input.Endpoints != nil {
+		cur.Endpoints = input.Endpoints
+		cur.DeploymentUpdateToken = s.nextID("deploy")
+	}
+	cur.UpdateToken = s.nextID("token")
+	fw.transition(s, FirewallUpdating, FirewallUpdateComplete)
+
+	req.ok(map[string]interface{}{
+		"Response": firewall.UpdateResponse{
+			Info:                  *cur,
+			UpdateToken:           cur.UpdateToken,
+			FirewallId:            cur.Id,
+			Region:                fw.region,
+			DeploymentUpdateToken: cur.DeploymentUpdateToken,
+		},
+	})
+}
+
+func (s *Server) deleteFirewall(req *request, fw *firewallState) {
+	if fw.deleted {
+		req.notFound("firewall does not exist: %s", fw.info.Id)
+		return
+	}
+	fw.deleted = true
+	fw.transition(s, FirewallDeleting, FirewallDeleteComplete)
+	token := s.nextID("token")
+
+	req.ok(map[string]interface{}{
+		"Response": firewall.DeleteResponse{
+			Info:           fw.info,
+			FirewallId:     fw.info.Id,
+			FirewallStatus: fw.status.FirewallStatus,
+			UpdateToken:    &token,
+		},
+	})
+}
+
+func (s *Server) handleFirewallRulestack(req *request, fw *firewallState) {
+	switch req.r.Method {
+	case http.MethodPost:
+		var input firewall.AssociateInput
+		if !req.decode(&input) {
+			return
+		}
+		if !s.checkToken(req, input.UpdateToken, fw.info.UpdateToken) {
+			return
+		}
+		rs := s.rulestacks[input.Rulestack]
+		if rs == nil {
+			req.notFound("rulestack does not exist: %s", input.Rulestack)
+			return
+		}
+		if rs.scope() == scopeGlobal {
+			fw.info.GlobalRulestack = rs.name
+		} else {
+			fw.info.Rulestack = rs.name
+		}
+		fw.info.UpdateToken = s.nextID("token")
+		fw.rulestackCommitted(s, rs)
+		req.ok(map[string]interface{}{
+			"Response": firewall.AssociateOutputDetails{
+				Rulestack:   rs.name,
+				Firewall:    fw.info.Name,
+				AccountId:   fw.info.AccountId,
+				UpdateToken: fw.info.UpdateToken,
+			},
+		})
+	case http.MethodDelete:
+		var input firewall.DisAssociateInput
+		if !req.decode(&input) {
+			return
+		}
+		if !s.checkToken(req, input.UpdateToken, fw.info.UpdateToken) {
+			return
+		}
+		global := req.path[4] == "globalrulestack" || (fw.info.Rulestack == "" && fw.info.GlobalRulestack != "")
+		name := fw.info.Rulestack
+		if global {
+			name = fw.info.GlobalRulestack
+		}
+		if name == "" {
+			req.notFound("rulestack association does not exist for firewall %s", fw.info.Name)
+			return
+		}
+		if global {
+			fw.info.GlobalRulestack = ""
+			fw.status.GlobalRuleStackStatus = ""
+			fw.status.GlobalRuleStackCommitInfo = nil
+		} else {
+			fw.info.Rulestack = ""
+			fw.status.RulestackStatus = ""
+			fw.status.RuleStackCommitInfo = nil
+		}
+		fw.info.UpdateToken = s.nextID("token")
+		req.ok(map[string]interface{}{
+			"Response": firewall.AssociateOutputDetails{
+				Rulestack:   name,
+				Firewall:    fw.info.Name,
+				AccountId:   fw.info.AccountId,
+				UpdateToken: fw.info.UpdateToken,
+			},
+		})
+	default:
+		req.methodNotAllowed()
+	}
+}
+
+func (s *Server) handleLogProfile(req *request, fw *firewallState) {
+	switch req.r.Method {
+	case http.MethodGet:
+		lp := fw.logProfile
+		lp.Firewall = fw.info.Name
+		lp.FirewallId = fw.info.Id
+		lp.AccountId = fw.info.AccountId
+		lp.Region = fw.region
+		req.ok(map[string]interface{}{"Response": lp})
+	case http.MethodPost, http.MethodPut:
+		var input logprofile.Info
+		if !req.decode(&input) {
+			return
+		}
+		if !s.checkToken(req, input.UpdateToken, fw.logProfile.UpdateToken) {
+			return
+		}
+		input.UpdateToken = s.nextID("token")
+		fw.logProfile = input
+		req.ok(map[string]interface{}{})
+	default:
+		req.methodNotAllowed()
+	}
+}
+
+func (s *Server) handleFirewallTags(req *request, fw *firewallState) {
+	switch req.r.Method {
+	case http.MethodGet:
+		req.ok(map[string]interface{}{
+			"Response": firewall.ListTagsOutputDetails{
+				Firewall: fw.info.Name,
+				Tags:     fw.info.Tags,
+			},
+		})
+	case http.MethodPost:
+		var input firewall.AddTagsInput
+		if !req.decode(&input) {
+			return
+		}
+		for _, t := range input.Tags {
+			fw.info.Tags = removeTag(fw.info.Tags, t.Key)
+			fw.info.Tags = append(fw.info.Tags, t)
+		}
+		req.ok(map[string]interface{}{})
+	case http.MethodDelete:
+		var input firewall.RemoveTagsInput
+		if !req.decode(&input) {
+			return
+		}
+		for _, key := range input.Tags {
+			fw.info.Tags = removeTag(fw.info.Tags, key)
+		}
+		req.ok(map[string]interface{}{})
+	default:
+		req.methodNotAllowed()
+	}
+}
@@ -0,0 +1,193 @@
+package ngfwtest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/account"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/appid"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/country"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/predefinedurl"
+)
+
+// Static content served by the fake.
+var (
+	Countries = []country.Country{
+		{Code: "US", Description: "United States"},
+		{Code: "DE", Description: "Germany"},
+		{Code: "JP", Description: "Japan"},
+	}
+
+	AppIDVersions = []string{"8595-7473", "8600-7500"}
+
+	Applications = []string{"dns", "ssl", "web-browsing"}
+
+	URLCategories = []predefinedurl.Category{
+		{Name: "adult", Action: "block"},
+		{Name: "gambling", Action: "alert"},
+		{Name: "news", Action: "allow"},
+	}
+)
+
+func (s *Server) handleAccounts(req *request) {
+	if len(req.path) == 3 {
+		switch req.r.Method {
+		case http.MethodGet:
+			ids := make([]string, 0, len(s.accounts))
+			for id := range s.accounts {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+
+			token, max := req.page()
+			ids, next, ok := paginate(ids, token, max)
+			if !ok {
+				req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+				return
+			}
+			ans := account.ListResponse{
+				AccountIds: ids,
+				NextToken:  next,
+			}
+			if req.flag("Describe") {
+				for _, id := range ids {
+					ans.AccountDetails = append(ans.AccountDetails, s.accounts[id].AccountDetail)
+				}
+			}
+			req.ok(map[string]interface{}{"Response": ans})
+		case http.MethodPost:
+			var input account.CreateInput
+			if !req.decode(&input) {
+				return
+			}
+			if len(input.AccountId) != 12 {
+				req.fail(http.StatusBadRequest, "invalid request: AccountId must be 12 digits")
+				return
+			}
+			if _, ok := s.accounts[input.AccountId]; ok {
+				req.fail(http.StatusConflict, "account %s is already linked", input.AccountId)
+				return
+			}
+			detail := account.AccountDetail{
+				AccountId:                 input.AccountId,
+				CloudFormationTemplateURL: fmt.Sprintf("%s/cft/%s.yaml", s.URL, input.AccountId),
+				OnboardingStatus:          "Pending",
+				ExternalId:                s.nextID("ext"),
+				ServiceAccountId:          "000000000000",
+				SNSTopicArn:               fmt.Sprintf("arn:aws:sns:%s:000000000000:%s", DefaultRegion, input.AccountId),
+			}
+			s.accounts[input.AccountId] = &account.ReadResponse{
+				AccountDetail: detail,
+				UpdateToken:   s.nextID("token"),
+			}
+			req.ok(map[string]interface{}{
+				"Response": account.Info{
+					TrustedAccount: detail.ServiceAccountId,
+					ExternalId:     detail.ExternalId,
+					SNSTopicArn:    detail.SNSTopicArn,
+					Origin:         input.Origin,
+				},
+			})
+		default:
+			req.methodNotAllowed()
+		}
+		return
+	}
+
+	acct := s.accounts[req.path[3]]
+	if len(req.path) != 4 || acct == nil {
+		req.notFound("account does not exist: %s", req.path[3])
+		return
+	}
+	switch req.r.Method {
+	case http.MethodGet:
+		req.ok(map[string]interface{}{"Response": acct})
+	case http.MethodDelete:
+		delete(s.accounts, req.path[3])
+		req.ok(map[string]interface{}{})
+	default:
+		req.methodNotAllowed()
+	}
+}
+
+func (s *Server) handleCountries(req *request) {
+	if req.r.Method != http.MethodGet {
+		req.methodNotAllowed()
+		return
+	}
+	token, max := req.page()
+	start, end, next, ok := window(len(Countries), token, max)
+	if !ok {
+		req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+		return
+	}
+	req.ok(map[string]interface{}{
+		"Response": country.ListOutputDetails{
+			Countries: Countries[start:end],
+			NextToken: next,
+		},
+	})
+}
+
+func (s *Server) handleAppIDs(req *request) {
+	if req.r.Method != http.MethodGet {
+		req.methodNotAllowed()
+		return
+	}
+	switch len(req.path) {
+	case 3:
+		token, max := req.page()
+		versions, next, ok := paginate(AppIDVersions, token, max)
+		if !ok {
+			req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+			return
+		}
+		req.ok(map[string]interface{}{
+			"Response": appid.ListOutputDetails{
+				Versions:  versions,
+				NextToken: next,
+			},
+		})
+	case 6:
+		if req.path[4] != "appids" {
+			req.unknownPath()
+			return
+		}
+		for _, x := range Applications {
+			if x == req.path[5] {
+				req.ok(map[string]interface{}{
+					"Response": appid.ApplicationOutputDetails{
+						Name: x,
+						Details: appid.ApplicationDetails{
+							Description: x,
+						},
+					},
+				})
+				return
+			}
+		}
+		req.notFound("application does not exist: %s", req.path[5])
+	default:
+		req.unknownPath()
+	}
+}
+
+func (s *Server) handleURLCategories(req *request) {
+	if req.r.Method != http.MethodGet {
+		req.methodNotAllowed()
+		return
+	}
+	token, max := req.page()
+	start, end, next, ok := window(len(URLCategories), token, max)
+	if !ok {
+		req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+		return
+	}
+	req.ok(map[string]interface{}{
+		"Response": predefinedurl.ListResponse{
+			Categories: URLCategories[start:end],
+			NextToken:  next,
+		},
+	})
+}
@@ -0,0 +1,846 @@
+package ngfwtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/security"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/tag"
+)
+
+// Rulestack states and commit states reported by the fake.
+const (
+	StateUncommitted = "Uncommitted"
+	StateCommitted   = "Committed"
+
+	CommitPending = "Pending"
+	CommitSuccess = "Success"
+	CommitFailed  = "Failed"
+
+	scopeLocal  = "Local"
+	scopeGlobal = "Global"
+)
+
+// objectKind describes how a rulestack object collection is rendered.
+type objectKind struct {
+	listCandidate   string
+	listRunning     string
+	listUncommitted string
+	readCandidate   string
+	readRunning     string
+}
+
+var objectKinds = map[string]objectKind{
+	"prefixlists": {
+		listCandidate:   "PrefixListCandidate",
+		listRunning:     "PrefixListRunning",
+		listUncommitted: "PrefixListUncommitted",
+		readCandidate:   "PrefixListCandidate",
+		readRunning:     "PrefixListRunning",
+	},
+	"fqdnlists": {
+		listCandidate:   "FqdnListCandidate",
+		listRunning:     "FqdnListRunning",
+		listUncommitted: "FqdnListUncommitted",
+		readCandidate:   "FqdnListCandidate",
+		readRunning:     "FqdnListRunning",
+	},
+	"feeds": {
+		listCandidate:   "FeedCandidate",
+		listRunning:     "FeedRunning",
+		listUncommitted: "FeedUncommitted",
+		readCandidate:   "FeedCandidate",
+		readRunning:     "FeedRunning",
+	},
+	"certificates": {
+		listCandidate:   "CertificateObjectCandidate",
+		listRunning:     "CertificateObjectRunning",
+		listUncommitted: "CertificateObjectUncommitted",
+		readCandidate:   "CertificateObjectCandidate",
+		readRunning:     "CertificateObjectRunning",
+	},
+	"urlcustomcategories": {
+		listCandidate:   "CategoriesCandidate",
+		listRunning:     "CategoriesRunning",
+		listUncommitted: "CategoriesUncommitted",
+		readCandidate:   "URLCategoryCandidate",
+		readRunning:     "URLCategoryRunning",
+	},
+}
+
+// rulestackConfig is the committable part of a rulestack.
+type rulestackConfig struct {
+	Entry   stack.Details
+	Rules   map[string]map[int]security.Details
+	Objects map[string]map[string]map[string]interface{}
+	Actions map[string]map[string]interface{}
+}
+
+func newRulestackConfig(entry stack.Details) *rulestackConfig {
+	return &rulestackConfig{
+		Entry:   entry,
+		Rules:   make(map[string]map[int]security.Details),
+		Objects: make(map[string]map[string]map[string]interface{}),
+		Actions: make(map[string]map[string]interface{}),
+	}
+}
+
+func (o *rulestackConfig) clone() *rulestackConfig {
+	if o == nil {
+		return nil
+	}
+	b, _ := json.Marshal(o)
+	var ans rulestackConfig
+	json.Unmarshal(b, &ans)
+	return &ans
+}
+
+type rulestack struct {
+	name           string
+	candidate      *rulestackConfig
+	running        *rulestackConfig
+	dirty          bool
+	tags           []tag.Details
+	commitStatus   string
+	commitMessages []string
+	pendingPolls   int
+}
+
+func (o *rulestack) scope() string {
+	if o.candidate.Entry.Scope == "" {
+		return scopeLocal
+	}
+	return o.candidate.Entry.Scope
+}
+
+func (o *rulestack) state() string {
+	if o.running == nil || o.dirty {
+		return StateUncommitted
+	}
+	return StateCommitted
+}
+
+func (o *rulestack) touch(s *Server, e *stack.Details) {
+	o.dirty = true
+	e.UpdateToken = s.nextID("token")
+}
+
+// rulestackPerm returns the token permission needed for the given scope.
+func rulestackPerm(scope string) string {
+	if scope == scopeGlobal {
+		return PermGlobalRulestack
+	}
+	return PermRulestack
+}
+
+func (s *Server) handleRulestacks(req *request) {
+	if len(req.path) == 3 {
+		scope := req.query.Get("scope")
+		if req.r.Method == http.MethodPost {
+			var input stack.Info
+			if !req.decode(&input) {
+				return
+			}
+			scope = input.Entry.Scope
+		}
+		if !s.authorize(req, rulestackPerm(scope)) {
+			return
+		}
+		switch req.r.Method {
+		case http.MethodGet:
+			s.listRulestacks(req)
+		case http.MethodPost:
+			s.createRulestack(req)
+		default:
+			req.methodNotAllowed()
+		}
+		return
+	}
+
+	name := req.path[3]
+	rs := s.rulestacks[name]
+	if rs == nil {
+		if s.authorize(req, PermRulestack, PermGlobalRulestack) {
+			req.notFound("rulestack does not exist: %s", name)
+		}
+		return
+	}
+	if !s.authorize(req, rulestackPerm(rs.scope())) {
+		return
+	}
+
+	if len(req.path) == 4 {
+		switch req.r.Method {
+		case http.MethodGet:
+			s.readRulestack(req, rs)
+		case http.MethodPut:
+			s.updateRulestack(req, rs)
+		case http.MethodDelete:
+			s.deleteRulestack(req, rs)
+		default:
+			req.methodNotAllowed()
+		}
+		return
+	}
+
+	switch sub := req.path[4]; {
+	case sub == "commit" && len(req.path) == 5:
+		s.handleCommit(req, rs)
+	case sub == "validate" && len(req.path) == 5:
+		s.handleValidate(req, rs)
+	case sub == "revert" && len(req.path) == 5:
+		s.handleRevert(req, rs)
+	case sub == "export" && len(req.path) == 5:
+		s.handleExport(req, rs)
+	case (sub == "xml" || sub == "scm") && len(req.path) == 5:
+		if req.r.Method != http.MethodPost {
+			req.methodNotAllowed()
+			return
+		}
+		req.ok(map[string]interface{}{})
+	case sub == "tags" && len(req.path) == 5:
+		s.handleRulestackTags(req, rs)
+	case sub == "rulelists":
+		s.handleRules(req, rs)
+	case sub == "urlfilteringprofiles":
+		s.handleURLOverrides(req, rs)
+	default:
+		if _, ok := objectKinds[sub]; ok {
+			s.handleObjects(req, rs, sub)
+			return
+		}
+		req.unknownPath()
+	}
+}
+
+func (s *Server) listRulestacks(req *request) {
+	scope := req.query.Get("scope")
+	names := make([]string, 0, len(s.rulestacks))
+	for name, rs := range s.rulestacks {
+		if scope != "" && rs.scope() != scope {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	token, max := req.page()
+	names, next, ok := paginate(names, token, max)
+	if !ok {
+		req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+		return
+	}
+
+	ans := stack.ListOutputDetails{
+		Candidates:  names,
+		Running:     []string{},
+		Uncommitted: []stack.ListUncommitted{},
+		NextToken:   next,
+	}
+	for _, name := range names {
+		rs := s.rulestacks[name]
+		if rs.running != nil {
+			ans.Running = append(ans.Running, name)
+		}
+		if rs.state() == StateUncommitted {
+			op := "Update"
+			if rs.running == nil {
+				op = "Create"
+			}
+			ans.Uncommitted = append(ans.Uncommitted, stack.ListUncommitted{Name: name, Operation: op})
+		}
+	}
+
+	req.ok(map[string]interface{}{"Response": ans})
+}
+
+func (s *Server) createRulestack(req *request) {
+	var input stack.Info
+	if !req.decode(&input) {
+		return
+	}
+	if input.Name == "" {
+		req.fail(http.StatusBadRequest, "invalid request: RuleStackName is required")
+		return
+	}
+	if _, ok := s.rulestacks[input.Name]; ok {
+		req.fail(http.StatusConflict, "rulestack already exists: %s", input.Name)
+		return
+	}
+	if input.Entry.Scope == "" {
+		input.Entry.Scope = scopeLocal
+	}
+
+	rs := &rulestack{
+		name:      input.Name,
+		candidate: newRulestackConfig(input.Entry),
+		tags:      input.Entry.Tags,
+	}
+	rs.touch(s, &rs.candidate.Entry)
+	s.rulestacks[input.Name] = rs
+
+	req.ok(map[string]interface{}{
+		"Response": map[string]interface{}{
+			"RuleStackName":  input.Name,
+			"RuleStackEntry": rs.candidate.Entry,
+		},
+	})
+}
+
+func (s *Server) readRulestack(req *request, rs *rulestack) {
+	ans := stack.ReadResponse{
+		Name:  rs.name,
+		State: rs.state(),
+	}
+	running := req.flag("Running")
+	if !running || req.flag("Candidate") {
+		e := rs.candidate.Entry
+		ans.Candidate = &e
+	}
+	if running && rs.running != nil {
+		e := rs.running.Entry
+		ans.Running = &e
+	}
+
+	req.ok(map[string]interface{}{"Response": ans})
+}
+
+func (s *Server) updateRulestack(req *request, rs *rulestack) {
+	var input stack.Info
+	if !req.decode(&input) {
+		return
+	}
+	if !s.checkToken(req, input.Entry.UpdateToken, rs.candidate.Entry.UpdateToken) {
+		return
+	}
+	if input.Entry.Scope == "" {
+		input.Entry.Scope = rs.scope()
+	}
+	if input.Entry.Scope != rs.scope() {
+		req.fail(http.StatusBadRequest, "invalid request: rulestack scope cannot be changed")
+		return
+	}
+
+	rs.candidate.Entry = input.Entry
+	rs.touch(s, &rs.candidate.Entry)
+	req.ok(map[string]interface{}{})
+}
+
+func (s *Server) deleteRulestack(req *request, rs *rulestack) {
+	for _, fw := range s.firewalls {
+		if !fw.deleted && (fw.info.Rulestack == rs.name || fw.info.GlobalRulestack == rs.name) {
+			req.fail(http.StatusConflict, "rulestack %s is associated with firewall %s", rs.name, fw.info.Name)
+			return
+		}
+	}
+	delete(s.rulestacks, rs.name)
+	req.ok(map[string]interface{}{})
+}
+
+func (s *Server) handleCommit(req *request, rs *rulestack) {
+	switch req.r.Method {
+	case http.MethodPost:
+		rs.commitMessages = nil
+		rs.commitStatus = CommitPending
+		rs.pendingPolls = s.PendingPolls
+		if rs.pendingPolls <= 0 {
+			s.finishCommit(rs)
+		}
+		req.ok(map[string]interface{}{})
+	case http.MethodGet:
+		if rs.commitStatus == "" {
+			req.fail(http.StatusBadRequest, "rulestack %s has never been committed", rs.name)
+			return
+		}
+		if rs.commitStatus == CommitPending {
+			if rs.pendingPolls > 0 {
+				rs.pendingPolls--
+			} else {
+				s.finishCommit(rs)
+			}
+		}
+		req.ok(map[string]interface{}{
+			"Response": stack.CommitResponse{
+				Name:               rs.name,
+				CommitStatus:       rs.commitStatus,
+				ValidationStatus:   CommitSuccess,
+				CommitMessages:     rs.commitMessages,
+				ValidationMessages: []string{},
+			},
+		})
+	default:
+		req.methodNotAllowed()
+	}
+}
+
+func (s *Server) finishCommit(rs *rulestack) {
+	if msgs := s.validate(rs); len(msgs) > 0 {
+		rs.commitStatus = CommitFailed
+		rs.commitMessages = msgs
+		return
+	}
+
+	rs.running = rs.candidate.clone()
+	rs.dirty = false
+	rs.commitStatus = CommitSuccess
+	rs.commitMessages = []string{}
+
+	// Firewalls using this rulestack pick up the new config.
+	for _, fw := range s.firewalls {
+		if fw.info.Rulestack == rs.name || fw.info.GlobalRulestack == rs.name {
+			fw.rulestackCommitted(s, rs)
+		}
+	}
+}
+
+// validate returns the validation errors of the rulestack candidate config.
+func (s *Server) validate(rs *rulestack) []string {
+	var msgs []string
+	for list, rules := range rs.candidate.Rules {
+		for priority, rule := range rules {
+			refs := append([]string{}, rule.Source.PrefixLists...)
+			refs = append(refs, rule.Destination.PrefixLists...)
+			for _, x := range refs {
+				if _, ok := rs.candidate.Objects["prefixlists"][x]; !ok {
+					msgs = append(msgs, "rule "+list+"/"+strconv.Itoa(priority)+" references unknown prefix list "+x)
+				}
+			}
+			for _, x := range rule.Destination.FqdnLists {
+				if _, ok := rs.candidate.Objects["fqdnlists"][x]; !ok {
+					msgs = append(msgs, "rule "+list+"/"+strconv.Itoa(priority)+" references unknown fqdn list "+x)
+				}
+			}
+		}
+	}
+	sort.Strings(msgs)
+	return msgs
+}
+
+func (s *Server) handleValidate(req *request, rs *rulestack) {
+	if req.r.Method != http.MethodPost {
+		req.methodNotAllowed()
+		return
+	}
+	if msgs := s.validate(rs); len(msgs) > 0 {
+		req.fail(http.StatusBadRequest, "invalid request: %s", strings.Join(msgs, "; "))
+		return
+	}
+	req.ok(map[string]interface{}{})
+}
+
+func (s *Server) handleRevert(req *request, rs *rulestack) {
+	if req.r.Method != http.MethodPost {
+		req.methodNotAllowed()
+		return
+	}
+	if rs.running == nil {
+		req.fail(http.StatusBadRequest, "rulestack %s has no running config to revert to", rs.name)
+		return
+	}
+	rs.candidate = rs.running.clone()
+	rs.dirty = false
+	req.ok(map[string]interface{}{})
+}
+
+func (s *Server) handleExport(req *request, rs *rulestack) {
+	if req.r.Method != http.MethodGet {
+		req.methodNotAllowed()
+		return
+	}
+	req.ok(map[string]interface{}{
+		"Response": "<config><rulestack name=\"" + rs.name + "\"/></config>",
+	})
+}
+
+func (s *Server) handleRulestackTags(req *request, rs *rulestack) {
+	switch req.r.Method {
+	case http.MethodGet:
+		req.ok(map[string]interface{}{
+			"Response": stack.ListTagsOutputDetails{
+				Rulestack: rs.name,
+				Tags:      rs.tags,
+			},
+		})
+	case http.MethodPost:
+		var input stack.AddTagsInput
+		if !req.decode(&input) {
+			return
+		}
+		for _, t := range input.Tags {
+			rs.tags = removeTag(rs.tags, t.Key)
+			rs.tags = append(rs.tags, t)
+		}
+		req.ok(map[string]interface{}{})
+	case http.MethodDelete:
+		var input stack.RemoveTagsInput
+		if !req.decode(&input) {
+			return
+		}
+		for _, key := range input.Tags {
+			rs.tags = removeTag(rs.tags, key)
+		}
+		req.ok(map[string]interface{}{})
+	default:
+		req.methodNotAllowed()
+	}
+}
+
+func removeTag(tags []tag.Details, key string) []tag.Details {
+	ans := tags[:0]
+	for _, t := range tags {
+		if t.Key != key {
+			ans = append(ans, t)
+		}
+	}
+	return ans
+}
+
+func (s *Server) handleRules(req *request, rs *rulestack) {
+	if len(req.path) < 6 {
+		req.unknownPath()
+		return
+	}
+	list := req.path[5]
+	switch list {
+	case security.LOCAL_RULE:
+		if rs.scope() != scopeLocal {
+			req.fail(http.StatusBadRequest, "invalid request: %s is not valid for a %s rulestack", list, rs.scope())
+			return
+		}
+	case security.PRE_RULE, security.POST_RULE:
+		if rs.scope() != scopeGlobal {
+			req.fail(http.StatusBadRequest, "invalid request: %s is not valid for a %s rulestack", list, rs.scope())
+			return
+		}
+	default:
+		req.fail(http.StatusBadRequest, "invalid request: unknown rule list %s", list)
+		return
+	}
+
+	rules := rs.candidate.Rules[list]
+	if rules == nil {
+		rules = make(map[int]security.Details)
+		rs.candidate.Rules[list] = rules
+	}
+
+	if len(req.path) == 6 {
+		switch req.r.Method {
+		case http.MethodGet:
+			s.listRules(req, rs, list)
+		case http.MethodPost:
+			var input security.Info
+			if !req.decode(&input) {
+				return
+			}
+			if _, ok := rules[input.Priority]; ok {
+				req.fail(http.StatusConflict, "rule with priority %d already exists in %s", input.Priority, list)
+				return
+			}
+			input.Entry.UpdateToken = s.nextID("token")
+			rules[input.Priority] = input.Entry
+			rs.dirty = true
+			req.ok(map[string]interface{}{})
+		default:
+			req.methodNotAllowed()
+		}
+		return
+	}
+
+	if len(req.path) != 8 || req.path[6] != "priorities" {
+		req.unknownPath()
+		return
+	}
+	priority, err := strconv.Atoi(req.path[7])
+	if err != nil {
+		req.fail(http.StatusBadRequest, "invalid request: bad priority %q", req.path[7])
+		return
+	}
+	cur, ok := rules[priority]
+	if !ok && req.r.Method != http.MethodGet {
+		req.notFound("rule with priority %d does not exist in %s", priority, list)
+		return
+	}
+
+	switch req.r.Method {
+	case http.MethodGet:
+		ans := security.ReadResponse{
+			Rulestack: rs.name,
+			RuleList:  list,
+			Priority:  priority,
+		}
+		if ok {
+			ans.Candidate = &cur
+		}
+		if rs.running != nil {
+			if x, found := rs.running.Rules[list][priority]; found {
+				ans.Running = &x
+			}
+		}
+		if ans.Candidate == nil && ans.Running == nil {
+			req.notFound("rule with priority %d does not exist in %s", priority, list)
+			return
+		}
+		req.ok(map[string]interface{}{"Response": ans})
+	case http.MethodPut:
+		var input security.Info
+		if !req.decode(&input) {
+			return
+		}
+		if !s.checkToken(req, input.Entry.UpdateToken, cur.UpdateToken) {
+			return
+		}
+		input.Entry.UpdateToken = s.nextID("token")
+		rules[priority] = input.Entry
+		rs.dirty = true
+		req.ok(map[string]interface{}{})
+	case http.MethodDelete:
+		delete(rules, priority)
+		rs.dirty = true
+		req.ok(map[string]interface{}{})
+	default:
+		req.methodNotAllowed()
+	}
+}
+
+func (s *Server) listRules(req *request, rs *rulestack, list string) {
+	toEntries := func(rules map[int]security.Details) []security.ListEntryCandidate {
+		ans := make([]security.ListEntryCandidate, 0, len(rules))
+		for p, x := range rules {
+			ans = append(ans, security.ListEntryCandidate{Name: x.Name, Priority: p})
+		}
+		sort.Slice(ans, func(i, j int) bool { return ans[i].Priority < ans[j].Priority })
+		return ans
+	}
+
+	ans := security.ListOutputDetails{
+		Rulestack:  rs.name,
+		RuleList:   list,
+		Candidates: toEntries(rs.candidate.Rules[list]),
+	}
+	if rs.running != nil {
+		ans.Running = toEntries(rs.running.Rules[list])
+	}
+
+	token, max := req.page()
+	start, end, next, ok := window(len(ans.Candidates), token, max)
+	if !ok {
+		req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+		return
+	}
+	ans.Candidates = ans.Candidates[start:end]
+	ans.NextToken = next
+
+	req.ok(map[string]interface{}{"Response": ans})
+}
+
+func (s *Server) handleObjects(req *request, rs *rulestack, kind string) {
+	info := objectKinds[kind]
+	objs := rs.candidate.Objects[kind]
+	if objs == nil {
+		objs = make(map[string]map[string]interface{})
+		rs.candidate.Objects[kind] = objs
+	}
+
+	if len(req.path) == 5 {
+		switch req.r.Method {
+		case http.MethodGet:
+			names := sortedKeys(objs)
+			token, max := req.page()
+			names, next, ok := paginate(names, token, max)
+			if !ok {
+				req.fail(http.StatusBadRequest, "invalid request: bad NextToken %q", token)
+				return
+			}
+			var running []string
+			if rs.running != nil {
+				running = sortedKeys(rs.running.Objects[kind])
+			}
+			req.ok(map[string]interface{}{
+				"Response": map[string]interface{}{
+					"RuleStackName":      rs.name,
+					info.listCandidate:   names,
+					info.listRunning:     running,
+					info.listUncommitted: []interface{}{},
+					"NextToken":          next,
+				},
+			})
+		case http.MethodPost:
+			var input map[string]interface{}
+			if !req.decode(&input) {
+				return
+			}
+			name, _ := input["Name"].(string)
+			if name == "" {
+				req.fail(http.StatusBadRequest, "invalid request: Name is required")
+				return
+			}
+			if _, ok := objs[name]; ok {
+				req.fail(http.StatusConflict, "%s %s already exists", kind, name)
+				return
+			}
+			input["UpdateToken"] = s.nextID("token")
+			objs[name] = input
+			rs.dirty = true
+			req.ok(map[string]interface{}{})
+		default:
+			req.methodNotAllowed()
+		}
+		return
+	}
+
+	if len(req.path) != 6 {
+		req.unknownPath()
+		return
+	}
+	name := req.path[5]
+	cur, ok := objs[name]
+	var running map[string]interface{}
+	if rs.running != nil {
+		running = rs.running.Objects[kind][name]
+	}
+	if !ok && (req.r.Method != http.MethodGet || running == nil) {
+		req.notFound("%s %s does not exist", kind, name)
+		return
+	}
+
+	switch req.r.Method {
+	case http.MethodGet:
+		ans := map[string]interface{}{
+			"RuleStackName": rs.name,
+			"Name":          name,
+		}
+		if ok {
+			ans[info.readCandidate] = cur
+		}
+		if running != nil {
+			ans[info.readRunning] = running
+		}
+		req.ok(map[string]interface{}{"Response": ans})
+	case http.MethodPut:
+		var input map[string]interface{}
+		if !req.decode(&input) {
+			return
+		}
+		given, _ := input["UpdateToken"].(string)
+		have, _ := cur["UpdateToken"].(string)
+		if !s.checkToken(req, given, have) {
+			return
+		}
+		input["Name"] = name
+		input["UpdateToken"] = s.nextID("token")
+		objs[name] = input
+		rs.dirty = true
+		req.ok(map[string]interface{}{})
+	case http.MethodDelete:
+		delete(objs, name)
+		rs.dirty = true
+		req.ok(map[string]interface{}{})
+	default:
+		req.methodNotAllowed()
+	}
+}
+
+// handleURLOverrides serves the predefined URL category action overrides
+// under urlfilteringprofiles/custom/urlcategories.
+func (s *Server) handleURLOverrides(req *request, rs *rulestack) {
+	if len(req.path) < 7 || req.path[5] != "custom" || req.path[6] != "urlcategories" {
+		req.unknownPath()
+		return
+	}
+
+	switch {
+	case len(req.path) == 7 && req.r.Method == http.MethodGet:
+		var running []string
+		if rs.running != nil {
+			running = sortedKeys(rs.running.Actions)
+		}
+		req.ok(map[string]interface{}{
+			"Response": map[string]interface{}{
+				"RuleStackName":         rs.name,
+				"Candidate":             sortedKeys(rs.candidate.Actions),
+				"Running":               running,
+				"CategoriesUncommitted": []interface{}{},
+			},
+		})
+	case len(req.path) == 8 && req.r.Method == http.MethodGet:
+		name := req.path[7]
+		ans := map[string]interface{}{
+			"RuleStackName":        rs.name,
+			"Name":                 name,
+			"URLCategoryCandidate": rs.candidate.Actions[name],
+		}
+		if rs.running != nil {
+			ans["URLCategoryRunning"] = rs.running.Actions[name]
+		}
+		req.ok(map[string]interface{}{"Response": ans})
+	case len(req.path) == 9 && req.path[8] == "action" && req.r.Method == http.MethodPut:
+		name := req.path[7]
+		var input map[string]interface{}
+		if !req.decode(&input) {
+			return
+		}
+		given, _ := input["UpdateToken"].(string)
+		have, _ := rs.candidate.Actions[name]["UpdateToken"].(string)
+		if !s.checkToken(req, given, have) {
+			return
+		}
+		input["UpdateToken"] = s.nextID("token")
+		rs.candidate.Actions[name] = input
+		rs.dirty = true
+		req.ok(map[string]interface{}{})
+	default:
+		req.unknownPath()
+	}
+}
+
+// checkToken verifies an optimistic locking update token.  An empty given
+// token skips the check, as the real service does.
+func (s *Server) checkToken(req *request, given, have string) bool {
+	if given == "" || given == have {
+		return true
+	}
+	req.fail(http.StatusConflict, "update token mismatch, please provide latest token")
+	return false
+}
+
+func sortedKeys(m map[string]map[string]interface{}) []string {
+	ans := make([]string, 0, len(m))
+	for k := range m {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+// window computes the [start, end) bounds of a page of n items.
+func window(n int, token string, max int) (int, int, string, bool) {
+	start := 0
+	if token != "" {
+		v, err := strconv.Atoi(token)
+		if err != nil || v < 0 || v > n {
+			return 0, 0, "", false
+		}
+		start = v
+	}
+	end := n
+	if max > 0 && start+max < n {
+		end = start + max
+	}
+	var next string
+	if end < n {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next, true
+}
+
+func paginate(names []string, token string, max int) ([]string, string, bool) {
+	start, end, next, ok := window(len(names), token, max)
+	if !ok {
+		return nil, "", false
+	}
+	return names[start:end], next, true
+}
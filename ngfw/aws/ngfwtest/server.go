@@ -0,0 +1,549 @@
+/*
+Package ngfwtest provides an in-process fake of the Cloud NGFW API for
+hermetic testing.
+
+The fake serves the v1 and v2 config endpoints, the mgmt token endpoints and
+the externalID based cloudmanager auth endpoint from an httptest.Server, and
+keeps rulestacks, security rules, objects, firewalls and commit states in
+memory so that full create, commit and associate workflows can be run
+offline.
+
+Point an aws.Client at the fake either through Setup():
+
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	c := &aws.Client{
+		Host:     srv.Host(),
+		V2Host:   srv.Host(),
+		Protocol: "http",
+		Region:   ngfwtest.DefaultRegion,
+	}
+
+or through the externalID flow, which also exercises the JWT refresh path:
+
+	c := &aws.Client{AuthType: aws.AuthTypeExternalID}
+	c.SetupUsingCreds(ctx, aws.AuthInfo{
+		ExternalID:       "tenant",
+		Region:           ngfwtest.DefaultRegion,
+		HttpClient:       srv.Client(),
+		SecureHttpClient: srv.Client(),
+		RegionURL:        srv.URL,
+		RegionV2URL:      srv.URL,
+		AuthURL:          srv.URL,
+	})
+*/
+package ngfwtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/account"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/response"
+)
+
+// Defaults used by the fake when the caller does not override them.
+const (
+	DefaultRegion        = "us-east-1"
+	DefaultTenant        = "ngfwtest"
+	DefaultTenantVersion = "V2"
+
+	// TokenExpiryMinutes is the lifetime reported for every minted JWT.
+	TokenExpiryMinutes = 60
+)
+
+// Token permissions minted by the fake.  These mirror the mgmt token
+// endpoints of the real service.
+const (
+	PermFirewall        = "cloudfirewalladmin"
+	PermRulestack       = "cloudrulestackadmin"
+	PermGlobalRulestack = "cloudglobalrulestackadmin"
+	PermAccount         = "cloudaccountadmin"
+	PermCloudManager    = "cloudmanager"
+	PermPanorama        = "panorama"
+)
+
+// Request is a request that was received by the fake.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// Server is an in-process fake of the Cloud NGFW API.
+//
+// The exported fields may be changed before the first request is sent.
+type Server struct {
+	*httptest.Server
+
+	// Tenant and TenantVersion are minted into the JWT claims.
+	Tenant        string
+	TenantVersion string
+
+	// ExternalIDs restricts the external IDs accepted by the cloudmanager
+	// auth endpoint.  An empty list accepts any external ID.
+	ExternalIDs []string
+
+	// PendingPolls is the number of status reads that a firewall operation
+	// or a rulestack commit stays in progress before it completes.
+	PendingPolls int
+
+	// Now returns the current time.  It defaults to time.Now.
+	Now func() time.Time
+
+	mu         sync.Mutex
+	seq        int
+	faults     []*Fault
+	key        []byte
+	tokens     map[string]tokenInfo
+	requests   []Request
+	rulestacks map[string]*rulestack
+	firewalls  map[string]*firewallState
+	accounts   map[string]*account.ReadResponse
+}
+
+/*
+Fault makes the fake answer matching requests with an error instead of
+handling them.
+
+Faults are checked in the order they were added, and a fault stops matching
+once it has been served Count times.
+*/
+type Fault struct {
+	// Method and Path select the requests to fail.  An empty Method matches
+	// every method, and Path is matched as a prefix of the URL path.
+	Method string
+	Path   string
+
+	// Count is the number of requests to fail.
+	Count int
+
+	// StatusCode is the HTTP status code to answer with.
+	StatusCode int
+
+	// RetryAfter, if set, is sent as the Retry-After header.
+	RetryAfter string
+}
+
+// AddFault registers a fault.
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+func (s *Server) fault(r *http.Request) *Fault {
+	for _, f := range s.faults {
+		if f.Count <= 0 || (f.Method != "" && f.Method != r.Method) || !strings.HasPrefix(r.URL.Path, f.Path) {
+			continue
+		}
+		f.Count--
+		return f
+	}
+	return nil
+}
+
+type tokenInfo struct {
+	permission      string
+	subscriptionKey string
+	expires         time.Time
+}
+
+// NewServer starts and returns a new fake server.  The caller should call
+// Close when finished, to shut it down.
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewTLSServer starts and returns a new fake server using TLS.  The
+// Client() method of the returned server trusts the server certificate.
+func NewTLSServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func newServer() *Server {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("ngfwtest: failed to generate signing key: %v", err))
+	}
+
+	return &Server{
+		Tenant:        DefaultTenant,
+		TenantVersion: DefaultTenantVersion,
+		Now:           time.Now,
+		key:           key,
+		tokens:        make(map[string]tokenInfo),
+		rulestacks:    make(map[string]*rulestack),
+		firewalls:     make(map[string]*firewallState),
+		accounts:      make(map[string]*account.ReadResponse),
+	}
+}
+
+// Host returns the host:port of the fake, suitable for the Host and V2Host
+// fields of aws.Client.
+func (s *Server) Host() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// Requests returns a copy of the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ans := make([]Request, len(s.requests))
+	copy(ans, s.requests)
+	return ans
+}
+
+// Token mints a new JWT and subscription key for the given permission,
+// exactly as the mgmt token endpoints would.
+func (s *Server) Token(permission string) (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.mint(permission)
+}
+
+// this function should be called with s.mu taken
+func (s *Server) mint(permission string) (string, string) {
+	now := s.Now()
+	exp := now.Add(TokenExpiryMinutes * time.Minute)
+	claims := jwt.MapClaims{
+		"tenant":         s.Tenant,
+		"tenant_version": s.TenantVersion,
+		"permission":     permission,
+		"region":         DefaultRegion,
+		"iat":            now.Unix(),
+		"exp":            exp.Unix(),
+		"jti":            s.nextID("jti"),
+	}
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.key)
+	if err != nil {
+		panic(fmt.Sprintf("ngfwtest: failed to sign token: %v", err))
+	}
+	sk := s.nextID("sk")
+	s.tokens[tok] = tokenInfo{
+		permission:      permission,
+		subscriptionKey: sk,
+		expires:         exp,
+	}
+	return tok, sk
+}
+
+// this function should be called with s.mu taken
+func (s *Server) nextID(prefix string) string {
+	s.seq++
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%s%04d", prefix, hex.EncodeToString(b), s.seq)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+
+	req := &request{
+		r:     r,
+		w:     w,
+		body:  body,
+		path:  strings.Split(strings.Trim(r.URL.Path, "/"), "/"),
+		query: r.URL.Query(),
+	}
+
+	if f := s.fault(r); f != nil {
+		if f.RetryAfter != "" {
+			w.Header().Set("Retry-After", f.RetryAfter)
+		}
+		req.fail(f.StatusCode, "injected fault: %s", http.StatusText(f.StatusCode))
+		return
+	}
+
+	switch {
+	case req.match("v1", "mgmt", "tokens", "*"):
+		s.handleToken(req)
+	case req.match("v1", "mgmt", "cloudservicetokens", "panorama"):
+		s.handleServiceToken(req)
+	case req.prefix("v1", "mgmt", "linkaccounts"):
+		if s.authorize(req, PermAccount) {
+			s.handleAccounts(req)
+		}
+	case req.prefix("v1", "config", "rulestacks"):
+		s.handleRulestacks(req)
+	case req.prefix("v1", "config", "ngfirewalls"), req.prefix("v2", "config", "ngfirewalls"):
+		if s.authorize(req, PermFirewall) {
+			s.handleFirewalls(req)
+		}
+	case req.match("v1", "config", "countries"):
+		if s.authorize(req, PermRulestack, PermGlobalRulestack) {
+			s.handleCountries(req)
+		}
+	case req.prefix("v1", "config", "appidversions"):
+		if s.authorize(req, PermRulestack, PermGlobalRulestack) {
+			s.handleAppIDs(req)
+		}
+	case req.match("v1", "config", "urlcategories"):
+		if s.authorize(req, PermRulestack, PermGlobalRulestack) {
+			s.handleURLCategories(req)
+		}
+	default:
+		req.unknownPath()
+	}
+}
+
+func (s *Server) handleToken(req *request) {
+	if req.r.Method != http.MethodGet {
+		req.methodNotAllowed()
+		return
+	}
+
+	perm := req.path[3]
+	switch perm {
+	case PermFirewall, PermRulestack, PermGlobalRulestack, PermAccount:
+	case PermCloudManager:
+		if !s.allowedExternalID(req.query.Get("externalid")) {
+			req.fail(http.StatusForbidden, "external id %q is not allowed", req.query.Get("externalid"))
+			return
+		}
+	default:
+		req.unknownPath()
+		return
+	}
+
+	tok, sk := s.mint(perm)
+	req.ok(map[string]interface{}{
+		"Response": map[string]interface{}{
+			"TokenId":         tok,
+			"SubscriptionKey": sk,
+			"ExpiryTime":      TokenExpiryMinutes,
+			"Enabled":         true,
+		},
+	})
+}
+
+func (s *Server) handleServiceToken(req *request) {
+	if req.r.Method != http.MethodGet {
+		req.methodNotAllowed()
+		return
+	}
+	if !s.allowedExternalID(req.query.Get("externalid")) {
+		req.fail(http.StatusForbidden, "external id %q is not allowed", req.query.Get("externalid"))
+		return
+	}
+
+	tok, sk := s.mint(PermPanorama)
+	req.ok(map[string]interface{}{
+		"Response": map[string]interface{}{
+			"TokenId":         tok,
+			"SubscriptionKey": sk,
+			"ExpiryTime":      TokenExpiryMinutes,
+			"Enabled":         true,
+		},
+	})
+}
+
+func (s *Server) allowedExternalID(id string) bool {
+	if id == "" {
+		return false
+	}
+	if len(s.ExternalIDs) == 0 {
+		return true
+	}
+	for _, x := range s.ExternalIDs {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize verifies that the request carries a JWT and subscription key
+// minted by this server for one of the given permissions.  A cloudmanager
+// token grants every permission, just like the externalID flow does.
+func (s *Server) authorize(req *request, perms ...string) bool {
+	tok := req.r.Header.Get("Authorization")
+	info, ok := s.tokens[tok]
+	if tok == "" || !ok {
+		req.writeJSON(http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+		return false
+	}
+	if s.Now().After(info.expires) {
+		req.writeJSON(http.StatusUnauthorized, map[string]string{"message": "The incoming token has expired"})
+		return false
+	}
+	if req.r.Header.Get("x-api-key") != info.subscriptionKey {
+		req.writeJSON(http.StatusForbidden, map[string]string{"message": "Forbidden"})
+		return false
+	}
+	if info.permission == PermCloudManager {
+		return true
+	}
+	for _, p := range perms {
+		if p == info.permission {
+			return true
+		}
+	}
+
+	req.writeJSON(http.StatusForbidden, map[string]string{
+		"message": fmt.Sprintf("User is not authorized to access this resource with a %s token", info.permission),
+	})
+	return false
+}
+
+// request bundles a single in-flight request with its response writer.
+type request struct {
+	r     *http.Request
+	w     http.ResponseWriter
+	body  []byte
+	path  []string
+	query url.Values
+}
+
+// match returns true if the path matches the given parts exactly, where
+// "*" matches any single part.
+func (req *request) match(parts ...string) bool {
+	if len(parts) != len(req.path) {
+		return false
+	}
+	return req.prefix(parts...)
+}
+
+// prefix returns true if the path begins with the given parts, where "*"
+// matches any single part.
+func (req *request) prefix(parts ...string) bool {
+	if len(parts) > len(req.path) {
+		return false
+	}
+	for i, x := range parts {
+		if x != "*" && x != req.path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// decode unmarshals the request body into v.  An empty body is not an error.
+func (req *request) decode(v interface{}) bool {
+	if len(req.body) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(req.body, v); err != nil {
+		req.fail(http.StatusBadRequest, "invalid request body: %s", err)
+		return false
+	}
+	return true
+}
+
+// flag returns true if the given boolean is set either as a query param or
+// in the JSON body of the request.
+func (req *request) flag(name string) bool {
+	if strings.EqualFold(req.query.Get(strings.ToLower(name)), "true") {
+		return true
+	}
+	var m map[string]interface{}
+	if len(req.body) > 0 && json.Unmarshal(req.body, &m) == nil {
+		if v, ok := m[name].(bool); ok {
+			return v
+		}
+	}
+	return false
+}
+
+// page returns the NextToken and MaxResults of a list request, read either
+// from the query params or the JSON body.
+func (req *request) page() (string, int) {
+	token := req.query.Get("nexttoken")
+	var max int
+	fmt.Sscanf(req.query.Get("maxresults"), "%d", &max)
+
+	var body struct {
+		NextToken  string `json:"NextToken"`
+		MaxResults int    `json:"MaxResults"`
+	}
+	if len(req.body) > 0 && json.Unmarshal(req.body, &body) == nil {
+		if token == "" {
+			token = body.NextToken
+		}
+		if max == 0 {
+			max = body.MaxResults
+		}
+	}
+
+	return token, max
+}
+
+func (req *request) writeJSON(code int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		code = http.StatusInternalServerError
+		b = []byte(fmt.Sprintf(`{"message":%q}`, err.Error()))
+	}
+	req.w.Header().Set("Content-Type", "application/json")
+	req.w.Header().Set("x-amzn-RequestId", fmt.Sprintf("req-%d", time.Now().UnixNano()))
+	req.w.WriteHeader(code)
+	req.w.Write(b)
+}
+
+// ok writes a successful response.  The ResponseStatus is added to maps.
+func (req *request) ok(v interface{}) {
+	if m, isMap := v.(map[string]interface{}); isMap {
+		m["ResponseStatus"] = response.Status{}
+	}
+	req.writeJSON(http.StatusOK, v)
+}
+
+// fail writes an error response with a ResponseStatus body, using the HTTP
+// status code as the error code.
+func (req *request) fail(code int, format string, a ...interface{}) {
+	req.writeJSON(code, map[string]interface{}{
+		"ResponseStatus": response.Status{
+			Code:   code,
+			Reason: fmt.Sprintf(format, a...),
+		},
+	})
+}
+
+func (req *request) notFound(format string, a ...interface{}) {
+	req.fail(http.StatusNotFound, format, a...)
+}
+
+func (req *request) methodNotAllowed() {
+	req.fail(http.StatusMethodNotAllowed, "invalid request: method %s not allowed", req.r.Method)
+}
+
+// unknownPath mimics the API gateway response for paths that do not exist.
+func (req *request) unknownPath() {
+	req.writeJSON(http.StatusForbidden, map[string]string{"message": "Missing Authentication Token"})
+}
@@ -3,11 +3,12 @@ package aws
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -38,336 +39,186 @@ func GetPermission(v string) (string, error) {
 	return "", fmt.Errorf("Unknown permission: %s", v)
 }
 
-// RefreshJwts refreshes all JWTs and stores them for future API calls.
-func (c *Client) RefreshFirewallAdminJwt(ctx context.Context) error {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing JWTs...")
-	}
-	c.FirewallAdminMutex.Lock()
-	defer c.FirewallAdminMutex.Unlock()
-	if c.FirewallAdminJwtExpTime.Sub(time.Now()) > 10*time.Second {
-		// the jwt is valid for 10 or more seconds. let's not replenish it now
-		return nil
-	}
+// iamRoleSource fetches JWTs by assuming the IAM role configured for each
+// permission and calling the mgmt token endpoints with its credentials.
+type iamRoleSource struct {
+	c *Client
 
-	jwtReq := getJwt{
-		Expires: 120,
-		/*
-			KeyInfo: &jwtKeyInfo{
-				Region: c.Region,
-				Tenant: "XY",
-			},
-		*/
-	}
+	// endpoint overrides the STS endpoint, for tests.
+	endpoint string
 
-	var creds *credentials.Credentials
-	if c.AccessKey != "" || c.SecretKey != "" {
-		creds = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, "")
-	}
+	mu       sync.Mutex
+	sessions map[string]*session.Session
+}
 
-	sess, err := session.NewSessionWithOptions(session.Options{
-		Config: aws.Config{
-			Credentials: creds,
-			Region:      aws.String(c.Region),
-		},
-		Profile:           *aws.String(c.Profile),
-		SharedConfigState: session.SharedConfigEnable,
-	})
-	if err != nil {
-		return err
-	}
+// sts returns an STS client for the given region, using creds if not nil.
+// The AWS session is created the first time a region is used.
+func (s *iamRoleSource) sts(region string, creds *sts.Credentials) (*sts.STS, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess := s.sessions[region]
+	if sess == nil {
+		c := s.c
+		var ac *credentials.Credentials
+		if c.AccessKey != "" || c.SecretKey != "" {
+			ac = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, "")
+		}
+
+		cfg := aws.Config{
+			Credentials: ac,
+			Region:      aws.String(region),
+		}
+		if s.endpoint != "" {
+			cfg.Endpoint = aws.String(s.endpoint)
+		}
+		var err error
+		sess, err = session.NewSessionWithOptions(session.Options{
+			Config:            cfg,
+			Profile:           c.Profile,
+			SharedConfigState: session.SharedConfigEnable,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if s.sessions == nil {
+			s.sessions = make(map[string]*session.Session)
+		}
+		s.sessions[region] = sess
+	}
+
+	if creds != nil {
+		return sts.New(sess, aws.NewConfig().WithCredentials(chainedCredentials(creds))), nil
+	}
+	return sts.New(sess), nil
+}
 
-	svc := sts.New(sess)
-	// Get firewall JWT.
-	var rarn *string
-	if c.LfaArn != "" {
-		rarn = aws.String(c.LfaArn)
-	} else if c.Arn != "" {
-		rarn = aws.String(c.Arn)
-	} else {
-		log.Printf("err: No LFA role is assigned")
-		return err
+// Token implements TokenSource.
+func (s *iamRoleSource) Token(ctx context.Context, permission string) (Token, error) {
+	c := s.c
+
+	var arn, region, endpoint, auth string
+	switch permission {
+	case PermissionFirewall:
+		arn, region, endpoint = c.LfaArn, c.Region, "cloudfirewalladmin"
+	case PermissionRulestack:
+		arn, region, endpoint = c.LraArn, c.Region, "cloudrulestackadmin"
+	case PermissionGlobalRulestack:
+		arn, region, endpoint = c.GraArn, c.Region, "cloudglobalrulestackadmin"
+	case PermissionAccount:
+		// The account admin role has no fallback on Arn.
+		if c.AcctAdminArn == "" {
+			return Token{}, fmt.Errorf("no account admin role is assigned")
+		}
+		arn, region, endpoint, auth = c.AcctAdminArn, c.MPRegion, "cloudaccountadmin", PermissionAccountAdminJWT
+	default:
+		return Token{}, fmt.Errorf("Unknown permission required: %q", permission)
+	}
+	if arn == "" {
+		arn = c.Arn
+	}
+	if arn == "" {
+		return Token{}, fmt.Errorf("no role is assigned for the %s permission", permission)
 	}
 
 	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing firewall JWT...")
+		c.logger().Info("refreshing JWT", logging.F("permission", permission), logging.F("role", arn))
 	}
-	result, err := svc.AssumeRole(&sts.AssumeRoleInput{
-		RoleArn:         rarn,
-		RoleSessionName: aws.String("sdk_session"),
-	})
+	creds, err := s.assume(ctx, region, arn, c.assumeRoleOptions(permission))
 	if err != nil {
-		return err
+		return Token{}, err
 	}
+
 	path := Path{
-		V1Path: []string{"v1", "mgmt", "tokens", "cloudfirewalladmin"},
+		V1Path: []string{"v1", "mgmt", "tokens", endpoint},
 	}
 	var ans authResponse
 	_, err = c.Communicate(
-		ctx, "", http.MethodGet, path, nil, jwtReq, &ans, result.Credentials,
+		withOperation(ctx, "RefreshJwt"), auth, http.MethodGet, path, nil, getJwt{Expires: 120}, &ans, creds,
 	)
 	if err != nil {
-		log.Printf("err:%+v", err)
-		return err
-	}
-
-	tNow := time.Now()
-	if err := c.SetTenantVersion(ans.Resp.Jwt); err != nil {
-		return err
-	}
-	c.FirewallAdminJwtExpTime = tNow.Add(time.Duration(ans.Resp.ExpiryTime) * time.Minute)
-	c.FirewallAdminJwt = ans.Resp.Jwt
-	c.FirewallSubscriptionKey = ans.Resp.SubscriptionKey
-	return nil
-}
-
-// RefreshJwts refreshes all JWTs and stores them for future API calls.
-func (c *Client) RefreshRulestackAdminJwt(ctx context.Context) error {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing RulestackAdmin JWT...")
-	}
-
-	c.RulestackAdminMutex.Lock()
-	defer c.RulestackAdminMutex.Unlock()
-	if time.Until(c.RulestackAdminJwtExpTime) > 10*time.Second {
-		// the jwt is valid for 10 or more seconds. let's not replenish it now
-		log.Printf("exptime:%+v now:%+v", c.RulestackAdminJwtExpTime, time.Now())
-		return nil
-	}
-
-	jwtReq := getJwt{
-		Expires: 120,
-		/*
-			KeyInfo: &jwtKeyInfo{
-				Region: c.Region,
-				Tenant: "XY",
-			},
-		*/
+		return Token{}, err
 	}
 
-	var creds *credentials.Credentials
-	if c.AccessKey != "" || c.SecretKey != "" {
-		creds = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, "")
-	}
-
-	sess, err := session.NewSessionWithOptions(session.Options{
-		Config: aws.Config{
-			Credentials: creds,
-			Region:      aws.String(c.Region),
-		},
-		Profile:           c.Profile,
-		SharedConfigState: session.SharedConfigEnable,
-	})
-
+	claims, err := c.ParseToken(ans.Resp.Jwt)
 	if err != nil {
-		log.Printf("err:%+v", err)
-		return err
-	}
-
-	svc := sts.New(sess)
-	// Get rulestack JWT.
-	var rarn *string
-	if c.LraArn != "" {
-		rarn = aws.String(c.LraArn)
-	} else if c.Arn != "" {
-		rarn = aws.String(c.Arn)
-	} else {
-		log.Printf("err: No LRA role is assigned")
-		return err
+		c.logger().Error("failed to parse token", logging.F("error", err))
+		return Token{}, err
 	}
-	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing RulestackAdmin JWT...")
-	}
-	result, err := svc.AssumeRole(&sts.AssumeRoleInput{
-		RoleArn:         rarn,
-		RoleSessionName: aws.String("sdk_session"),
-	})
-	if err != nil {
-		log.Printf("err:%+v", err)
-		return err
+	if err = c.setTenantVersion(claims); err != nil {
+		return Token{}, err
 	}
-
-	var ans authResponse
-	_, err = c.RequestJwt(
-		ctx, http.MethodGet, []string{"v1", "mgmt", "tokens", "cloudrulestackadmin"}, nil, jwtReq, &ans, result.Credentials,
-	)
-	if err != nil {
-		log.Printf("err:%+v", err)
-		return err
-	}
-
-	tNow := time.Now()
-	if err := c.SetTenantVersion(ans.Resp.Jwt); err != nil {
-		return err
-	}
-	c.RulestackAdminJwtExpTime = tNow.Add(time.Duration(ans.Resp.ExpiryTime) * time.Minute)
-	c.RulestackAdminJwt = ans.Resp.Jwt
-	c.RulestackSubscriptionKey = ans.Resp.SubscriptionKey
-	return nil
+	return Token{
+		Jwt:             ans.Resp.Jwt,
+		SubscriptionKey: ans.Resp.SubscriptionKey,
+		Expires:         claims.expiresOr(time.Duration(ans.Resp.ExpiryTime) * time.Minute),
+	}, nil
 }
 
-// RefreshJwts refreshes all JWTs and stores them for future API calls.
-func (c *Client) RefreshGlobalRulestackAdminJwt(ctx context.Context) error {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing JWTs...")
-	}
-
-	c.GlobalRulestackAdminMutex.Lock()
-	defer c.GlobalRulestackAdminMutex.Unlock()
-	if c.GlobalRulestackAdminJwtExpTime.Sub(time.Now()) > 10*time.Second {
-		// the jwt is valid for 10 or more seconds. let's not replenish it now
-		return nil
-	}
-
-	jwtReq := getJwt{
-		Expires: 120,
-		/*
-			KeyInfo: &jwtKeyInfo{
-				Region: c.Region,
-				Tenant: "XY",
-			},
-		*/
-	}
-
-	var creds *credentials.Credentials
-	if c.AccessKey != "" || c.SecretKey != "" {
-		creds = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, "")
-	}
-
-	sess, err := session.NewSessionWithOptions(session.Options{
-		Config: aws.Config{
-			Credentials: creds,
-			Region:      aws.String(c.Region),
-		},
-		Profile:           *aws.String(c.Profile),
-		SharedConfigState: session.SharedConfigEnable,
+// iamRoles returns the client's iamRoleSource.
+func (c *Client) iamRoles() *iamRoleSource {
+	c.iamOnce.Do(func() {
+		c.iamSource = &iamRoleSource{c: c}
 	})
+	return c.iamSource
+}
 
-	if err != nil {
-		return err
-	}
-
-	svc := sts.New(sess)
+/*
+refreshJwt makes sure the deprecated fields for key hold a valid token.
 
-	// Get global rulestack JWT.
-	var rarn *string
-	if c.GraArn != "" {
-		rarn = aws.String(c.GraArn)
-	} else if c.Arn != "" {
-		rarn = aws.String(c.Arn)
-	} else {
-		log.Printf("err: No GRA role is assigned")
+Clients with an AuthType get the token from their TokenProvider. Clients
+without one fetch it from src, as the Refresh functions always did.
+*/
+func (c *Client) refreshJwt(ctx context.Context, key string, src TokenSource) error {
+	if _, ok := c.tokens().(fieldTokens); !ok {
+		_, err := c.token(ctx, key)
 		return err
 	}
 
-	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing global rulestack JWT...")
+	if tok, err := c.tokens().Token(ctx, key); err == nil && tok.Valid(10*time.Second) {
+		return nil
 	}
-	result, err := svc.AssumeRole(&sts.AssumeRoleInput{
-		RoleArn:         rarn,
-		RoleSessionName: aws.String("sdk_session"),
-	})
+	tok, err := c.instrument(src).Token(ctx, key)
 	if err != nil {
 		return err
 	}
-	path := Path{
-		V1Path: []string{"v1", "mgmt", "tokens", "cloudglobalrulestackadmin"},
-	}
-	var ans authResponse
-	_, err = c.Communicate(
-		ctx, "", http.MethodGet, path, nil, jwtReq, &ans, result.Credentials,
-	)
-	if err != nil {
-		return err
-	}
-	tNow := time.Now()
-	if err := c.SetTenantVersion(ans.Resp.Jwt); err != nil {
-		return err
-	}
-	c.GlobalRulestackAdminJwtExpTime = tNow.Add(time.Duration(ans.Resp.ExpiryTime) * time.Minute)
-	c.GlobalRulestackAdminJwt = ans.Resp.Jwt
-	c.GlobalRulestackSubscriptionKey = ans.Resp.SubscriptionKey
+	c.storeToken(key, tok)
 	return nil
 }
 
-// RefreshJwts refreshes all JWTs and stores them for future API calls.
-func (c *Client) RefreshAccountAdminJwt(ctx context.Context) error {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing JWTs...")
-	}
-	c.AccountAdminMutex.Lock()
-	defer c.AccountAdminMutex.Unlock()
-	if c.AccountAdminJwtExpTime.Sub(time.Now()) > 10*time.Second {
-		// the jwt is valid for 10 or more seconds. let's not replenish it now
-		return nil
-	}
-
-	jwtReq := getJwt{
-		Expires: 120,
-		/*
-			KeyInfo: &jwtKeyInfo{
-				Region: c.Region,
-				Tenant: "XY",
-			},
-		*/
-	}
-
-	var creds *credentials.Credentials
-	if c.AccessKey != "" || c.SecretKey != "" {
-		creds = credentials.NewStaticCredentials(c.AccessKey, c.SecretKey, "")
-	}
-
-	sess, err := session.NewSessionWithOptions(session.Options{
-		Config: aws.Config{
-			Credentials: creds,
-			Region:      aws.String(c.MPRegion),
-		},
-		Profile:           *aws.String(c.Profile),
-		SharedConfigState: session.SharedConfigEnable,
-	})
-	if err != nil {
-		return err
-	}
-
-	svc := sts.New(sess)
-	// Get account admin JWT.
-	if c.AcctAdminArn == "" {
-		return err
-	}
+// RefreshFirewallAdminJwt makes sure the client holds a valid firewall
+// admin JWT.
+//
+// Deprecated: Communicate fetches tokens as needed. Use the client's
+// TokenProvider to get one directly.
+func (c *Client) RefreshFirewallAdminJwt(ctx context.Context) error {
+	return c.refreshJwt(ctx, PermissionFirewall, c.iamRoles())
+}
 
-	if c.Logging&awsngfw.LogLogin == awsngfw.LogLogin {
-		log.Printf("(login) refreshing account admin JWT...")
-	}
-	result, err := svc.AssumeRole(&sts.AssumeRoleInput{
-		RoleArn:         aws.String(c.AcctAdminArn),
-		RoleSessionName: aws.String("sdk_session"),
-	})
-	if err != nil {
-		return err
-	}
-	path := Path{
-		V1Path: []string{"v1", "mgmt", "tokens", "cloudaccountadmin"},
-	}
-	var ans authResponse
-	_, err = c.Communicate(
-		ctx, PermissionAccountAdminJWT, http.MethodGet, path, nil, jwtReq, &ans, result.Credentials,
-	)
-	if err != nil {
-		log.Printf("err:%+v", err)
-		return err
-	}
+// RefreshRulestackAdminJwt makes sure the client holds a valid rulestack
+// admin JWT.
+//
+// Deprecated: Communicate fetches tokens as needed. Use the client's
+// TokenProvider to get one directly.
+func (c *Client) RefreshRulestackAdminJwt(ctx context.Context) error {
+	return c.refreshJwt(ctx, PermissionRulestack, c.iamRoles())
+}
 
-	tNow := time.Now()
+// RefreshGlobalRulestackAdminJwt makes sure the client holds a valid global
+// rulestack admin JWT.
+//
+// Deprecated: Communicate fetches tokens as needed. Use the client's
+// TokenProvider to get one directly.
+func (c *Client) RefreshGlobalRulestackAdminJwt(ctx context.Context) error {
+	return c.refreshJwt(ctx, PermissionGlobalRulestack, c.iamRoles())
+}
 
-	if err := c.SetTenantVersion(ans.Resp.Jwt); err != nil {
-		return err
-	}
-	c.AccountAdminJwtExpTime = tNow.Add(time.Duration(ans.Resp.ExpiryTime) * time.Minute)
-	c.AccountAdminJwt = ans.Resp.Jwt
-	c.AccountAdminSubscriptionKey = ans.Resp.SubscriptionKey
-	return nil
+// RefreshAccountAdminJwt makes sure the client holds a valid account admin
+// JWT.
+//
+// Deprecated: Communicate fetches tokens as needed. Use the client's
+// TokenProvider to get one directly.
+func (c *Client) RefreshAccountAdminJwt(ctx context.Context) error {
+	return c.refreshJwt(ctx, PermissionAccount, c.iamRoles())
 }
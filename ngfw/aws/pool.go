@@ -0,0 +1,406 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultPoolIdleTimeout is how long a pooled client may go unused before
+// it is evicted, when ClientPoolOptions.IdleTimeout is zero.
+const DefaultPoolIdleTimeout = 15 * time.Minute
+
+// ErrPoolClosed is returned by a ClientPool that has been closed.
+var ErrPoolClosed = errors.New("client pool is closed")
+
+// PoolKey identifies the client of a tenant in a ClientPool.
+type PoolKey struct {
+	ExternalID string
+	Region     string
+}
+
+// ClientPoolOptions configures a ClientPool.
+type ClientPoolOptions struct {
+	// Info returns the AuthInfo used to set up the client for a key, as
+	// passed to SetupUsingCreds. Its ExternalID and Region default to the
+	// key's, and its HttpClient and SecureHttpClient to the pool's. This is
+	// required.
+	Info func(key PoolKey) (AuthInfo, error)
+
+	// Configure, if set, is called on each new client before it is set up,
+	// to set its Logger, Metrics, Middleware and so on.
+	Configure func(key PoolKey, c *Client)
+
+	// HttpClient and SecureHttpClient are shared by all the clients of the
+	// pool, so they share connections. If nil, clients are created using
+	// a clone of http.DefaultTransport.
+	HttpClient       *http.Client
+	SecureHttpClient *http.Client
+
+	// MaxConcurrency is the number of calls to Do that may run at once for
+	// a tenant. If zero, there is no limit.
+	MaxConcurrency int
+
+	// IdleTimeout is how long a client may go unused before it is evicted.
+	// If zero, DefaultPoolIdleTimeout is used.
+	IdleTimeout time.Duration
+}
+
+// PoolStats are the statistics of a ClientPool.
+type PoolStats struct {
+	// Clients is the number of clients in the pool.
+	Clients int
+
+	// Created and Evicted count the clients created and evicted since the
+	// pool was created.
+	Created uint64
+	Evicted uint64
+
+	// InFlight is the number of calls to Do running, and Waiting the
+	// number waiting for their tenant's concurrency limit.
+	InFlight int
+	Waiting  int
+
+	// Tenants has the statistics of each client in the pool.
+	Tenants map[PoolKey]TenantStats
+}
+
+// TenantStats are the statistics of a tenant's client in a ClientPool.
+type TenantStats struct {
+	Calls    uint64
+	InFlight int
+	Waiting  int
+	Created  time.Time
+	LastUsed time.Time
+
+	// Leases is the number of times the client was handed out by Get and
+	// not released yet.
+	Leases int
+}
+
+/*
+ClientPool lazily creates and caches one external ID client per tenant and
+region, for services managing many tenants.
+
+The clients share the pool's HTTP clients, and the calls made for each
+tenant can be limited. Clients left unused for IdleTimeout are closed and
+evicted, unless a call to Do is using them or Get handed them out and they
+were not released yet. Close the pool to stop everything.
+*/
+type ClientPool struct {
+	opts       ClientPoolOptions
+	httpClient *http.Client
+	secure     *http.Client
+	stop       chan struct{}
+	done       chan struct{}
+
+	mu      sync.Mutex
+	clients map[PoolKey]*pooledClient
+	created uint64
+	evicted uint64
+	closed  bool
+}
+
+/*
+pooledClient is a client of the pool.
+
+It is added to the pool before it is set up, so that concurrent requests for
+it wait on ready instead of setting up clients of their own. Until ready is
+closed, only ready and err may be used, and err only afterwards.
+*/
+type pooledClient struct {
+	ready chan struct{}
+	err   error
+
+	c     *Client
+	slots chan struct{}
+	stats TenantStats
+}
+
+// NewClientPool returns a ClientPool, which must be closed once done with.
+func NewClientPool(opts ClientPoolOptions) *ClientPool {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultPoolIdleTimeout
+	}
+
+	p := &ClientPool{
+		opts:       opts,
+		httpClient: opts.HttpClient,
+		secure:     opts.SecureHttpClient,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		clients:    make(map[PoolKey]*pooledClient),
+	}
+	if p.httpClient == nil {
+		p.httpClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	}
+	if p.secure == nil {
+		p.secure = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	}
+
+	go p.janitor()
+	return p
+}
+
+/*
+Get returns the client of a tenant, creating it if needed, and a func to
+call once done with it. The client is not evicted until then. Calls made with
+it directly are not subject to MaxConcurrency.
+*/
+func (p *ClientPool) Get(ctx context.Context, externalID, region string) (*Client, func(), error) {
+	pc, err := p.client(ctx, PoolKey{ExternalID: externalID, Region: region})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	pc.stats.Leases++
+	pc.stats.LastUsed = time.Now()
+	p.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			p.mu.Lock()
+			pc.stats.Leases--
+			pc.stats.LastUsed = time.Now()
+			p.mu.Unlock()
+		})
+	}
+	return pc.c, release, nil
+}
+
+// Do calls fn with the client of a tenant once the tenant is under its
+// concurrency limit.
+func (p *ClientPool) Do(ctx context.Context, externalID, region string, fn func(*Client) error) error {
+	pc, err := p.client(ctx, PoolKey{ExternalID: externalID, Region: region})
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	// Counting the waiting calls as in use keeps the client from being
+	// evicted under them.
+	pc.stats.Waiting++
+	p.mu.Unlock()
+
+	if pc.slots != nil {
+		select {
+		case pc.slots <- struct{}{}:
+		case <-ctx.Done():
+			p.mu.Lock()
+			pc.stats.Waiting--
+			p.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+
+	p.mu.Lock()
+	pc.stats.Waiting--
+	pc.stats.InFlight++
+	pc.stats.Calls++
+	pc.stats.LastUsed = time.Now()
+	p.mu.Unlock()
+
+	defer func() {
+		if pc.slots != nil {
+			<-pc.slots
+		}
+		p.mu.Lock()
+		pc.stats.InFlight--
+		pc.stats.LastUsed = time.Now()
+		p.mu.Unlock()
+	}()
+
+	return fn(pc.c)
+}
+
+/*
+client returns the pooled client for key, creating it if needed.
+
+The client is set up without holding p.mu, so the pool isn't blocked on it.
+Concurrent calls for the same key share the setup, and its error.
+
+It is touched before being returned, so that it isn't evicted before the
+caller starts using it.
+*/
+func (p *ClientPool) client(ctx context.Context, key PoolKey) (*pooledClient, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	pc := p.clients[key]
+	if pc == nil {
+		pc = &pooledClient{ready: make(chan struct{})}
+		p.clients[key] = pc
+		p.mu.Unlock()
+
+		c, err := p.setup(ctx, key)
+
+		p.mu.Lock()
+		switch {
+		case err != nil:
+			delete(p.clients, key)
+		case p.closed:
+			// The pool was closed during the setup, and has no more
+			// clients to close.
+			c.Close()
+			err = ErrPoolClosed
+		default:
+			pc.c = c
+			if p.opts.MaxConcurrency > 0 {
+				pc.slots = make(chan struct{}, p.opts.MaxConcurrency)
+			}
+			pc.stats.Created = time.Now()
+			pc.stats.LastUsed = pc.stats.Created
+			p.created++
+		}
+		pc.err = err
+		close(pc.ready)
+		p.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return pc, nil
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-pc.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if pc.err != nil {
+		return nil, pc.err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+	pc.stats.LastUsed = time.Now()
+	return pc, nil
+}
+
+// setup creates and sets up the client for key.
+func (p *ClientPool) setup(ctx context.Context, key PoolKey) (*Client, error) {
+	if p.opts.Info == nil {
+		return nil, fmt.Errorf("the client pool has no Info function")
+	}
+	info, err := p.opts.Info(key)
+	if err != nil {
+		return nil, err
+	}
+	if info.ExternalID == "" {
+		info.ExternalID = key.ExternalID
+	}
+	if info.Region == "" {
+		info.Region = key.Region
+	}
+	if info.HttpClient == nil {
+		info.HttpClient = p.httpClient
+	}
+	if info.SecureHttpClient == nil {
+		info.SecureHttpClient = p.secure
+	}
+
+	c := &Client{AuthType: AuthTypeExternalID}
+	if p.opts.Configure != nil {
+		p.opts.Configure(key, c)
+	}
+	if err = c.SetupUsingCreds(ctx, info); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Stats returns the statistics of the pool.
+func (p *ClientPool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ans := PoolStats{
+		Created: p.created,
+		Evicted: p.evicted,
+		Tenants: make(map[PoolKey]TenantStats, len(p.clients)),
+	}
+	for key, pc := range p.clients {
+		if pc.c == nil {
+			// Still being set up.
+			continue
+		}
+		ans.InFlight += pc.stats.InFlight
+		ans.Waiting += pc.stats.Waiting
+		ans.Clients++
+		ans.Tenants[key] = pc.stats
+	}
+	return ans
+}
+
+// janitor evicts idle clients until the pool is closed.
+func (p *ClientPool) janitor() {
+	defer close(p.done)
+
+	t := time.NewTicker(p.opts.IdleTimeout / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-t.C:
+			p.evictIdle(now)
+		}
+	}
+}
+
+// evictIdle closes and removes the clients unused since IdleTimeout before
+// now. Clients in use by Do or leased by Get are kept.
+func (p *ClientPool) evictIdle(now time.Time) {
+	var idle []*Client
+
+	p.mu.Lock()
+	for key, pc := range p.clients {
+		if pc.c == nil || pc.stats.InFlight > 0 || pc.stats.Waiting > 0 || pc.stats.Leases > 0 {
+			continue
+		}
+		if now.Sub(pc.stats.LastUsed) >= p.opts.IdleTimeout {
+			idle = append(idle, pc.c)
+			delete(p.clients, key)
+			p.evicted++
+		}
+	}
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		c.Close()
+	}
+}
+
+// Close closes all the clients of the pool, which must not be used
+// afterwards.
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	clients := p.clients
+	p.clients = make(map[PoolKey]*pooledClient)
+	p.mu.Unlock()
+
+	close(p.stop)
+	<-p.done
+	for _, pc := range clients {
+		// Clients still being set up are closed by client.
+		if pc.c != nil {
+			pc.c.Close()
+		}
+	}
+	return nil
+}
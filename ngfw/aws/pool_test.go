@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestClientPoolAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	p := NewClientPool(ClientPoolOptions{
+		Info: func(key PoolKey) (AuthInfo, error) {
+			return AuthInfo{RegionURL: srv.URL, RegionV2URL: srv.URL, AuthURL: srv.URL}, nil
+		},
+		HttpClient:       srv.Client(),
+		SecureHttpClient: srv.Client(),
+		MaxConcurrency:   2,
+		IdleTimeout:      time.Hour,
+	})
+	defer p.Close()
+
+	var mu sync.Mutex
+	var running, most int
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- p.Do(ctx, "ext-1", ngfwtest.DefaultRegion, func(c *Client) error {
+				mu.Lock()
+				running++
+				if running > most {
+					most = running
+				}
+				mu.Unlock()
+				defer func() {
+					mu.Lock()
+					running--
+					mu.Unlock()
+				}()
+
+				_, err := c.ListRuleStack(ctx, stack.ListInput{})
+				time.Sleep(time.Millisecond)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if most > 2 {
+		t.Fatalf("%d calls ran at once for a tenant limited to 2", most)
+	}
+
+	a, release, err := p.Get(ctx, "ext-1", ngfwtest.DefaultRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, releaseB, err := p.Get(ctx, "ext-2", ngfwtest.DefaultRegion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || a.ExternalID != "ext-1" || b.ExternalID != "ext-2" {
+		t.Fatalf("tenants did not get their own clients")
+	}
+	if a.SecureHttpClient != b.SecureHttpClient {
+		t.Fatalf("clients do not share the HTTP client")
+	}
+
+	st := p.Stats()
+	key := PoolKey{ExternalID: "ext-1", Region: ngfwtest.DefaultRegion}
+	if st.Clients != 2 || st.Created != 2 || st.Tenants[key].Calls != 10 || st.InFlight != 0 {
+		t.Fatalf("wrong stats: %+v", st)
+	}
+
+	// Clients handed out by Get are only evicted once released.
+	p.evictIdle(time.Now().Add(2 * time.Hour))
+	if st = p.Stats(); st.Clients != 2 || st.Evicted != 0 {
+		t.Fatalf("leased clients were evicted: %+v", st)
+	}
+	release()
+	release()
+	releaseB()
+	p.evictIdle(time.Now().Add(2 * time.Hour))
+	if st = p.Stats(); st.Clients != 0 || st.Evicted != 2 {
+		t.Fatalf("idle clients were not evicted: %+v", st)
+	}
+
+	p.Close()
+	if _, _, err = p.Get(ctx, "ext-1", ngfwtest.DefaultRegion); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+}
+
+func TestClientPoolSetup(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	blocked := make(chan struct{})
+	unblock := make(chan struct{})
+	p := NewClientPool(ClientPoolOptions{
+		Info: func(key PoolKey) (AuthInfo, error) {
+			mu.Lock()
+			calls[key.ExternalID]++
+			mu.Unlock()
+			if key.ExternalID == "slow" {
+				close(blocked)
+				<-unblock
+			}
+			return AuthInfo{RegionURL: srv.URL, RegionV2URL: srv.URL, AuthURL: srv.URL}, nil
+		},
+		HttpClient:       srv.Client(),
+		SecureHttpClient: srv.Client(),
+	})
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.Do(ctx, "slow", ngfwtest.DefaultRegion, func(*Client) error { return nil }); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	<-blocked
+
+	// A tenant being set up doesn't hold up the others.
+	if err := p.Do(ctx, "fast", ngfwtest.DefaultRegion, func(*Client) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if st := p.Stats(); st.Clients != 1 {
+		t.Fatalf("clients being set up should not be counted: %+v", st)
+	}
+	p.evictIdle(time.Now().Add(time.Hour))
+	close(unblock)
+	wg.Wait()
+
+	if calls["slow"] != 1 {
+		t.Fatalf("the client was set up %d times", calls["slow"])
+	}
+	if st := p.Stats(); st.Clients != 1 || st.Tenants[PoolKey{ExternalID: "slow", Region: ngfwtest.DefaultRegion}].Calls != 3 {
+		t.Fatalf("wrong stats: %+v", st)
+	}
+}
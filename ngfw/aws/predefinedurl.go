@@ -9,6 +9,8 @@ import (
 
 // List returns a list of objects.
 func (c *Client) ListUrlPredefinedCategories(ctx context.Context, input predefinedurl.ListInput) (predefinedurl.ListOutput, error) {
+	ctx = withOperation(ctx, "ListUrlPredefinedCategories")
+
 	c.Log(http.MethodGet, "list predefined url categories")
 	path := Path{
 		V1Path: []string{"v1", "config", "urlcategories"},
@@ -29,6 +31,8 @@ func (c *Client) ListUrlPredefinedCategories(ctx context.Context, input predefin
 
 // ListOverrides returns URL categories with overrides specified.
 func (c *Client) ListUrlCategoriesActionOverride(ctx context.Context, input predefinedurl.ListOverridesInput) (predefinedurl.ListOverridesOutput, error) {
+	ctx = withOperation(ctx, "ListUrlCategoriesActionOverride")
+
 	c.Log(http.MethodGet, "list predefined url category overrides for rulestack %q", input.Rulestack)
 	path := Path{
 		V1Path: []string{"v1", "config", "rulestacks", input.Rulestack, "urlfilteringprofiles", "custom", "urlcategories"},
@@ -49,6 +53,8 @@ func (c *Client) ListUrlCategoriesActionOverride(ctx context.Context, input pred
 
 // GetOverride returns the URL category override info.
 func (c *Client) DescribeUrlCategoryActionOverride(ctx context.Context, input predefinedurl.GetOverrideInput) (predefinedurl.GetOverrideOutput, error) {
+	ctx = withOperation(ctx, "DescribeUrlCategoryActionOverride")
+
 	c.Log(http.MethodGet, "get %q predefined url category override: %s", input.Rulestack, input.Name)
 	path := Path{
 		V1Path: []string{"v1", "config", "rulestacks", input.Rulestack, "urlfilteringprofiles", "custom", "urlcategories", input.Name},
@@ -69,6 +75,8 @@ func (c *Client) DescribeUrlCategoryActionOverride(ctx context.Context, input pr
 
 // Override specifies an override for a predefined URL category.
 func (c *Client) UpdateUrlCategoryActionOverride(ctx context.Context, input predefinedurl.OverrideInput) error {
+	ctx = withOperation(ctx, "UpdateUrlCategoryActionOverride")
+
 	c.Log(http.MethodPut, "override %q predefined url category: %s", input.Rulestack, input.Name)
 	path := Path{
 		V1Path: []string{"v1", "config", "rulestacks", input.Rulestack, "urlfilteringprofiles", "custom", "urlcategories", input.Name, "action"},
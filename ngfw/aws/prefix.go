@@ -9,6 +9,8 @@ import (
 
 // List returns a list of objects.
 func (c *Client) ListPrefixList(ctx context.Context, input prefix.ListInput) (prefix.ListOutput, error) {
+	ctx = withOperation(ctx, "ListPrefixList")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return prefix.ListOutput{}, permErr
@@ -34,6 +36,8 @@ func (c *Client) ListPrefixList(ctx context.Context, input prefix.ListInput) (pr
 
 // Create creates an object.
 func (c *Client) CreatePrefixList(ctx context.Context, input prefix.Info) error {
+	ctx = withOperation(ctx, "CreatePrefixList")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -58,6 +62,8 @@ func (c *Client) CreatePrefixList(ctx context.Context, input prefix.Info) error
 
 // Read returns information on the given object.
 func (c *Client) ReadPrefixList(ctx context.Context, input prefix.ReadInput) (prefix.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadPrefixList")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return prefix.ReadOutput{}, permErr
@@ -83,6 +89,8 @@ func (c *Client) ReadPrefixList(ctx context.Context, input prefix.ReadInput) (pr
 
 // Update updates the given object.
 func (c *Client) UpdatePrefixList(ctx context.Context, input prefix.Info) error {
+	ctx = withOperation(ctx, "UpdatePrefixList")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -110,6 +118,8 @@ func (c *Client) UpdatePrefixList(ctx context.Context, input prefix.Info) error
 
 // Delete removes the given object from the config.
 func (c *Client) DeletePrefixList(ctx context.Context, input prefix.DeleteInput) error {
+	ctx = withOperation(ctx, "DeletePrefixList")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
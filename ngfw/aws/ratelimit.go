@@ -0,0 +1,152 @@
+package aws
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/metrics"
+)
+
+/*
+RateLimit configures a client side token bucket.
+
+Rate is the sustained number of requests per second, and Burst is the number
+of requests that may be sent back to back before Rate applies. A Burst of
+zero is treated as one.
+*/
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// RateLimitStats are the counters kept for each rate limited permission.
+// The time spent waiting is also reported to the client's Metrics.
+type RateLimitStats struct {
+	// Requests is the number of requests that went through the limiter.
+	Requests int64
+
+	// Waits is the number of requests that had to wait for a token.
+	Waits int64
+
+	// WaitTime is the total time spent waiting, and MaxWait the longest
+	// single wait.
+	WaitTime time.Duration
+	MaxWait  time.Duration
+}
+
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	stats  RateLimitStats
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newRateLimiter(limits map[string]RateLimit) *rateLimiter {
+	rl := &rateLimiter{buckets: make(map[string]*bucket)}
+	for perm, l := range limits {
+		if l.Rate <= 0 {
+			continue
+		}
+		burst := float64(l.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		rl.buckets[perm] = &bucket{
+			rate:   l.Rate,
+			burst:  burst,
+			tokens: burst,
+		}
+	}
+	return rl
+}
+
+// limiterKey maps a permission onto the bucket that budgets it.
+func limiterKey(auth string) string {
+	if auth == PermissionAccountAdminJWT {
+		return PermissionAccount
+	}
+	return auth
+}
+
+// wait blocks until a request for the given permission may be sent, and
+// reports the time waited to m.
+func (rl *rateLimiter) wait(ctx context.Context, auth string, m metrics.Metrics) error {
+	key := limiterKey(auth)
+	rl.mu.Lock()
+	b := rl.buckets[key]
+	if b == nil {
+		rl.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	b.stats.Requests++
+
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+		b.stats.Waits++
+		b.stats.WaitTime += delay
+		if delay > b.stats.MaxWait {
+			b.stats.MaxWait = delay
+		}
+	}
+	rl.mu.Unlock()
+
+	m.RateLimitWait(key, delay)
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		// Hand the reserved token back.
+		rl.mu.Lock()
+		b.tokens++
+		rl.mu.Unlock()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (rl *rateLimiter) stats() map[string]RateLimitStats {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	ans := make(map[string]RateLimitStats, len(rl.buckets))
+	for perm, b := range rl.buckets {
+		ans[perm] = b.stats
+	}
+	return ans
+}
+
+func (c *Client) rateLimiter() *rateLimiter {
+	c.limiterOnce.Do(func() {
+		c.limiter = newRateLimiter(c.RateLimits)
+	})
+	return c.limiter
+}
+
+// RateLimitStats returns the limiter counters for each rate limited
+// permission.
+func (c *Client) RateLimitStats() map[string]RateLimitStats {
+	return c.rateLimiter().stats()
+}
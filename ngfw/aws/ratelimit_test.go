@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/metrics"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestRateLimitsAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	t.Setenv("CLOUDNGFWAWS_RATE_LIMITS", `{"rulestack": {"rate": 200, "burst": 1}}`)
+	c := &Client{
+		Host:             srv.Host(),
+		V2Host:           srv.Host(),
+		Protocol:         "http",
+		Region:           ngfwtest.DefaultRegion,
+		CheckEnvironment: true,
+	}
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	reg := metrics.NewRegistry("")
+	c.Metrics = reg
+	c.FirewallAdminJwt, c.FirewallSubscriptionKey = srv.Token(ngfwtest.PermFirewall)
+	c.RulestackAdminJwt, c.RulestackSubscriptionKey = srv.Token(ngfwtest.PermRulestack)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.ListRuleStack(ctx, stack.ListInput{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ListFirewall(ctx, firewall.ListInput{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := c.RateLimitStats()
+	if rs := stats[PermissionRulestack]; rs.Requests != 3 || rs.Waits == 0 || rs.WaitTime <= 0 {
+		t.Fatalf("unexpected rulestack stats: %+v", rs)
+	}
+	if _, ok := stats[PermissionFirewall]; ok {
+		t.Fatalf("firewall calls should not be limited")
+	}
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `cloudngfw_rate_limit_wait_seconds_count{permission="rulestack"} 3`; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("missing %q in:\n%s", want, rec.Body.String())
+	}
+}
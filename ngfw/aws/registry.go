@@ -0,0 +1,40 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+)
+
+func init() {
+	api.Register(awsngfw.CloudProviderAWS, newAPIClient)
+}
+
+/*
+newAPIClient is the api.Provider of AWS. Its settings may be:
+
+  - nil, to load the Config from the env vars,
+  - a Config or *Config, built into a client as by NewClient,
+  - a *Client that was not set up yet.
+*/
+func newAPIClient(ctx context.Context, pc api.ProviderConfig) (api.Client, error) {
+	switch s := pc.Settings.(type) {
+	case nil:
+		return newClient(Config{CheckEnvironment: true}, &Client{Logger: pc.Logger})
+	case Config:
+		return newClient(s, &Client{Logger: pc.Logger})
+	case *Config:
+		return newClient(*s, &Client{Logger: pc.Logger})
+	case *Client:
+		if s.Logger == nil {
+			s.Logger = pc.Logger
+		}
+		if err := s.Setup(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("settings must be an aws.Config or *aws.Client, not %T", pc.Settings)
+}
@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestNewAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	tok, sk := srv.Token(ngfwtest.PermCloudManager)
+	c := &Client{
+		Host:          srv.Host(),
+		V2Host:        srv.Host(),
+		Protocol:      "http",
+		Region:        ngfwtest.DefaultRegion,
+		TenantVersion: awsngfw.TenantVersionV2,
+		TokenProvider: NewTokenProvider(StaticTokens{
+			PermissionFirewall: {Jwt: tok, SubscriptionKey: sk},
+		}, 0),
+	}
+	client, err := api.New(ctx, awsngfw.CloudProviderAWS, api.WithSettings(c), api.WithMaxGoroutines(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if client.GetApiPrefix(ctx) != srv.URL {
+		t.Fatalf("the client was not set up: prefix %q", client.GetApiPrefix(ctx))
+	}
+	if _, err = client.ListFirewall(ctx, firewall.ListInput{}); err != nil {
+		t.Fatalf("list firewalls: %s", err)
+	}
+
+	if _, err = api.New(ctx, "GCP"); !errors.Is(err, api.ErrUnknownProvider) || !strings.Contains(err.Error(), awsngfw.CloudProviderAWS) {
+		t.Fatalf("expected an unknown provider error listing AWS, got %v", err)
+	}
+	if _, err = api.New(ctx, awsngfw.CloudProviderAWS, api.WithSettings(42)); err == nil {
+		t.Fatalf("bad settings should be rejected")
+	}
+	if _, err = api.New(ctx, awsngfw.CloudProviderAWS, api.WithClient(c), api.WithMaxGoroutines(0)); err == nil {
+		t.Fatalf("zero goroutines should be rejected")
+	}
+
+	defer api.SetLogger(api.Logger)
+	api.SetLogger(nil)
+	if _, err = api.New(ctx, awsngfw.CloudProviderAWS, api.WithClient(c)); err == nil || !strings.Contains(err.Error(), "logger") {
+		t.Fatalf("expected a missing logger error, got %v", err)
+	}
+	if _, err = api.New(ctx, awsngfw.CloudProviderAWS, api.WithClient(c), api.WithLogger(logging.Nop())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPaginatorWithNew(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+	c := newExternalIDClient(t, srv)
+	client, err := api.New(ctx, awsngfw.CloudProviderAWS, api.WithClient(c), api.WithMaxGoroutines(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"rs1", "rs2", "rs3"} {
+		if err := c.CreateRuleStack(ctx, stack.Info{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pages, err := client.NewRuleStackPaginator(stack.ListInput{MaxResults: 2}, api.WithPrefetch()).All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, page := range pages {
+		names = append(names, page.Response.Candidates...)
+	}
+	if len(pages) != 2 || len(names) != 3 {
+		t.Fatalf("expected 3 rulestacks over 2 pages, got %d over %d pages", len(names), len(pages))
+	}
+}
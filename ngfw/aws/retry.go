@@ -0,0 +1,275 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+/*
+RetryPolicy controls how API calls that failed with a transient error are
+retried.
+
+GET calls (and the JWT requests) are always eligible for a retry. Mutating
+calls are only retried if RetryMutating is set and the request body carries a
+non-empty UpdateToken, as the service rejects a replay of such a request with
+a token conflict instead of applying it twice.
+
+A Retry-After header sent with the response is honored, unless it asks for a
+longer wait than MaxDelay, in which case the call fails right away. Each
+attempt goes through the client's rate limiter.
+*/
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+
+	// MinDelay and MaxDelay bound the exponential backoff between attempts.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+
+	// Retryable classifies the outcome of an attempt. The response body has
+	// already been read when this is called. If nil, DefaultRetryable is used.
+	Retryable func(resp *http.Response, err error) bool
+
+	// RetryMutating allows retrying mutating calls that carry an UpdateToken.
+	RetryMutating bool
+}
+
+/*
+DefaultRetryPolicy is used when the client does not have a RetryPolicy.
+
+Note that this retries GET calls up to 3 times, where earlier versions of
+the SDK made a single attempt. Set the client's RetryPolicy to
+&RetryPolicy{MaxAttempts: 1} to keep the old behavior.
+*/
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinDelay:    500 * time.Millisecond,
+	MaxDelay:    20 * time.Second,
+}
+
+// DefaultRetryable retries throttling, gateway errors, timeouts, and
+// connections that were reset or closed early.
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+			return true
+		}
+		var ne net.Error
+		return errors.As(err, &ne) && ne.Timeout()
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// delays returns the policy's MinDelay and MaxDelay, with defaults applied.
+func (p RetryPolicy) delays() (time.Duration, time.Duration) {
+	min, max := p.MinDelay, p.MaxDelay
+	if min <= 0 {
+		min = DefaultRetryPolicy.MinDelay
+	}
+	if max <= 0 {
+		max = DefaultRetryPolicy.MaxDelay
+	}
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	min, max := p.delays()
+
+	d := min << uint(attempt)
+	if d <= 0 || d > max {
+		d = max
+	}
+
+	// Full jitter over the upper half of the window.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryAfter parses the Retry-After header, either in seconds or as a date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	val := resp.Header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+// hasUpdateToken reports whether the JSON body carries a non-empty UpdateToken
+// at any depth.
+func hasUpdateToken(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return false
+	}
+
+	var walk func(interface{}) bool
+	walk = func(v interface{}) bool {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			if s, ok := x["UpdateToken"].(string); ok && s != "" {
+				return true
+			}
+			for _, y := range x {
+				if walk(y) {
+					return true
+				}
+			}
+		case []interface{}:
+			for _, y := range x {
+				if walk(y) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	return walk(v)
+}
+
+func (c *Client) retryPolicy() RetryPolicy {
+	if c.RetryPolicy != nil {
+		return *c.RetryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
+/*
+do performs the given request with the client's retry policy, returning the
+last response along with its body.
+
+The request body is replayed from data on every attempt, and sign (if given)
+is run against each attempt so that v4 signatures stay fresh. Mutating calls
+are only retried if idempotent is set.
+*/
+func (c *Client) do(ctx context.Context, hc *http.Client, req *http.Request, data []byte, sign func(*http.Request) error, idempotent bool) (*http.Response, []byte, error) {
+	policy := c.retryPolicy()
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	attempts := policy.MaxAttempts
+	if attempts < 1 || !idempotent {
+		attempts = 1
+	}
+
+	hc = c.httpClient(hc)
+	info, _ := RequestInfoFromContext(ctx)
+
+	_, maxDelay := policy.delays()
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		if err := c.rateLimiter().wait(ctx, info.Permission, c.metrics()); err != nil {
+			return nil, nil, err
+		}
+
+		info.Attempt = attempt + 1
+		r := req.Clone(withRequestInfo(ctx, info))
+		if data != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
+			r.ContentLength = int64(len(data))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(data)), nil
+			}
+		}
+		if sign != nil {
+			if err := sign(r); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		var body []byte
+		resp, err := hc.Do(r)
+		if err == nil {
+			body, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		if attempt+1 >= attempts || !retryable(resp, err) {
+			c.observeCall(info.Operation, req.Method, resp, start)
+			return resp, body, err
+		}
+
+		delay, ok := retryAfter(resp)
+		if !ok {
+			delay = policy.backoff(attempt)
+		} else if delay > maxDelay {
+			c.Log(req.Method, "not retrying %s, the server asked to wait %s", req.URL.Path, delay)
+			c.observeCall(info.Operation, req.Method, resp, start)
+			return resp, body, err
+		}
+		if resp != nil {
+			c.Log(req.Method, "retrying %s after http %d in %s", req.URL.Path, resp.StatusCode, delay)
+		} else {
+			c.Log(req.Method, "retrying %s after %v in %s", req.URL.Path, err, delay)
+		}
+		c.metrics().Retry(info.Operation, req.Method)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			c.observeCall(info.Operation, req.Method, resp, start)
+			return resp, body, err
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) observeCall(operation, method string, resp *http.Response, start time.Time) {
+	var status int
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	c.metrics().APICall(operation, method, status, time.Since(start))
+}
+
+// isIdempotent reports whether a Communicate call may be retried.
+func (c *Client) isIdempotent(method string, data []byte) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	}
+	return c.retryPolicy().RetryMutating && hasUpdateToken(data)
+}
@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/response"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestRetryAgainstFake(t *testing.T) {
+	runAgainstFake(t, []fakeTest{
+		{
+			name: "retries",
+			run: func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client) {
+				c.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+				if err := c.CreateRuleStack(ctx, stack.Info{Name: "rs1"}); err != nil {
+					t.Fatal(err)
+				}
+
+				srv.AddFault(ngfwtest.Fault{Method: http.MethodGet, Path: "/v1/config/rulestacks/rs1", Count: 2, StatusCode: http.StatusServiceUnavailable, RetryAfter: "0"})
+				rs, err := c.ReadRuleStack(ctx, stack.ReadInput{Name: "rs1"})
+				if err != nil {
+					t.Fatalf("read was not retried: %s", err)
+				}
+
+				update := stack.Info{Name: "rs1", Entry: *rs.Response.Candidate}
+				update.Entry.Description = "retried"
+				srv.AddFault(ngfwtest.Fault{Method: http.MethodPut, Path: "/v1/config/rulestacks/rs1", Count: 1, StatusCode: http.StatusTooManyRequests})
+				if err = c.UpdateRuleStack(ctx, update); !errors.Is(err, response.ErrThrottled) {
+					t.Fatalf("update should not be retried by default, got %v", err)
+				}
+
+				c.RetryPolicy.RetryMutating = true
+				srv.AddFault(ngfwtest.Fault{Method: http.MethodPut, Path: "/v1/config/rulestacks/rs1", Count: 1, StatusCode: http.StatusTooManyRequests})
+				if err = c.UpdateRuleStack(ctx, update); err != nil {
+					t.Fatalf("update with an UpdateToken was not retried: %s", err)
+				}
+			},
+		},
+		{
+			name: "retry-after-beyond-max-delay",
+			run: func(t *testing.T, ctx context.Context, srv *ngfwtest.Server, c *Client) {
+				c.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: time.Second}
+				c.RateLimits = map[string]RateLimit{PermissionRulestack: {Rate: 1000, Burst: 10}}
+
+				if err := c.CreateRuleStack(ctx, stack.Info{Name: "rs1"}); err != nil {
+					t.Fatal(err)
+				}
+
+				// Every attempt takes a token from the limiter.
+				srv.AddFault(ngfwtest.Fault{Method: http.MethodGet, Path: "/v1/config/rulestacks/rs1", Count: 2, StatusCode: http.StatusServiceUnavailable, RetryAfter: "0"})
+				if _, err := c.ReadRuleStack(ctx, stack.ReadInput{Name: "rs1"}); err != nil {
+					t.Fatal(err)
+				}
+				if n := c.RateLimitStats()[PermissionRulestack].Requests; n != 4 {
+					t.Fatalf("the limiter saw %d requests, not 4", n)
+				}
+
+				srv.AddFault(ngfwtest.Fault{Method: http.MethodGet, Path: "/v1/config/rulestacks/rs1", Count: 1, StatusCode: http.StatusTooManyRequests, RetryAfter: "3600"})
+				start := time.Now()
+				if _, err := c.ReadRuleStack(ctx, stack.ReadInput{Name: "rs1"}); !errors.Is(err, response.ErrThrottled) {
+					t.Fatalf("expected the throttling error, got %v", err)
+				}
+				if d := time.Since(start); d > 500*time.Millisecond {
+					t.Fatalf("a Retry-After beyond MaxDelay was waited for (%s)", d)
+				}
+			},
+		},
+	})
+}
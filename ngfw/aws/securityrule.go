@@ -10,6 +10,8 @@ import (
 
 // List returns a list of objects.
 func (c *Client) ListSecurityRule(ctx context.Context, input security.ListInput) (security.ListOutput, error) {
+	ctx = withOperation(ctx, "ListSecurityRule")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return security.ListOutput{}, permErr
@@ -36,6 +38,8 @@ func (c *Client) ListSecurityRule(ctx context.Context, input security.ListInput)
 
 // Create creates an object.
 func (c *Client) CreateSecurityRule(ctx context.Context, input security.Info) error {
+	ctx = withOperation(ctx, "CreateSecurityRule")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -63,6 +67,8 @@ func (c *Client) CreateSecurityRule(ctx context.Context, input security.Info) er
 
 // Read returns information on the given object.
 func (c *Client) ReadSecurityRule(ctx context.Context, input security.ReadInput) (security.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadSecurityRule")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return security.ReadOutput{}, permErr
@@ -90,6 +96,8 @@ func (c *Client) ReadSecurityRule(ctx context.Context, input security.ReadInput)
 
 // Update updates the given object.
 func (c *Client) UpdateSecurityRule(ctx context.Context, input security.Info) error {
+	ctx = withOperation(ctx, "UpdateSecurityRule")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -117,6 +125,8 @@ func (c *Client) UpdateSecurityRule(ctx context.Context, input security.Info) er
 
 // Delete removes the given object from the config.
 func (c *Client) DeleteSecurityRule(ctx context.Context, input security.DeleteInput) error {
+	ctx = withOperation(ctx, "DeleteSecurityRule")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -0,0 +1,175 @@
+package aws
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// srpN is the 3072 bit group of RFC 5054, which Cognito uses for SRP.
+const srpN = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD1" +
+	"29024E088A67CC74020BBEA63B139B22514A08798E3404DD" +
+	"EF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245" +
+	"E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3D" +
+	"C2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F" +
+	"83655D23DCA3AD961C62F356208552BB9ED529077096966D" +
+	"670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9" +
+	"DE2BCBF6955817183995497CEA956AE515D2261898FA0510" +
+	"15728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64" +
+	"ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+	"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6B" +
+	"F12FFA06D98A0864D87602733EC86A64521F2B18177B200C" +
+	"BBE117577A615D6C770988C0BAD946E208E24FA074E5AB31" +
+	"43DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+
+// srpTimestampLayout is the format of the TIMESTAMP of a PASSWORD_VERIFIER
+// response. Cognito wants the day of the month without padding.
+const srpTimestampLayout = "Mon Jan 2 15:04:05 UTC 2006"
+
+var (
+	srpBigN, _ = new(big.Int).SetString(srpN, 16)
+	srpG       = big.NewInt(2)
+	srpK       = hashHex(padHex(srpBigN) + padHex(srpG))
+)
+
+/*
+srpSession is the client side of the SRP exchange of Cognito's
+USER_SRP_AUTH flow.
+
+SRP proves to Cognito that the client knows the password without ever
+sending it.
+*/
+type srpSession struct {
+	poolName string
+	a        *big.Int
+	bigA     *big.Int
+
+	// now is overridden by tests.
+	now func() time.Time
+}
+
+// newSRPSession starts an exchange for the given user pool ID.
+func newSRPSession(userPoolID string) (*srpSession, error) {
+	parts := strings.SplitN(userPoolID, "_", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid user pool ID %q", userPoolID)
+	}
+
+	b := make([]byte, 128)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	a := new(big.Int).SetBytes(b)
+	bigA := new(big.Int).Exp(srpG, a, srpBigN)
+	if bigA.Sign() == 0 {
+		return nil, fmt.Errorf("bad SRP secret")
+	}
+
+	return &srpSession{poolName: parts[1], a: a, bigA: bigA, now: time.Now}, nil
+}
+
+// A returns the SRP_A auth parameter.
+func (s *srpSession) A() string {
+	return s.bigA.Text(16)
+}
+
+/*
+verify answers the PASSWORD_VERIFIER challenge, returning the challenge
+responses other than USERNAME and SECRET_HASH.
+*/
+func (s *srpSession) verify(password string, params map[string]string) (map[string]string, error) {
+	userID := params["USER_ID_FOR_SRP"]
+	bigB, ok := new(big.Int).SetString(params["SRP_B"], 16)
+	if !ok || new(big.Int).Mod(bigB, srpBigN).Sign() == 0 {
+		return nil, fmt.Errorf("invalid SRP_B in the PASSWORD_VERIFIER challenge")
+	}
+	salt, ok := new(big.Int).SetString(params["SALT"], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid SALT in the PASSWORD_VERIFIER challenge")
+	}
+	secretBlock, err := base64.StdEncoding.DecodeString(params["SECRET_BLOCK"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid SECRET_BLOCK in the PASSWORD_VERIFIER challenge: %w", err)
+	}
+
+	u := hashHex(padHex(s.bigA) + padHex(bigB))
+	if u.Sign() == 0 {
+		return nil, fmt.Errorf("invalid SRP_B in the PASSWORD_VERIFIER challenge")
+	}
+
+	up := sha256.Sum256([]byte(s.poolName + userID + ":" + password))
+	x := hashHex(padHex(salt) + hex.EncodeToString(up[:]))
+
+	// S = (B - k * g^x) ^ (a + u * x) mod N
+	gx := new(big.Int).Exp(srpG, x, srpBigN)
+	base := new(big.Int).Sub(bigB, new(big.Int).Mul(srpK, gx))
+	base.Mod(base, srpBigN)
+	exp := new(big.Int).Add(s.a, new(big.Int).Mul(u, x))
+	bigS := new(big.Int).Exp(base, exp, srpBigN)
+
+	key := srpKey(bigS, u)
+	timestamp := s.now().UTC().Format(srpTimestampLayout)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s.poolName))
+	mac.Write([]byte(userID))
+	mac.Write(secretBlock)
+	mac.Write([]byte(timestamp))
+
+	return map[string]string{
+		"TIMESTAMP":                   timestamp,
+		"PASSWORD_CLAIM_SECRET_BLOCK": params["SECRET_BLOCK"],
+		"PASSWORD_CLAIM_SIGNATURE":    base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+	}, nil
+}
+
+// srpKey derives the 16 byte key of the PASSWORD_CLAIM_SIGNATURE with
+// HKDF, salted with u.
+func srpKey(bigS, u *big.Int) []byte {
+	ikm, _ := hex.DecodeString(padHex(bigS))
+	salt, _ := hex.DecodeString(padHex(u))
+
+	prk := hmac.New(sha256.New, salt)
+	prk.Write(ikm)
+	okm := hmac.New(sha256.New, prk.Sum(nil))
+	okm.Write([]byte("Caldera Derived Key\x01"))
+	return okm.Sum(nil)[:16]
+}
+
+/*
+padHex returns the hex encoding of n as Cognito hashes it: an even number
+of digits, with a leading zero byte if the high bit would otherwise be set.
+*/
+func padHex(n *big.Int) string {
+	h := n.Text(16)
+	if len(h)%2 == 1 {
+		return "0" + h
+	}
+	if strings.ContainsRune("89abcdef", rune(h[0])) {
+		return "00" + h
+	}
+	return h
+}
+
+// hashHex returns the SHA256 hash of the bytes encoded by h, as a number.
+func hashHex(h string) *big.Int {
+	b, _ := hex.DecodeString(h)
+	sum := sha256.Sum256(b)
+	return new(big.Int).SetBytes(sum[:])
+}
+
+// secretHash returns the SECRET_HASH of a user for an app client with a
+// secret.
+func secretHash(username, clientID, clientSecret string) string {
+	mac := hmac.New(sha256.New, []byte(clientSecret))
+	mac.Write([]byte(username + clientID))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
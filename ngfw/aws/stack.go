@@ -22,6 +22,8 @@ const (
 
 // List returns a list of objects.
 func (c *Client) ListRuleStack(ctx context.Context, input stack.ListInput) (stack.ListOutput, error) {
+	ctx = withOperation(ctx, "ListRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return stack.ListOutput{}, permErr
@@ -85,6 +87,8 @@ func (c *Client) ListRuleStack(ctx context.Context, input stack.ListInput) (stac
 
 // Create creates an object.
 func (c *Client) CreateRuleStack(ctx context.Context, input stack.Info) error {
+	ctx = withOperation(ctx, "CreateRuleStack")
+
 	perm, permErr := GetPermission(input.Entry.Scope)
 	if permErr != nil {
 		return permErr
@@ -109,6 +113,8 @@ func (c *Client) CreateRuleStack(ctx context.Context, input stack.Info) error {
 
 // Read returns information on the given object.
 func (c *Client) ReadRuleStack(ctx context.Context, input stack.ReadInput) (stack.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return stack.ReadOutput{}, permErr
@@ -145,6 +151,8 @@ func (c *Client) ReadRuleStack(ctx context.Context, input stack.ReadInput) (stac
 
 // export returns the rulestack XML.
 func (c *Client) ExportRuleStackXML(ctx context.Context, input stack.ReadInput) (stack.ExportRulestackXmlOutput, error) {
+	ctx = withOperation(ctx, "ExportRuleStackXML")
+
 	scope := LocalScope
 	if input.Scope != "" {
 		scope = input.Scope
@@ -202,6 +210,8 @@ func B64EncodeGzip(data []byte) (string, error) {
 
 // savepanrs saves the panorama rulestack XML in S3 bucket.
 func (c *Client) SaveRuleStackXML(ctx context.Context, input stack.SaveRulestackXmlInput) error {
+	ctx = withOperation(ctx, "SaveRuleStackXML")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -231,6 +241,8 @@ func (c *Client) SaveRuleStackXML(ctx context.Context, input stack.SaveRulestack
 }
 
 func (c *Client) CreateSCMRuleStack(ctx context.Context, input stack.CreateSCMRuleStackInput) error {
+	ctx = withOperation(ctx, "CreateSCMRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -261,6 +273,8 @@ func (c *Client) CreateSCMRuleStack(ctx context.Context, input stack.CreateSCMRu
 
 // Update updates the given object.
 func (c *Client) UpdateRuleStack(ctx context.Context, input stack.Info) error {
+	ctx = withOperation(ctx, "UpdateRuleStack")
+
 	perm, permErr := GetPermission(input.Entry.Scope)
 	if permErr != nil {
 		return permErr
@@ -288,6 +302,8 @@ func (c *Client) UpdateRuleStack(ctx context.Context, input stack.Info) error {
 
 // Delete removes the given object from the config.
 func (c *Client) DeleteRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	ctx = withOperation(ctx, "DeleteRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -312,6 +328,8 @@ func (c *Client) DeleteRuleStack(ctx context.Context, input stack.SimpleInput) e
 
 // Commit commits the rulestack configuration.
 func (c *Client) CommitRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	ctx = withOperation(ctx, "CommitRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -339,23 +357,31 @@ func (c *Client) PollCommitRuleStack(ctx context.Context, input stack.SimpleInpu
 	c.Log(http.MethodGet, "begin commit polling: %s", input.Name)
 	defer c.Log(http.MethodGet, "end commit polling: %s", input.Name)
 
-	ans, err := c.CommitStatusRuleStack(ctx, input)
-	if err != nil {
-		return ans, err
-	}
+	var ans stack.CommitStatus
+	err := c.wait(ctx, "PollCommitRuleStack", func(ctx context.Context) (bool, error) {
+		var err error
+		ans, err = c.CommitStatusRuleStack(ctx, input)
+		if err != nil {
+			return false, err
+		}
 
-	switch ans.Response.CommitStatus {
-	case api.RsCommitStatusPending:
-		return ans, nil
-	case api.RsCommitStatusSuccess:
-		return ans, nil
-	default:
-		return ans, fmt.Errorf(ans.CommitErrors())
-	}
+		switch ans.Response.CommitStatus {
+		case api.RsCommitStatusPending:
+			return true, fmt.Errorf("rulestack %q commit is still pending", input.Name)
+		case api.RsCommitStatusSuccess:
+			return false, nil
+		default:
+			return false, fmt.Errorf(ans.CommitErrors())
+		}
+	})
+
+	return ans, err
 }
 
 // CommitStatus gets the commit status.
 func (c *Client) CommitStatusRuleStack(ctx context.Context, input stack.SimpleInput) (stack.CommitStatus, error) {
+	ctx = withOperation(ctx, "CommitStatusRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return stack.CommitStatus{}, permErr
@@ -381,6 +407,8 @@ func (c *Client) CommitStatusRuleStack(ctx context.Context, input stack.SimpleIn
 
 // Revert reverts to the last good config.
 func (c *Client) RevertRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	ctx = withOperation(ctx, "RevertRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -405,6 +433,8 @@ func (c *Client) RevertRuleStack(ctx context.Context, input stack.SimpleInput) e
 
 // Validate validates the rulestack config.
 func (c *Client) ValidateRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	ctx = withOperation(ctx, "ValidateRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -429,6 +459,8 @@ func (c *Client) ValidateRuleStack(ctx context.Context, input stack.SimpleInput)
 
 // ListTags returns the list of tags for this rulestack.
 func (c *Client) ListTagsRuleStack(ctx context.Context, input stack.ListTagsInput) (stack.ListTagsOutput, error) {
+	ctx = withOperation(ctx, "ListTagsRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return stack.ListTagsOutput{}, permErr
@@ -465,6 +497,8 @@ func (c *Client) ListTagsRuleStack(ctx context.Context, input stack.ListTagsInpu
 
 // AddTags adds tags to the specified rulestack.
 func (c *Client) AddTagsRuleStack(ctx context.Context, input stack.AddTagsInput) error {
+	ctx = withOperation(ctx, "AddTagsRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -489,6 +523,8 @@ func (c *Client) AddTagsRuleStack(ctx context.Context, input stack.AddTagsInput)
 
 // RemoveTags removes the given tags from the resource.
 func (c *Client) RemoveTagsRuleStack(ctx context.Context, input stack.RemoveTagsInput) error {
+	ctx = withOperation(ctx, "RemoveTagsRuleStack")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -0,0 +1,307 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+// Keys of the tokens that are good for more than one permission.
+const (
+	tokenCloudRulestack = "cloud rulestack"
+	tokenCognito        = "cognito"
+)
+
+// Token is a JWT and the subscription key that goes with it.
+type Token struct {
+	Jwt             string
+	SubscriptionKey string
+
+	// Expires is when the JWT expires. The zero value means it never does.
+	Expires time.Time
+}
+
+// Valid returns true if the token has a JWT that is good for at least the
+// given margin.
+func (t Token) Valid(margin time.Duration) bool {
+	if t.Jwt == "" {
+		return false
+	}
+	return t.Expires.IsZero() || time.Until(t.Expires) > margin
+}
+
+// TokenSource fetches a new token for a permission (one of the Permission
+// constants).
+type TokenSource interface {
+	Token(ctx context.Context, permission string) (Token, error)
+}
+
+// TokenSourceFunc lets an ordinary function be used as a TokenSource.
+type TokenSourceFunc func(ctx context.Context, permission string) (Token, error)
+
+// Token calls f(ctx, permission).
+func (f TokenSourceFunc) Token(ctx context.Context, permission string) (Token, error) {
+	return f(ctx, permission)
+}
+
+// StaticTokens is a TokenSource that always returns the same token for each
+// permission.
+type StaticTokens map[string]Token
+
+// Token implements TokenSource.
+func (s StaticTokens) Token(_ context.Context, permission string) (Token, error) {
+	tok, ok := s[permission]
+	if !ok {
+		return Token{}, fmt.Errorf("no static token for permission %q", permission)
+	}
+	return tok, nil
+}
+
+/*
+TokenProvider hands out the token that Communicate sends for a permission.
+
+Implementations must be safe for concurrent use.
+*/
+type TokenProvider interface {
+	// Token returns a valid token for the permission, fetching one if
+	// needed.
+	Token(ctx context.Context, permission string) (Token, error)
+
+	// Invalidate drops the cached token for the permission, so the next
+	// call to Token fetches a new one.
+	Invalidate(permission string)
+}
+
+/*
+NewTokenProvider returns a TokenProvider that caches one token per
+permission, fetching a new one from src once the cached token is within
+margin of expiring.
+
+Calls for different permissions don't block each other, and concurrent calls
+for the same permission share a single fetch.
+*/
+func NewTokenProvider(src TokenSource, margin time.Duration) TokenProvider {
+	return &tokenCache{
+		source:  src,
+		margin:  margin,
+		entries: make(map[string]*tokenEntry),
+	}
+}
+
+type tokenEntry struct {
+	mu  sync.Mutex
+	tok Token
+}
+
+type tokenCache struct {
+	source TokenSource
+	margin time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*tokenEntry
+}
+
+func (tc *tokenCache) entry(permission string) *tokenEntry {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	e := tc.entries[permission]
+	if e == nil {
+		e = &tokenEntry{}
+		tc.entries[permission] = e
+	}
+	return e
+}
+
+// Token implements TokenProvider.
+func (tc *tokenCache) Token(ctx context.Context, permission string) (Token, error) {
+	e := tc.entry(permission)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.tok.Valid(tc.margin) {
+		return e.tok, nil
+	}
+
+	tok, err := tc.source.Token(ctx, permission)
+	if err != nil {
+		e.tok = Token{}
+		return Token{}, err
+	}
+	e.tok = tok
+	return tok, nil
+}
+
+// Invalidate implements TokenProvider.
+func (tc *tokenCache) Invalidate(permission string) {
+	e := tc.entry(permission)
+	e.mu.Lock()
+	e.tok = Token{}
+	e.mu.Unlock()
+}
+
+/*
+tokens returns the client's TokenProvider.
+
+If the client has none, a TokenManager is built for its AuthType the first
+time it is needed: IAM roles are assumed through STS, the external ID is
+exchanged for a token over mTLS, and Cognito users log in with their
+password. Clients without an AuthType send the JWTs stored in their
+*AdminJwt fields as is.
+
+Tokens are fetched when needed, unless BackgroundTokenRefresh is set.
+
+If the client has a TokenCacheDir, fetched tokens are shared with other
+processes through a DiskTokenCache. Fetches failing on transient errors
+suspend auth for a while, see AuthHealth.
+*/
+func (c *Client) tokens() TokenProvider {
+	c.tokenOnce.Do(func() {
+		if c.TokenProvider != nil {
+			c.tokenProvider = c.TokenProvider
+			return
+		}
+
+		opts := TokenManagerOptions{
+			Margin:      60 * time.Second,
+			Background:  c.BackgroundTokenRefresh,
+			IdleTimeout: TokenIdleTimeout,
+		}
+		switch c.AuthType {
+		case AuthTypeIAMRole:
+			opts.Margin = 10 * time.Second
+			c.tokenProvider = NewTokenManager(c.cached(c.guard(c.instrument(c.iamRoles()))), opts)
+		case AuthTypeExternalID:
+			c.tokenProvider = NewTokenManager(c.cached(c.guard(c.instrument(&externalIDSource{c: c}))), opts)
+		case AuthTypeCognito:
+			c.tokenProvider = NewTokenManager(c.cached(c.guard(c.instrument(&cognitoSource{c: c}))), opts)
+		default:
+			c.tokenProvider = fieldTokens{c: c}
+		}
+	})
+	return c.tokenProvider
+}
+
+// instrument reports every fetch done by src to the client's Metrics.
+func (c *Client) instrument(src TokenSource) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context, permission string) (Token, error) {
+		tok, err := src.Token(ctx, permission)
+		c.metrics().TokenRefresh(permission, err)
+		if err != nil {
+			c.logger().Error("failed to refresh JWT", logging.F("permission", permission), logging.F("error", err))
+		}
+		return tok, err
+	})
+}
+
+// tokenKey maps a permission onto the token that grants it. Tokens fetched
+// with the external ID or from Cognito are good for all but the account
+// admin permission.
+func (c *Client) tokenKey(auth string) string {
+	switch c.AuthType {
+	case AuthTypeExternalID:
+		if auth != PermissionAccount {
+			return tokenCloudRulestack
+		}
+	case AuthTypeCognito:
+		if auth != PermissionAccount {
+			return tokenCognito
+		}
+	}
+	return auth
+}
+
+// token returns the token to send for the given permission.
+func (c *Client) token(ctx context.Context, auth string) (Token, error) {
+	key := c.tokenKey(auth)
+	p := c.tokens()
+	tok, err := p.Token(ctx, key)
+	if err != nil {
+		return Token{}, err
+	}
+	if _, ok := p.(fieldTokens); !ok {
+		if mu, _, _, _ := c.tokenFields(key); mu == nil {
+			key = auth
+		}
+		c.storeToken(key, tok)
+	}
+	return tok, nil
+}
+
+/*
+tokenFields returns the deprecated client fields that hold the token for the
+given key.
+
+Tokens handed out by the TokenProvider are copied into these fields, so code
+that still reads them keeps working.
+*/
+func (c *Client) tokenFields(key string) (*sync.Mutex, *string, *string, *time.Time) {
+	switch key {
+	case PermissionFirewall:
+		return &c.FirewallAdminMutex, &c.FirewallAdminJwt, &c.FirewallSubscriptionKey, &c.FirewallAdminJwtExpTime
+	case PermissionRulestack:
+		return &c.RulestackAdminMutex, &c.RulestackAdminJwt, &c.RulestackSubscriptionKey, &c.RulestackAdminJwtExpTime
+	case PermissionGlobalRulestack:
+		return &c.GlobalRulestackAdminMutex, &c.GlobalRulestackAdminJwt, &c.GlobalRulestackSubscriptionKey, &c.GlobalRulestackAdminJwtExpTime
+	case PermissionAccount:
+		return &c.AccountAdminMutex, &c.AccountAdminJwt, &c.AccountAdminSubscriptionKey, &c.AccountAdminJwtExpTime
+	case tokenCloudRulestack:
+		return &c.CloudRulestackAdminMutex, &c.CloudRulestackAdminJwt, &c.CloudRulestackSubscriptionKey, &c.CloudRulestackAdminJwtExpTime
+	}
+	return nil, nil, nil, nil
+}
+
+// storeToken copies tok into the deprecated client fields for key.
+func (c *Client) storeToken(key string, tok Token) {
+	mu, jwt, sk, exp := c.tokenFields(key)
+	if mu == nil {
+		return
+	}
+	mu.Lock()
+	*jwt, *sk, *exp = tok.Jwt, tok.SubscriptionKey, tok.Expires
+	mu.Unlock()
+}
+
+/*
+fieldTokens is the TokenProvider of clients without an AuthType.
+
+It never fetches anything, and instead returns the JWT and subscription key
+that were stored in the client's fields.
+*/
+type fieldTokens struct {
+	c *Client
+}
+
+func (f fieldTokens) Token(_ context.Context, permission string) (Token, error) {
+	mu, jwt, sk, exp := f.c.tokenFields(permission)
+	if mu == nil {
+		return Token{}, fmt.Errorf("Unknown permission required: %q", permission)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return Token{Jwt: *jwt, SubscriptionKey: *sk, Expires: *exp}, nil
+}
+
+func (fieldTokens) Invalidate(string) {}
+
+/*
+Close stops the background work of the client, such as token refreshes. It
+is only needed with BackgroundTokenRefresh.
+
+The client must not be used afterwards.
+*/
+func (c *Client) Close() error {
+	// A TokenProvider given by the caller may be shared, and is theirs to
+	// close.
+	if c.TokenProvider != nil {
+		return nil
+	}
+	if p, ok := c.tokenProvider.(interface{ Close() error }); ok {
+		return p.Close()
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestTokenProviderAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	perms := map[string]string{
+		PermissionFirewall:  ngfwtest.PermFirewall,
+		PermissionRulestack: ngfwtest.PermRulestack,
+	}
+	tp := NewTokenProvider(TokenSourceFunc(func(_ context.Context, permission string) (Token, error) {
+		mu.Lock()
+		fetches[permission]++
+		mu.Unlock()
+		jwt, sk := srv.Token(perms[permission])
+		return Token{Jwt: jwt, SubscriptionKey: sk, Expires: time.Now().Add(time.Hour)}, nil
+	}), 10*time.Second)
+
+	c := &Client{
+		Host:          srv.Host(),
+		V2Host:        srv.Host(),
+		Protocol:      "http",
+		Region:        ngfwtest.DefaultRegion,
+		TokenProvider: tp,
+	}
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := c.ListRuleStack(ctx, stack.ListInput{})
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.ListFirewall(ctx, firewall.ListInput{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches[PermissionRulestack] != 1 || fetches[PermissionFirewall] != 1 {
+		t.Fatalf("tokens were fetched more than once: %v", fetches)
+	}
+
+	tp.Invalidate(PermissionRulestack)
+	if _, err := c.ListRuleStack(ctx, stack.ListInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if fetches[PermissionRulestack] != 2 {
+		t.Fatalf("invalidated token was not fetched again: %v", fetches)
+	}
+	if c.RulestackAdminJwt == "" {
+		t.Fatalf("rulestack JWT was not copied into the client")
+	}
+}
+
+// TestTokenRefreshDuringRequests overlaps token refreshes, which set the
+// tenant version, with API calls reading it. Run with -race.
+func TestTokenRefreshDuringRequests(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	// The tokens are served from memory: the race detector orders all
+	// socket I/O, which would hide a race between a refresh and a call.
+	jwt, key := srv.Token(ngfwtest.PermCloudManager)
+	body, err := json.Marshal(stack.AuthOutput{Response: stack.AuthOutputDetails{TokenId: jwt, SubscriptionKey: key, ExpiryTime: 60, Enabled: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &http.Client{Transport: RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		rec.Write(body)
+		return rec.Result(), nil
+	})}
+
+	c := &Client{AuthType: AuthTypeExternalID}
+	err = c.SetupUsingCreds(ctx, AuthInfo{
+		ExternalID:       "ext-1234",
+		Region:           ngfwtest.DefaultRegion,
+		HttpClient:       srv.Client(),
+		SecureHttpClient: auth,
+		RegionURL:        srv.URL,
+		RegionV2URL:      srv.URL,
+		AuthURL:          srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			c.tokens().Invalidate(tokenCloudRulestack)
+			if _, err := c.tokens().Token(ctx, tokenCloudRulestack); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		if _, err := c.ListRuleStack(ctx, stack.ListInput{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
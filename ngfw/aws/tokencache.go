@@ -0,0 +1,293 @@
+package aws
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+)
+
+// DefaultTokenCacheMargin is how long a cached token must still be good
+// for to be used, when DiskTokenCache.Margin is zero.
+const DefaultTokenCacheMargin = 60 * time.Second
+
+/*
+DiskTokenCache is a TokenSource that keeps the tokens fetched by another
+source in files, so separate processes can share them.
+
+Each token is stored in its own file, named after a hash of its scope and
+encrypted with AES-256-GCM. The scope is also authenticated, so a file can't
+be passed off as the token of another scope. Files that can't be decrypted,
+say because the key changed, are treated as missing.
+
+A cached token is only used while it is good for more than Margin and a
+quarter of its lifetime, so a process refreshing a token ahead of expiry
+gets a new one rather than the one it is replacing.
+
+A file is locked while its token is read and, if needed, fetched, so
+concurrent processes wait for one another instead of all fetching the same
+token.
+*/
+type DiskTokenCache struct {
+	// Dir is the directory holding the cache files. It is created if
+	// needed.
+	Dir string
+
+	// Source fetches the tokens that are not in the cache.
+	Source TokenSource
+
+	// Scope returns the cache key of the token for a permission. Tokens
+	// that are not interchangeable must have different scopes. If nil, the
+	// permission itself is used.
+	Scope func(permission string) string
+
+	// Margin is how long a cached token must still be good for to be used.
+	// If zero, DefaultTokenCacheMargin is used.
+	Margin time.Duration
+
+	aead cipher.AEAD
+}
+
+// NewDiskTokenCache returns a DiskTokenCache storing the tokens of src in
+// dir, encrypted with a key derived from the given secret.
+func NewDiskTokenCache(dir, secret string, src TokenSource, scope func(permission string) string) (*DiskTokenCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no token cache directory given")
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("no token cache key given")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskTokenCache{
+		Dir:    dir,
+		Source: src,
+		Scope:  scope,
+		aead:   aead,
+	}, nil
+}
+
+// cachedToken is the plaintext of a cache file.
+type cachedToken struct {
+	Jwt             string    `json:"jwt"`
+	SubscriptionKey string    `json:"subscription_key,omitempty"`
+	Expires         time.Time `json:"expires,omitempty"`
+	Fetched         time.Time `json:"fetched"`
+}
+
+// fresh returns true if the token is good for more than margin and a
+// quarter of its lifetime.
+func (ct cachedToken) fresh(margin time.Duration) bool {
+	tok := Token{Jwt: ct.Jwt, Expires: ct.Expires}
+	if !tok.Valid(margin) {
+		return false
+	}
+	return ct.Expires.IsZero() || time.Until(ct.Expires) > ct.Expires.Sub(ct.Fetched)/4
+}
+
+// Token implements TokenSource.
+func (d *DiskTokenCache) Token(ctx context.Context, permission string) (Token, error) {
+	scope := permission
+	if d.Scope != nil {
+		scope = d.Scope(permission)
+	}
+	margin := d.Margin
+	if margin == 0 {
+		margin = DefaultTokenCacheMargin
+	}
+
+	if err := os.MkdirAll(d.Dir, 0700); err != nil {
+		return Token{}, err
+	}
+	sum := sha256.Sum256([]byte(scope))
+	path := filepath.Join(d.Dir, hex.EncodeToString(sum[:]))
+
+	unlock, err := lockFile(ctx, path+".lock")
+	if err != nil {
+		return Token{}, err
+	}
+	defer unlock()
+
+	if ct, err := d.read(path, scope); err == nil && ct.fresh(margin) {
+		return Token{Jwt: ct.Jwt, SubscriptionKey: ct.SubscriptionKey, Expires: ct.Expires}, nil
+	}
+
+	tok, err := d.Source.Token(ctx, permission)
+	if err != nil {
+		return Token{}, err
+	}
+	if err = d.write(path, scope, tok); err != nil {
+		return Token{}, err
+	}
+	return tok, nil
+}
+
+// read decrypts the token in path.
+func (d *DiskTokenCache) read(path, scope string) (cachedToken, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	n := d.aead.NonceSize()
+	if len(b) < n {
+		return cachedToken{}, fmt.Errorf("token cache file %s is truncated", path)
+	}
+	plain, err := d.aead.Open(nil, b[:n], b[n:], []byte(scope))
+	if err != nil {
+		return cachedToken{}, err
+	}
+
+	var ct cachedToken
+	err = json.Unmarshal(plain, &ct)
+	return ct, err
+}
+
+// write encrypts tok into path, replacing the file in a single rename so
+// readers never see a partial write.
+func (d *DiskTokenCache) write(path, scope string, tok Token) error {
+	plain, err := json.Marshal(cachedToken{
+		Jwt:             tok.Jwt,
+		SubscriptionKey: tok.SubscriptionKey,
+		Expires:         tok.Expires,
+		Fetched:         time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, d.aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	b := d.aead.Seal(nonce, nonce, plain, []byte(scope))
+
+	f, err := ioutil.TempFile(d.Dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("file is locked")
+
+/*
+lockFile takes an exclusive lock on path, waiting for other processes to
+release it. The returned function releases the lock.
+*/
+func lockFile(ctx context.Context, path string) (func(), error) {
+	delay := 10 * time.Millisecond
+	for {
+		unlock, err := tryLock(path)
+		if err != errLocked {
+			return unlock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay < time.Second {
+			delay *= 2
+		}
+	}
+}
+
+/*
+cached wraps src in a DiskTokenCache if the client has a TokenCacheDir.
+
+Tokens are cached per tenant, region, permission and identity: the role ARN
+for IAM roles, the external ID or the Cognito user name.
+*/
+func (c *Client) cached(src TokenSource) TokenSource {
+	if c.TokenCacheDir == "" {
+		return src
+	}
+
+	d, err := NewDiskTokenCache(c.TokenCacheDir, c.TokenCacheKey, src, c.tokenScope)
+	if err != nil {
+		c.logger().Error("token cache disabled", logging.F("error", err))
+		return src
+	}
+	return TokenSourceFunc(func(ctx context.Context, permission string) (Token, error) {
+		tok, err := d.Token(ctx, permission)
+		if err != nil {
+			return Token{}, err
+		}
+		// Tokens read from the cache haven't been through the source, so the
+		// tenant version is taken from them here. Cognito tokens don't carry
+		// one.
+		if c.AuthType != AuthTypeCognito {
+			if err = c.SetTenantVersion(tok.Jwt); err != nil {
+				return Token{}, err
+			}
+		}
+		return tok, nil
+	})
+}
+
+// tokenScope returns the key of the cached token for a permission.
+func (c *Client) tokenScope(permission string) string {
+	region := c.Region
+	var identity string
+	switch c.AuthType {
+	case AuthTypeIAMRole:
+		switch permission {
+		case PermissionFirewall:
+			identity = c.LfaArn
+		case PermissionRulestack:
+			identity = c.LraArn
+		case PermissionGlobalRulestack:
+			identity = c.GraArn
+		case PermissionAccount:
+			identity, region = c.AcctAdminArn, c.MPRegion
+		}
+		if identity == "" && permission != PermissionAccount {
+			identity = c.Arn
+		}
+		// The same role assumed another way, such as through other roles or
+		// for a different session, gets tokens of its own.
+		opts, _ := json.Marshal(c.assumeRoleOptions(permission))
+		identity += "\x00" + string(opts)
+	case AuthTypeExternalID:
+		identity = c.ExternalID
+	case AuthTypeCognito:
+		identity = c.UserName
+	}
+
+	return strings.Join([]string{c.AuthType, c.Tenant, region, permission, identity}, "\x00")
+}
@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiskTokenCache(t *testing.T) {
+	dir := t.TempDir()
+	var fetches int32
+	src := TokenSourceFunc(func(ctx context.Context, permission string) (Token, error) {
+		n := atomic.AddInt32(&fetches, 1)
+		return Token{Jwt: fmt.Sprintf("jwt-%d", n), SubscriptionKey: "sk", Expires: time.Now().Add(time.Hour)}, nil
+	})
+	scope := func(permission string) string { return "tenant\x00us-east-1\x00" + permission }
+
+	newCache := func(secret string) *DiskTokenCache {
+		d, err := NewDiskTokenCache(dir, secret, src, scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	// Two caches on the same directory stand in for two processes.
+	a, b := newCache("secret"), newCache("secret")
+	first, err := a.Token(context.Background(), PermissionRulestack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := b.Token(context.Background(), PermissionRulestack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Jwt != first.Jwt || second.SubscriptionKey != "sk" || !second.Expires.Equal(first.Expires) {
+		t.Fatalf("cached token differs: %+v vs %+v", second, first)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("token was fetched %d times", n)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range files {
+		b, _ := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if strings.Contains(string(b), first.Jwt) {
+			t.Fatalf("%s holds the JWT in the clear", fi.Name())
+		}
+	}
+
+	// Other permissions and other keys don't see the token.
+	if tok, _ := b.Token(context.Background(), PermissionFirewall); tok.Jwt == first.Jwt {
+		t.Fatalf("token was shared across permissions")
+	}
+	if tok, _ := newCache("other").Token(context.Background(), PermissionRulestack); tok.Jwt == first.Jwt {
+		t.Fatalf("token was decrypted with the wrong key")
+	}
+}
@@ -0,0 +1,256 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by a TokenManager that has been closed.
+var ErrClosed = errors.New("token manager is closed")
+
+// TokenManagerOptions configures a TokenManager.
+type TokenManagerOptions struct {
+	// Margin is how long before it expires a token stops being handed out.
+	Margin time.Duration
+
+	// RefreshAhead is how long before it expires a token is refreshed in
+	// the background. It is raised to Margin if smaller. If zero, a fifth
+	// of the token's lifetime is used.
+	RefreshAhead time.Duration
+
+	// MinBackoff is the delay before the first retry of a failed background
+	// refresh. Retries back off exponentially up to MaxBackoffTime.
+	MinBackoff time.Duration
+
+	// Background turns on the refreshing of tokens ahead of expiry, and the
+	// retrying of failed refreshes. Without it, tokens are only fetched
+	// when asked for and missing or about to expire.
+	Background bool
+
+	// IdleTimeout stops the background refreshes of a permission that has
+	// not been asked for in that long. If zero, they go on until Close.
+	IdleTimeout time.Duration
+}
+
+/*
+TokenManager is a TokenProvider that caches tokens, and optionally refreshes
+them in the background.
+
+Concurrent requests for a token that has to be fetched share a single fetch.
+
+With the Background option, once a permission has been asked for its token
+is refreshed ahead of expiry, so requests don't wait on a refresh. Failed
+background refreshes are retried with exponential backoff, capped at
+MaxBackoffTime. Close stops all background work, and must be called once the
+manager is no longer used unless an IdleTimeout is set.
+*/
+type TokenManager struct {
+	source TokenSource
+	opts   TokenManagerOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	entries map[string]*managedToken
+	closed  bool
+}
+
+type managedToken struct {
+	tok      Token
+	err      error
+	failures int
+	used     time.Time
+	fetching *tokenFetch
+	timer    *time.Timer
+}
+
+// tokenFetch is a fetch in flight. Its result is set before done is closed.
+type tokenFetch struct {
+	done chan struct{}
+	tok  Token
+	err  error
+}
+
+// NewTokenManager returns a TokenManager fetching tokens from src.
+func NewTokenManager(src TokenSource, opts TokenManagerOptions) *TokenManager {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &TokenManager{
+		source:  src,
+		opts:    opts,
+		ctx:     ctx,
+		cancel:  cancel,
+		entries: make(map[string]*managedToken),
+	}
+}
+
+// Token implements TokenProvider.
+func (m *TokenManager) Token(ctx context.Context, permission string) (Token, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return Token{}, ErrClosed
+	}
+	e := m.entries[permission]
+	if e == nil {
+		e = &managedToken{}
+		m.entries[permission] = e
+	}
+	e.used = time.Now()
+	if e.tok.Valid(m.opts.Margin) {
+		tok := e.tok
+		m.mu.Unlock()
+		return tok, nil
+	}
+	f := e.fetching
+	if f == nil {
+		f = m.fetch(permission, e)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return Token{}, ctx.Err()
+	}
+
+	// The result of the fetch waited for is used even if the token has
+	// since been invalidated.
+	switch {
+	case f.err != nil:
+		return Token{}, f.err
+	case f.tok.Valid(m.opts.Margin):
+		return f.tok, nil
+	}
+	return Token{}, fmt.Errorf("the %s token expires too soon to be used", permission)
+}
+
+/*
+fetch starts fetching the token for e in the background. Any scheduled
+refresh is cancelled.
+
+This must be called with m.mu taken.
+*/
+func (m *TokenManager) fetch(permission string, e *managedToken) *tokenFetch {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+
+	f := &tokenFetch{done: make(chan struct{})}
+	e.fetching = f
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		tok, err := m.source.Token(m.ctx, permission)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if err != nil {
+			e.err = err
+			e.failures++
+			if m.closed {
+				err = ErrClosed
+			}
+		} else {
+			e.tok, e.err, e.failures = tok, nil, 0
+		}
+		f.tok, f.err = tok, err
+		e.fetching = nil
+		close(f.done)
+		if !m.closed && m.opts.Background {
+			m.schedule(permission, e)
+		}
+	}()
+
+	return f
+}
+
+/*
+schedule arranges for the next background refresh of e: ahead of expiry
+after a success, or after a backoff delay after a failure. Nothing is
+scheduled for a permission that has been idle for IdleTimeout.
+
+This must be called with m.mu taken.
+*/
+func (m *TokenManager) schedule(permission string, e *managedToken) {
+	if m.opts.IdleTimeout > 0 && time.Since(e.used) > m.opts.IdleTimeout {
+		return
+	}
+
+	var delay time.Duration
+	if e.err != nil {
+		delay = m.opts.MinBackoff
+		for i := 1; i < e.failures && delay < MaxBackoffTime; i++ {
+			delay *= 2
+		}
+		if delay > MaxBackoffTime {
+			delay = MaxBackoffTime
+		}
+	} else {
+		if e.tok.Expires.IsZero() {
+			return
+		}
+		ahead := m.opts.RefreshAhead
+		if ahead <= 0 {
+			ahead = time.Until(e.tok.Expires) / 5
+		}
+		if ahead < m.opts.Margin {
+			ahead = m.opts.Margin
+		}
+		delay = time.Until(e.tok.Expires.Add(-ahead))
+		if delay < 0 {
+			delay = 0
+		}
+	}
+
+	var t *time.Timer
+	t = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.closed || e.timer != t {
+			return
+		}
+		e.timer = nil
+		m.fetch(permission, e)
+	})
+	e.timer = t
+}
+
+// Invalidate implements TokenProvider.
+func (m *TokenManager) Invalidate(permission string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.entries[permission]; e != nil {
+		e.tok = Token{}
+	}
+}
+
+// Close stops all background refreshes, and waits for the ones in flight to
+// be abandoned.
+func (m *TokenManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	for _, e := range m.entries {
+		if e.timer != nil {
+			e.timer.Stop()
+			e.timer = nil
+		}
+	}
+	m.mu.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+	return nil
+}
@@ -0,0 +1,129 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenManagerSharesFetches(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	m := NewTokenManager(TokenSourceFunc(func(ctx context.Context, permission string) (Token, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return Token{Jwt: "jwt-" + permission, Expires: time.Now().Add(time.Hour)}, nil
+	}), TokenManagerOptions{})
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tok, err := m.Token(context.Background(), PermissionRulestack); err != nil || tok.Jwt != "jwt-rulestack" {
+				t.Errorf("got %+v, %v", tok, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("token was fetched %d times", n)
+	}
+}
+
+func TestTokenManagerRefreshesInBackground(t *testing.T) {
+	var fetches int32
+	fail := errors.New("auth endpoint is down")
+	m := NewTokenManager(TokenSourceFunc(func(ctx context.Context, permission string) (Token, error) {
+		switch atomic.AddInt32(&fetches, 1) {
+		case 2, 3:
+			return Token{}, fail
+		}
+		return Token{Jwt: "jwt", Expires: time.Now().Add(50 * time.Millisecond)}, nil
+	}), TokenManagerOptions{
+		Margin:       10 * time.Millisecond,
+		RefreshAhead: 40 * time.Millisecond,
+		MinBackoff:   time.Millisecond,
+		Background:   true,
+	})
+
+	if _, err := m.Token(context.Background(), PermissionFirewall); err != nil {
+		t.Fatal(err)
+	}
+
+	// One refresh right away, two failed retries, then a success.
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&fetches) < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d fetches were made", atomic.LoadInt32(&fetches))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	n := atomic.LoadInt32(&fetches)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&fetches) != n {
+		t.Fatalf("refreshes continued after Close")
+	}
+	if _, err := m.Token(context.Background(), PermissionFirewall); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestTokenManagerFetchesOnDemand(t *testing.T) {
+	var fetches int32
+	m := NewTokenManager(TokenSourceFunc(func(ctx context.Context, permission string) (Token, error) {
+		atomic.AddInt32(&fetches, 1)
+		return Token{Jwt: "jwt", Expires: time.Now().Add(30 * time.Millisecond)}, nil
+	}), TokenManagerOptions{Margin: 10 * time.Millisecond})
+
+	if _, err := m.Token(context.Background(), PermissionFirewall); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("the token was refreshed without being asked for, %d fetches", n)
+	}
+	if _, err := m.Token(context.Background(), PermissionFirewall); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Fatalf("the expired token was not fetched again, %d fetches", n)
+	}
+}
+
+func TestTokenManagerStopsWhenIdle(t *testing.T) {
+	var fetches int32
+	m := NewTokenManager(TokenSourceFunc(func(ctx context.Context, permission string) (Token, error) {
+		atomic.AddInt32(&fetches, 1)
+		return Token{Jwt: "jwt", Expires: time.Now().Add(20 * time.Millisecond)}, nil
+	}), TokenManagerOptions{
+		Margin:      5 * time.Millisecond,
+		Background:  true,
+		IdleTimeout: 30 * time.Millisecond,
+	})
+	defer m.Close()
+
+	if _, err := m.Token(context.Background(), PermissionFirewall); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	n := atomic.LoadInt32(&fetches)
+	if n < 2 {
+		t.Fatalf("the token was not refreshed in the background")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&fetches) != n {
+		t.Fatalf("refreshes went on after the idle timeout")
+	}
+}
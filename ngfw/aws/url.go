@@ -9,6 +9,8 @@ import (
 
 // List returns a list of objects.
 func (c *Client) ListUrlCustomCategory(ctx context.Context, input url.ListInput) (url.ListOutput, error) {
+	ctx = withOperation(ctx, "ListUrlCustomCategory")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return url.ListOutput{}, permErr
@@ -35,6 +37,8 @@ func (c *Client) ListUrlCustomCategory(ctx context.Context, input url.ListInput)
 
 // Create creates an object.
 func (c *Client) CreateUrlCustomCategory(ctx context.Context, input url.Info) error {
+	ctx = withOperation(ctx, "CreateUrlCustomCategory")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -60,6 +64,8 @@ func (c *Client) CreateUrlCustomCategory(ctx context.Context, input url.Info) er
 
 // Read returns information on the given object.
 func (c *Client) ReadUrlCustomCategory(ctx context.Context, input url.ReadInput) (url.ReadOutput, error) {
+	ctx = withOperation(ctx, "ReadUrlCustomCategory")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return url.ReadOutput{}, permErr
@@ -85,6 +91,8 @@ func (c *Client) ReadUrlCustomCategory(ctx context.Context, input url.ReadInput)
 
 // Update updates the given object.
 func (c *Client) UpdateUrlCustomCategory(ctx context.Context, input url.Info) error {
+	ctx = withOperation(ctx, "UpdateUrlCustomCategory")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
@@ -113,6 +121,8 @@ func (c *Client) UpdateUrlCustomCategory(ctx context.Context, input url.Info) er
 
 // Delete removes the given object from the config.
 func (c *Client) DeleteUrlCustomCategory(ctx context.Context, input url.DeleteInput) error {
+	ctx = withOperation(ctx, "DeleteUrlCustomCategory")
+
 	perm, permErr := GetPermission(input.Scope)
 	if permErr != nil {
 		return permErr
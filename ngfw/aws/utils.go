@@ -1,7 +1,6 @@
 package aws
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -31,21 +30,6 @@ func Contains(inputStr string, strList []string) bool {
 	return false
 }
 
-func WaitForOperation(ctx context.Context, op func(ctx context.Context) (bool, error)) error {
-	var err error
-	for i := 0; i < 120; i++ {
-		shouldRetry, err := op(ctx)
-		if err == nil {
-			return nil
-		}
-		if !shouldRetry {
-			return err
-		}
-		time.Sleep(30 * time.Second)
-	}
-	return fmt.Errorf("operation timed out: %v", err)
-}
-
 func SliceToMap(s []string) map[string]struct{} {
 	m := make(map[string]struct{})
 	for _, v := range s {
@@ -0,0 +1,152 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWaitTimeout is returned (wrapped) when a Waiter runs out of time.
+var ErrWaitTimeout = errors.New("operation timed out")
+
+// WaitProgress is passed to Waiter.OnProgress after each unfinished poll.
+type WaitProgress struct {
+	// Attempt is the number of polls done so far.
+	Attempt int
+
+	// Elapsed is the time since the wait started.
+	Elapsed time.Duration
+
+	// Delay is the time until the next poll.
+	Delay time.Duration
+
+	// State is the error returned by the poll, describing why the operation
+	// is not yet done.
+	State error
+}
+
+/*
+Waiter polls an operation until it is done, the overall timeout passes, or
+the context is cancelled.
+
+The delay between polls starts at MinDelay and doubles up to MaxDelay.
+*/
+type Waiter struct {
+	MinDelay time.Duration
+	MaxDelay time.Duration
+
+	// Timeout bounds the whole wait. If zero, the client's resource timeout
+	// is used.
+	Timeout time.Duration
+
+	// OnProgress, if set, is called after every poll that needs a retry.
+	OnProgress func(WaitProgress)
+}
+
+// DefaultWaiter is used when the client does not have a Waiter. Its
+// Timeout only applies if the client has no resource timeout.
+var DefaultWaiter = Waiter{
+	MinDelay: 5 * time.Second,
+	MaxDelay: 30 * time.Second,
+	Timeout:  60 * time.Minute,
+}
+
+/*
+Wait calls op until it returns a nil error, or an error with retry set to
+false.
+
+If the timeout passes first, an error wrapping ErrWaitTimeout and the last
+state is returned. If ctx is done first, its error is returned right away.
+*/
+func (w Waiter) Wait(ctx context.Context, op func(ctx context.Context) (bool, error)) error {
+	min, max, timeout := w.MinDelay, w.MaxDelay, w.Timeout
+	if min <= 0 {
+		min = DefaultWaiter.MinDelay
+	}
+	if max < min {
+		max = min
+	}
+	if timeout <= 0 {
+		timeout = DefaultWaiter.Timeout
+	}
+
+	start := time.Now()
+	wctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	delay := min
+	var state error
+	for attempt := 1; ; attempt++ {
+		retry, err := op(wctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !retry {
+			return err
+		}
+		if wctx.Err() != nil {
+			return fmt.Errorf("%w after %s: %v", ErrWaitTimeout, time.Since(start).Round(time.Second), err)
+		}
+		state = err
+
+		if w.OnProgress != nil {
+			w.OnProgress(WaitProgress{
+				Attempt: attempt,
+				Elapsed: time.Since(start),
+				Delay:   delay,
+				State:   state,
+			})
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-wctx.Done():
+			t.Stop()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w after %s: %v", ErrWaitTimeout, time.Since(start).Round(time.Second), state)
+		case <-t.C:
+		}
+
+		delay *= 2
+		if delay > max {
+			delay = max
+		}
+	}
+}
+
+// waiter returns the client's Waiter, bounded by the resource timeout
+// unless the Waiter has a Timeout of its own.
+func (c *Client) waiter(ctx context.Context) Waiter {
+	w := DefaultWaiter
+	if c.Waiter != nil {
+		w = *c.Waiter
+	}
+	if c.Waiter == nil || c.Waiter.Timeout == 0 {
+		if rt := c.GetResourceTimeout(ctx); rt > 0 {
+			w.Timeout = time.Duration(rt) * time.Second
+		}
+	}
+	return w
+}
+
+// wait polls op with the client's Waiter, recording the time spent under the
+// given operation name.
+func (c *Client) wait(ctx context.Context, operation string, op func(ctx context.Context) (bool, error)) error {
+	start := time.Now()
+	err := c.waiter(ctx).Wait(ctx, op)
+	c.metrics().Wait(operation, time.Since(start), err)
+	return err
+}
+
+// WaitForOperation polls op with the DefaultWaiter.
+//
+// Deprecated: Use Waiter.Wait, or the Client's Wait* functions.
+func WaitForOperation(ctx context.Context, op func(ctx context.Context) (bool, error)) error {
+	return DefaultWaiter.Wait(ctx, op)
+}
@@ -0,0 +1,83 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+func TestWaiterAgainstFake(t *testing.T) {
+	ctx := testContext()
+	srv := ngfwtest.NewServer()
+	srv.PendingPolls = 2
+	defer srv.Close()
+	c := newExternalIDClient(t, srv)
+
+	var progress []WaitProgress
+	c.Waiter = &Waiter{
+		MinDelay: time.Millisecond,
+		MaxDelay: 2 * time.Millisecond,
+		OnProgress: func(p WaitProgress) {
+			progress = append(progress, p)
+		},
+	}
+
+	if err := c.CreateRuleStack(ctx, stack.Info{Name: "rs1"}); err != nil {
+		t.Fatal(err)
+	}
+	si := stack.SimpleInput{Name: "rs1"}
+	if err := c.CommitRuleStack(ctx, si); err != nil {
+		t.Fatal(err)
+	}
+	status, err := c.PollCommitRuleStack(ctx, si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Response.CommitStatus != api.RsCommitStatusSuccess || len(progress) != 2 {
+		t.Fatalf("commit status %q after %d progress calls", status.Response.CommitStatus, len(progress))
+	}
+
+	c.Waiter.Timeout = 5 * time.Millisecond
+	c.Waiter.MinDelay = time.Hour
+	if err = c.CommitRuleStack(ctx, si); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = c.PollCommitRuleStack(ctx, si); !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected ErrWaitTimeout, got %v", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	c.Waiter.Timeout = 0
+	if _, err = c.PollCommitRuleStack(cctx, si); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Minute {
+		t.Fatalf("waits did not return promptly")
+	}
+}
+
+func TestWaiterTimeoutFromResourceTimeout(t *testing.T) {
+	ctx := testContext()
+	c := &Client{ResourceTimeout: 7200}
+
+	if w := c.waiter(ctx); w.Timeout != 2*time.Hour || w.MinDelay != DefaultWaiter.MinDelay {
+		t.Fatalf("the default waiter should use the resource timeout: %+v", w)
+	}
+
+	c.Waiter = &Waiter{MinDelay: time.Second}
+	if w := c.waiter(ctx); w.Timeout != 2*time.Hour {
+		t.Fatalf("a waiter without a timeout should use the resource timeout: %+v", w)
+	}
+
+	c.Waiter.Timeout = time.Minute
+	if w := c.waiter(ctx); w.Timeout != time.Minute {
+		t.Fatalf("the waiter's own timeout should win: %+v", w)
+	}
+}
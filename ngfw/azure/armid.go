@@ -0,0 +1,56 @@
+package azure
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FirewallResourceType is the ARM resource type of Cloud NGFW firewalls.
+const FirewallResourceType = "PaloAltoNetworks.Cloudngfw/firewalls"
+
+/*
+ArmId is an Azure Resource Manager resource ID:
+
+	/subscriptions/{SubscriptionId}/resourceGroups/{ResourceGroup}/providers/{Type}/{Name}
+
+For firewalls, Type is FirewallResourceType.
+*/
+type ArmId struct {
+	SubscriptionId string
+	ResourceGroup  string
+	Type           string
+	Name           string
+}
+
+// ParseArmId parses an ARM resource ID.
+func ParseArmId(id string) (ArmId, error) {
+	parts := strings.Split(strings.Trim(id, "/"), "/")
+	if len(parts) != 8 ||
+		!strings.EqualFold(parts[0], "subscriptions") ||
+		!strings.EqualFold(parts[2], "resourceGroups") ||
+		!strings.EqualFold(parts[4], "providers") {
+		return ArmId{}, fmt.Errorf("%q is not an ARM resource ID", id)
+	}
+	for _, p := range parts {
+		if p == "" {
+			return ArmId{}, fmt.Errorf("%q is not an ARM resource ID", id)
+		}
+	}
+
+	return ArmId{
+		SubscriptionId: parts[1],
+		ResourceGroup:  parts[3],
+		Type:           parts[5] + "/" + parts[6],
+		Name:           parts[7],
+	}, nil
+}
+
+// IsArmId returns true if s looks like an ARM resource ID rather than a
+// name.
+func IsArmId(s string) bool {
+	return strings.HasPrefix(strings.ToLower(s), "/subscriptions/")
+}
+
+func (a ArmId) String() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s/%s", a.SubscriptionId, a.ResourceGroup, a.Type, a.Name)
+}
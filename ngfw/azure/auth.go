@@ -0,0 +1,85 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws"
+)
+
+// DefaultScope is the Azure AD scope of the tokens sent to Cloud NGFW, when
+// the client has no Scopes.
+const DefaultScope = "https://management.azure.com/.default"
+
+// AccessToken is an Azure AD access token.
+type AccessToken struct {
+	Token     string
+	ExpiresOn time.Time
+}
+
+/*
+TokenCredential gets Azure AD access tokens for the given scopes.
+
+This is a subset of azcore.TokenCredential, so the credentials of the Azure
+identity library can be used through a small adapter:
+
+	cred, _ := azidentity.NewDefaultAzureCredential(nil)
+	c.Credential = azure.TokenCredentialFunc(func(ctx context.Context, scopes []string) (azure.AccessToken, error) {
+		tok, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: scopes})
+		return azure.AccessToken{Token: tok.Token, ExpiresOn: tok.ExpiresOn}, err
+	})
+*/
+type TokenCredential interface {
+	GetToken(ctx context.Context, scopes []string) (AccessToken, error)
+}
+
+// TokenCredentialFunc lets an ordinary function be used as a
+// TokenCredential.
+type TokenCredentialFunc func(ctx context.Context, scopes []string) (AccessToken, error)
+
+// GetToken calls f(ctx, scopes).
+func (f TokenCredentialFunc) GetToken(ctx context.Context, scopes []string) (AccessToken, error) {
+	return f(ctx, scopes)
+}
+
+/*
+credentialSource is a TokenSource getting Azure AD tokens from a
+TokenCredential.
+
+Azure AD tokens carry the roles of the caller, so the same token is good
+for every permission, and is only fetched once for all of them.
+*/
+type credentialSource struct {
+	cred   TokenCredential
+	scopes []string
+	apiKey string
+
+	mu  sync.Mutex
+	tok aws.Token
+}
+
+// Token implements aws.TokenSource.
+func (s *credentialSource) Token(ctx context.Context, permission string) (aws.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tok.Valid(time.Minute) {
+		return s.tok, nil
+	}
+
+	at, err := s.cred.GetToken(ctx, s.scopes)
+	if err != nil {
+		return aws.Token{}, fmt.Errorf("failed to get an Azure AD token: %w", err)
+	}
+	if at.Token == "" {
+		return aws.Token{}, fmt.Errorf("the Azure AD credential returned an empty token")
+	}
+	s.tok = aws.Token{
+		Jwt:             at.Token,
+		SubscriptionKey: s.apiKey,
+		Expires:         at.ExpiresOn,
+	}
+	return s.tok, nil
+}
@@ -0,0 +1,230 @@
+/*
+Package azure is the Azure Cloud NGFW client, implementing api.Client.
+
+The Cloud NGFW API is the same on every cloud, so the calls are made by an
+aws.Client underneath. What differs is the authentication, done with Azure
+AD tokens, and the identification of firewalls, which are Azure resources
+with ARM IDs: firewall names may be given as ARM IDs, and default to the
+client's SubscriptionId and ResourceGroup otherwise.
+
+	client, err := api.New(ctx, cloudngfwgosdk.CloudProviderAzure, api.WithSettings(&azure.Client{
+		Host:           "api.example.azure.cloudngfw.paloaltonetworks.com",
+		Region:         "eastus",
+		SubscriptionId: "00000000-0000-0000-0000-000000000000",
+		ResourceGroup:  "ngfw",
+		Credential:     cred,
+	}))
+	if err != nil {
+		...
+	}
+	defer client.Close()
+*/
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/account"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logging"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/metrics"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws"
+)
+
+// ErrNotSupported is returned by the calls of api.Client that have no
+// Azure counterpart, such as the AWS account onboarding ones.
+var ErrNotSupported = errors.New("not supported on Azure")
+
+// ErrNotSetUp is returned by the calls of a Client that was not set up.
+var ErrNotSetUp = errors.New("the client was not set up")
+
+// Client is the Azure Cloud NGFW client. Setup must be called before use.
+type Client struct {
+	// Host serves the Cloud NGFW API, and Region is the Azure location of
+	// the firewalls, such as eastus.
+	Host     string
+	Region   string
+	Protocol string
+
+	// SubscriptionId and ResourceGroup complete the firewall names that
+	// are not given as ARM IDs. ARM IDs can only be used with a
+	// ResourceGroup, and must be in it.
+	SubscriptionId string
+	ResourceGroup  string
+
+	// Credential gets the Azure AD tokens for Scopes, DefaultScope if
+	// empty. ApiKey is the subscription key sent along with them.
+	Credential TokenCredential
+	Scopes     []string
+	ApiKey     string
+
+	Timeout         int
+	ResourceTimeout int
+	Headers         map[string]string
+	Agent           string
+	SyncMode        bool
+
+	// CheckEnvironment fills the settings above that are not set from the
+	// CLOUDNGFWAZURE_* env vars.
+	CheckEnvironment bool
+
+	Transport  *http.Transport
+	Middleware []aws.Middleware
+	Metrics    metrics.Metrics
+	Logger     logging.Logger
+
+	// ngfw makes the API calls once the client is set up. Its AWS settings
+	// are kept out of the client's API: the calls of api.Client are
+	// forwarded to it one by one.
+	ngfw   *aws.Client
+	tokens *aws.TokenManager
+}
+
+// Setup checks the settings and gets the client ready to make calls.
+func (c *Client) Setup() error {
+	if c.CheckEnvironment {
+		for _, s := range []struct {
+			env string
+			val *string
+		}{
+			{"CLOUDNGFWAZURE_HOST", &c.Host},
+			{"CLOUDNGFWAZURE_REGION", &c.Region},
+			{"CLOUDNGFWAZURE_PROTOCOL", &c.Protocol},
+			{"CLOUDNGFWAZURE_SUBSCRIPTION_ID", &c.SubscriptionId},
+			{"CLOUDNGFWAZURE_RESOURCE_GROUP", &c.ResourceGroup},
+			{"CLOUDNGFWAZURE_API_KEY", &c.ApiKey},
+		} {
+			if val := os.Getenv(s.env); *s.val == "" && val != "" {
+				*s.val = val
+			}
+		}
+	}
+
+	if c.Host == "" {
+		return fmt.Errorf("No host was specified")
+	}
+	if c.Region == "" {
+		return fmt.Errorf("No region was specified")
+	}
+	if c.Credential == nil {
+		return fmt.Errorf("No Azure AD credential was specified")
+	}
+
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{DefaultScope}
+	}
+	if c.tokens != nil {
+		c.tokens.Close()
+	}
+	c.tokens = aws.NewTokenManager(&credentialSource{
+		cred:   c.Credential,
+		scopes: scopes,
+		apiKey: c.ApiKey,
+	}, aws.TokenManagerOptions{Margin: time.Minute})
+
+	n := &aws.Client{
+		Host:            c.Host,
+		V2Host:          c.Host,
+		MPRegionHost:    c.Host,
+		Region:          c.Region,
+		Protocol:        c.Protocol,
+		TenantVersion:   awsngfw.TenantVersionV2,
+		Timeout:         c.Timeout,
+		ResourceTimeout: c.ResourceTimeout,
+		Headers:         c.Headers,
+		Agent:           c.Agent,
+		SyncMode:        c.SyncMode,
+		Transport:       c.Transport,
+		Middleware:      c.Middleware,
+		Metrics:         c.Metrics,
+		Logger:          c.Logger,
+		TokenProvider:   c.tokens,
+	}
+	if err := n.Setup(); err != nil {
+		return err
+	}
+	c.ngfw = n
+	return nil
+}
+
+// Close releases the client's tokens. The client must not be used
+// afterwards.
+func (c *Client) Close() error {
+	if c.tokens != nil {
+		return c.tokens.Close()
+	}
+	return nil
+}
+
+// client returns the client making the API calls, or ErrNotSetUp.
+func (c *Client) client() (*aws.Client, error) {
+	if c.ngfw == nil {
+		return nil, ErrNotSetUp
+	}
+	return c.ngfw, nil
+}
+
+func (c *Client) IsSyncModeEnabled(ctx context.Context) bool {
+	return c.SyncMode
+}
+
+func (c *Client) GetResourceTimeout(ctx context.Context) int {
+	if c.ngfw == nil {
+		return c.ResourceTimeout
+	}
+	return c.ngfw.GetResourceTimeout(ctx)
+}
+
+func (c *Client) GetRegion(ctx context.Context) string {
+	return c.Region
+}
+
+// GetApiPrefix returns the URL prefix of the API calls, or "" before Setup.
+func (c *Client) GetApiPrefix(ctx context.Context) string {
+	if c.ngfw == nil {
+		return ""
+	}
+	return c.ngfw.GetApiPrefix(ctx)
+}
+
+func (c *Client) GetCloudProvider(ctx context.Context) string {
+	return awsngfw.CloudProviderAzure
+}
+
+// GetMPRegion returns the client's Region, as Azure has no separate
+// management plane region.
+func (c *Client) GetMPRegion(ctx context.Context) string {
+	return c.Region
+}
+
+// GetProfile returns "", as Azure has no AWS profile.
+func (c *Client) GetProfile(ctx context.Context) string {
+	return ""
+}
+
+func (c *Client) GetCloudNGFWServiceToken(ctx context.Context, info stack.AuthInput) (stack.AuthOutput, error) {
+	return stack.AuthOutput{}, ErrNotSupported
+}
+
+func (c *Client) CreateAccount(ctx context.Context, input account.CreateInput) (account.CreateOutput, error) {
+	return account.CreateOutput{}, ErrNotSupported
+}
+
+func (c *Client) ReadAccount(ctx context.Context, input account.ReadInput) (account.ReadOutput, error) {
+	return account.ReadOutput{}, ErrNotSupported
+}
+
+func (c *Client) ListAccounts(ctx context.Context, input account.ListInput) (account.ListOutput, error) {
+	return account.ListOutput{}, ErrNotSupported
+}
+
+func (c *Client) DeleteAccount(ctx context.Context, input account.DeleteInput) error {
+	return ErrNotSupported
+}
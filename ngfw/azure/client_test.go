@@ -0,0 +1,194 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/account"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/prefix"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/security"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/ngfw/aws/ngfwtest"
+)
+
+const testSubscription = "00000000-0000-0000-0000-000000000001"
+
+func TestParseArmId(t *testing.T) {
+	s := "/subscriptions/" + testSubscription + "/resourceGroups/ngfw/providers/PaloAltoNetworks.Cloudngfw/firewalls/fw1"
+	id, err := ParseArmId(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.SubscriptionId != testSubscription || id.ResourceGroup != "ngfw" || id.Type != FirewallResourceType || id.Name != "fw1" || id.String() != s {
+		t.Fatalf("bad parse of %s: %+v", s, id)
+	}
+
+	for _, bad := range []string{"fw1", "/subscriptions/x/resourceGroups/y", "/subscriptions/x/groups/y/providers/a/b/c"} {
+		if _, err = ParseArmId(bad); err == nil {
+			t.Fatalf("%q should not parse", bad)
+		}
+	}
+}
+
+func TestResolveNeedsResourceGroup(t *testing.T) {
+	armId := "/subscriptions/" + testSubscription + "/resourceGroups/ngfw/providers/PaloAltoNetworks.Cloudngfw/firewalls/fw1"
+	c := &Client{SubscriptionId: testSubscription}
+	if _, _, err := c.resolve(armId, ""); err == nil {
+		t.Fatalf("an ARM ID was accepted without a resource group")
+	}
+	if name, sub, err := c.resolve("fw1", ""); err != nil || name != "fw1" || sub != testSubscription {
+		t.Fatalf("plain name resolved to %q, %q: %v", name, sub, err)
+	}
+
+	c.ResourceGroup = "NGFW"
+	if name, sub, err := c.resolve(armId, ""); err != nil || name != "fw1" || sub != testSubscription {
+		t.Fatalf("ARM ID resolved to %q, %q: %v", name, sub, err)
+	}
+}
+
+func TestClientNotSetUp(t *testing.T) {
+	ctx := context.Background()
+	c := &Client{Region: "eastus"}
+	if _, err := c.ListFirewall(ctx, firewall.ListInput{}); !errors.Is(err, ErrNotSetUp) {
+		t.Fatalf("expected ErrNotSetUp, got %v", err)
+	}
+	if _, err := c.ReadFirewall(ctx, firewall.ReadInput{Name: "fw1"}); !errors.Is(err, ErrNotSetUp) {
+		t.Fatalf("expected ErrNotSetUp, got %v", err)
+	}
+	if c.GetRegion(ctx) != "eastus" || c.GetApiPrefix(ctx) != "" {
+		t.Fatalf("wrong settings before setup")
+	}
+}
+
+func TestClientAgainstFake(t *testing.T) {
+	api.SetLogger(zap.NewNop().Sugar())
+	ctx := context.WithValue(context.Background(), "SchemaVersion", awsngfw.SchemaVersionV2)
+	srv := ngfwtest.NewServer()
+	defer srv.Close()
+
+	jwt, key := srv.Token(ngfwtest.PermCloudManager)
+	var fetches int32
+	c := &Client{
+		Host:           srv.Host(),
+		Protocol:       "http",
+		Region:         "eastus",
+		SubscriptionId: testSubscription,
+		ResourceGroup:  "ngfw",
+		ApiKey:         key,
+		Credential: TokenCredentialFunc(func(_ context.Context, scopes []string) (AccessToken, error) {
+			if len(scopes) != 1 || scopes[0] != DefaultScope {
+				t.Errorf("unexpected scopes %v", scopes)
+			}
+			atomic.AddInt32(&fetches, 1)
+			return AccessToken{Token: jwt, ExpiresOn: time.Now().Add(time.Hour)}, nil
+		}),
+	}
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The client must be usable wherever an api.Client is.
+	var _ api.Client = c
+
+	if c.GetCloudProvider(ctx) != awsngfw.CloudProviderAzure {
+		t.Fatalf("cloud provider is %q", c.GetCloudProvider(ctx))
+	}
+	if _, err := c.ListAccounts(ctx, account.ListInput{}); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("listing accounts should not be supported, got %v", err)
+	}
+
+	if err := c.CreateRuleStack(ctx, stack.Info{Name: "rs1", Entry: stack.Details{Scope: aws.LocalScope}}); err != nil {
+		t.Fatalf("create rulestack: %s", err)
+	}
+	if err := c.CreatePrefixList(ctx, prefix.Info{Rulestack: "rs1", Name: "pl1", PrefixList: []string{"10.0.0.0/8"}}); err != nil {
+		t.Fatalf("create prefix list: %s", err)
+	}
+	rule := security.Info{
+		Rulestack: "rs1",
+		RuleList:  security.LOCAL_RULE,
+		Priority:  10,
+		Entry: security.Details{
+			Name:         "allow-internal",
+			Enabled:      true,
+			Source:       security.SourceDetails{PrefixLists: []string{"pl1"}},
+			Destination:  security.DestinationDetails{Cidrs: []string{"any"}},
+			Applications: []string{"any"},
+			Action:       "Allow",
+		},
+	}
+	if err := c.CreateSecurityRule(ctx, rule); err != nil {
+		t.Fatalf("create security rule: %s", err)
+	}
+
+	armId := "/subscriptions/" + testSubscription + "/resourceGroups/ngfw/providers/PaloAltoNetworks.Cloudngfw/firewalls/fw1"
+	fw, err := c.CreateFirewall(ctx, firewall.Info{Name: armId, VpcId: "vnet-1"})
+	if err != nil {
+		t.Fatalf("create firewall: %s", err)
+	}
+	if fw.Response.Name != "fw1" || fw.Response.AccountId != testSubscription {
+		t.Fatalf("firewall was not created from its ARM ID: %+v", fw.Response)
+	}
+
+	list, err := c.ListFirewall(ctx, firewall.ListInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Response.Firewalls) != 1 {
+		t.Fatalf("unexpected firewalls: %+v", list.Response.Firewalls)
+	}
+	if id, err := c.FirewallArmId(list.Response.Firewalls[0]); err != nil || id != armId {
+		t.Fatalf("wrong ARM ID %q: %v", id, err)
+	}
+	if _, err = c.ReadFirewall(ctx, firewall.ReadInput{Name: armId, FirewallId: fw.Response.Id}); err != nil {
+		t.Fatalf("read firewall by ARM ID: %s", err)
+	}
+	if _, err = c.ReadFirewall(ctx, firewall.ReadInput{Name: armId, AccountId: "other"}); err == nil {
+		t.Fatalf("reading a firewall outside its subscription should fail")
+	}
+	otherGroup := strings.Replace(armId, "/ngfw/", "/other/", 1)
+	if _, err = c.ReadFirewall(ctx, firewall.ReadInput{Name: otherGroup}); err == nil {
+		t.Fatalf("reading a firewall outside the resource group should fail")
+	}
+	if err = c.SaveRuleStackXML(ctx, stack.SaveRulestackXmlInput{Name: "rs1", Firewalls: []stack.FirewallEntry{{ArmId: otherGroup}}}); err == nil {
+		t.Fatalf("saving a firewall entry outside the resource group should fail")
+	}
+	if err = c.SaveRuleStackXML(ctx, stack.SaveRulestackXmlInput{Name: "rs1", Firewalls: []stack.FirewallEntry{{Firewall: "fw2", ArmId: armId}}}); err == nil {
+		t.Fatalf("saving a firewall entry with a name not matching its ARM ID should fail")
+	}
+
+	err = c.SaveRuleStackXML(ctx, stack.SaveRulestackXmlInput{
+		Name:              "rs1",
+		RuleStackEntryXml: stack.XmlString{Xml: "<entry/>"},
+		Firewalls:         []stack.FirewallEntry{{ArmId: armId}, {Firewall: "fw2"}},
+	})
+	if err != nil {
+		t.Fatalf("save rulestack xml: %s", err)
+	}
+	var saved stack.SaveRulestackXmlInput
+	for _, r := range srv.Requests() {
+		if strings.HasSuffix(r.Path, "/xml") {
+			if err = json.Unmarshal(r.Body, &saved); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if len(saved.Firewalls) != 2 || saved.Firewalls[0].Firewall != "fw1" || saved.Firewalls[1].ArmId != strings.Replace(armId, "fw1", "fw2", 1) {
+		t.Fatalf("firewall entries were not filled in: %+v", saved.Firewalls)
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("the Azure AD token was fetched %d times", n)
+	}
+}
@@ -0,0 +1,336 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/logprofile"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+)
+
+/*
+The firewall calls below accept a firewall name given as an ARM ID, turning
+it into the name and subscription (the AccountId) the API expects. Plain
+names are in the client's SubscriptionId unless an AccountId is given.
+
+The API has no notion of resource groups, so the firewalls are taken to be
+in the client's ResourceGroup, and ARM IDs of other resource groups are
+rejected. A client without a ResourceGroup rejects all ARM IDs, as two
+firewalls of the same name in different groups would otherwise be mixed up.
+*/
+
+// resolve returns the name and subscription of a firewall given by name or
+// ARM ID.
+func (c *Client) resolve(name, accountId string) (string, string, error) {
+	if !IsArmId(name) {
+		if accountId == "" {
+			accountId = c.SubscriptionId
+		}
+		return name, accountId, nil
+	}
+
+	id, err := ParseArmId(name)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.EqualFold(id.Type, FirewallResourceType) {
+		return "", "", fmt.Errorf("%s is a %s, not a firewall", name, id.Type)
+	}
+	if accountId != "" && accountId != id.SubscriptionId {
+		return "", "", fmt.Errorf("%s is not in subscription %s", name, accountId)
+	}
+	if c.ResourceGroup == "" {
+		return "", "", fmt.Errorf("%s can't be used without the client's ResourceGroup", name)
+	}
+	if !strings.EqualFold(id.ResourceGroup, c.ResourceGroup) {
+		return "", "", fmt.Errorf("%s is not in resource group %s", name, c.ResourceGroup)
+	}
+	return id.Name, id.SubscriptionId, nil
+}
+
+// FirewallArmId returns the ARM ID of a firewall listed by the client,
+// which is in the client's ResourceGroup. It fails if the client has none.
+func (c *Client) FirewallArmId(fw firewall.ListFirewall) (string, error) {
+	if c.ResourceGroup == "" {
+		return "", fmt.Errorf("the ARM ID of %s needs the client's ResourceGroup", fw.Name)
+	}
+	sub := fw.AccountId
+	if sub == "" {
+		sub = c.SubscriptionId
+	}
+	return ArmId{
+		SubscriptionId: sub,
+		ResourceGroup:  c.ResourceGroup,
+		Type:           FirewallResourceType,
+		Name:           fw.Name,
+	}.String(), nil
+}
+
+func (c *Client) CreateFirewall(ctx context.Context, input firewall.Info) (firewall.CreateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.CreateOutput{}, err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return firewall.CreateOutput{}, err
+	}
+	return n.CreateFirewall(ctx, input)
+}
+
+func (c *Client) CreateFirewallWithWait(ctx context.Context, input firewall.Info) (firewall.CreateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.CreateOutput{}, err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return firewall.CreateOutput{}, err
+	}
+	return n.CreateFirewallWithWait(ctx, input)
+}
+
+func (c *Client) ReadFirewall(ctx context.Context, input firewall.ReadInput) (firewall.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.ReadOutput{}, err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return firewall.ReadOutput{}, err
+	}
+	return n.ReadFirewall(ctx, input)
+}
+
+func (c *Client) ModifyFirewall(ctx context.Context, input firewall.Info) (firewall.UpdateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.UpdateOutput{}, err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return firewall.UpdateOutput{}, err
+	}
+	return n.ModifyFirewall(ctx, input)
+}
+
+func (c *Client) ModifyFirewallV1(ctx context.Context, input firewall.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return err
+	}
+	return n.ModifyFirewallV1(ctx, input)
+}
+
+func (c *Client) ModifyFirewallWithWait(ctx context.Context, input firewall.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return err
+	}
+	return n.ModifyFirewallWithWait(ctx, input)
+}
+
+func (c *Client) ReadAndModifyFirewall(ctx context.Context, input firewall.Info) (firewall.UpdateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.UpdateOutput{}, err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return firewall.UpdateOutput{}, err
+	}
+	return n.ReadAndModifyFirewall(ctx, input)
+}
+
+func (c *Client) DeleteFirewall(ctx context.Context, input firewall.DeleteInput) (firewall.DeleteOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.DeleteOutput{}, err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return firewall.DeleteOutput{}, err
+	}
+	return n.DeleteFirewall(ctx, input)
+}
+
+func (c *Client) DeleteFirewallWithWait(ctx context.Context, input firewall.DeleteInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Name, input.AccountId, err = c.resolve(input.Name, input.AccountId); err != nil {
+		return err
+	}
+	return n.DeleteFirewallWithWait(ctx, input)
+}
+
+func (c *Client) ListTagsForFirewall(ctx context.Context, input firewall.ListTagsInput) (firewall.ListTagsOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.ListTagsOutput{}, err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return firewall.ListTagsOutput{}, err
+	}
+	return n.ListTagsForFirewall(ctx, input)
+}
+
+func (c *Client) AssociateRulestack(ctx context.Context, input firewall.AssociateInput) (firewall.AssociateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.AssociateOutput{}, err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return firewall.AssociateOutput{}, err
+	}
+	return n.AssociateRulestack(ctx, input)
+}
+
+func (c *Client) AssociateRulestackWithWait(ctx context.Context, input firewall.AssociateInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return err
+	}
+	return n.AssociateRulestackWithWait(ctx, input)
+}
+
+func (c *Client) AssociateGlobalRuleStack(ctx context.Context, input firewall.AssociateInput) (firewall.AssociateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.AssociateOutput{}, err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return firewall.AssociateOutput{}, err
+	}
+	return n.AssociateGlobalRuleStack(ctx, input)
+}
+
+func (c *Client) DisassociateRuleStack(ctx context.Context, input firewall.DisAssociateInput) (firewall.DisAssociateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.DisAssociateOutput{}, err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return firewall.DisAssociateOutput{}, err
+	}
+	return n.DisassociateRuleStack(ctx, input)
+}
+
+func (c *Client) DisassociateRuleStackWithWait(ctx context.Context, input firewall.DisAssociateInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return err
+	}
+	return n.DisassociateRuleStackWithWait(ctx, input)
+}
+
+func (c *Client) DisAssociateGlobalRuleStack(ctx context.Context, input firewall.DisAssociateInput) (firewall.DisAssociateOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.DisAssociateOutput{}, err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return firewall.DisAssociateOutput{}, err
+	}
+	return n.DisAssociateGlobalRuleStack(ctx, input)
+}
+
+func (c *Client) ReadFirewallLogprofile(ctx context.Context, input logprofile.ReadInput) (logprofile.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return logprofile.ReadOutput{}, err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return logprofile.ReadOutput{}, err
+	}
+	return n.ReadFirewallLogprofile(ctx, input)
+}
+
+func (c *Client) UpdateFirewallLogprofile(ctx context.Context, input logprofile.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Firewall, input.AccountId, err = c.resolve(input.Firewall, input.AccountId); err != nil {
+		return err
+	}
+	return n.UpdateFirewallLogprofile(ctx, input)
+}
+
+/*
+firewallEntries fills in the firewall entries of a rulestack: entries with
+an ArmId get their name and subscription from it, and entries with only a
+name get the ArmId of the firewall in the client's ResourceGroup. A name
+given along with an ArmId must be that of the same firewall.
+*/
+func (c *Client) firewallEntries(entries []stack.FirewallEntry) ([]stack.FirewallEntry, error) {
+	if entries == nil {
+		return nil, nil
+	}
+
+	ans := make([]stack.FirewallEntry, 0, len(entries))
+	for _, fw := range entries {
+		if fw.Firewall == "" && fw.ArmId == "" {
+			return nil, fmt.Errorf("firewall entries need a name or an ARM ID")
+		}
+		name, sub, err := c.resolve(fw.Firewall, fw.AccountId)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case fw.ArmId != "":
+			armName, armSub, err := c.resolve(fw.ArmId, fw.AccountId)
+			if err != nil {
+				return nil, err
+			}
+			if fw.Firewall != "" && (name != armName || (IsArmId(fw.Firewall) && sub != armSub)) {
+				return nil, fmt.Errorf("firewall %s is not %s", fw.Firewall, fw.ArmId)
+			}
+			name, sub = armName, armSub
+		case IsArmId(fw.Firewall):
+			fw.ArmId = fw.Firewall
+		default:
+			if fw.ArmId, err = c.FirewallArmId(firewall.ListFirewall{Name: name, AccountId: sub}); err != nil {
+				return nil, err
+			}
+		}
+		fw.Firewall, fw.AccountId = name, sub
+		ans = append(ans, fw)
+	}
+	return ans, nil
+}
+
+// SaveRuleStackXML saves a rulestack's XML, with its firewall entries
+// filled in from their ARM IDs or names.
+func (c *Client) SaveRuleStackXML(ctx context.Context, input stack.SaveRulestackXmlInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Firewalls, err = c.firewallEntries(input.Firewalls); err != nil {
+		return err
+	}
+	return n.SaveRuleStackXML(ctx, input)
+}
+
+// CreateSCMRuleStack creates an SCM rulestack, with its firewall entries
+// filled in from their ARM IDs or names.
+func (c *Client) CreateSCMRuleStack(ctx context.Context, input stack.CreateSCMRuleStackInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	if input.Firewalls, err = c.firewallEntries(input.Firewalls); err != nil {
+		return err
+	}
+	return n.CreateSCMRuleStack(ctx, input)
+}
@@ -0,0 +1,463 @@
+package azure
+
+import (
+	"context"
+
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/appid"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/certificate"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/country"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/feed"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/firewall"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/fqdn"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/predefinedurl"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/prefix"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/security"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/stack"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api/url"
+)
+
+/*
+The calls below are made as is by the client underneath, once the client is
+set up.
+*/
+
+func (c *Client) ListFeed(ctx context.Context, input feed.ListInput) (feed.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return feed.ListOutput{}, err
+	}
+	return n.ListFeed(ctx, input)
+}
+
+func (c *Client) CreateFeed(ctx context.Context, input feed.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CreateFeed(ctx, input)
+}
+
+func (c *Client) ReadFeed(ctx context.Context, input feed.ReadInput) (feed.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return feed.ReadOutput{}, err
+	}
+	return n.ReadFeed(ctx, input)
+}
+
+func (c *Client) UpdateFeed(ctx context.Context, input feed.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdateFeed(ctx, input)
+}
+
+func (c *Client) DeleteFeed(ctx context.Context, input feed.DeleteInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.DeleteFeed(ctx, input)
+}
+
+func (c *Client) ListSecurityRule(ctx context.Context, input security.ListInput) (security.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return security.ListOutput{}, err
+	}
+	return n.ListSecurityRule(ctx, input)
+}
+
+func (c *Client) CreateSecurityRule(ctx context.Context, input security.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CreateSecurityRule(ctx, input)
+}
+
+func (c *Client) ReadSecurityRule(ctx context.Context, input security.ReadInput) (security.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return security.ReadOutput{}, err
+	}
+	return n.ReadSecurityRule(ctx, input)
+}
+
+func (c *Client) UpdateSecurityRule(ctx context.Context, input security.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdateSecurityRule(ctx, input)
+}
+
+func (c *Client) DeleteSecurityRule(ctx context.Context, input security.DeleteInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.DeleteSecurityRule(ctx, input)
+}
+
+func (c *Client) ListRuleStack(ctx context.Context, input stack.ListInput) (stack.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return stack.ListOutput{}, err
+	}
+	return n.ListRuleStack(ctx, input)
+}
+
+func (c *Client) CreateRuleStack(ctx context.Context, input stack.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CreateRuleStack(ctx, input)
+}
+
+func (c *Client) ReadRuleStack(ctx context.Context, input stack.ReadInput) (stack.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return stack.ReadOutput{}, err
+	}
+	return n.ReadRuleStack(ctx, input)
+}
+
+func (c *Client) ExportRuleStackXML(ctx context.Context, input stack.ReadInput) (stack.ExportRulestackXmlOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return stack.ExportRulestackXmlOutput{}, err
+	}
+	return n.ExportRuleStackXML(ctx, input)
+}
+
+func (c *Client) UpdateRuleStack(ctx context.Context, input stack.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdateRuleStack(ctx, input)
+}
+
+func (c *Client) DeleteRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.DeleteRuleStack(ctx, input)
+}
+
+func (c *Client) CommitRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CommitRuleStack(ctx, input)
+}
+
+func (c *Client) PollCommitRuleStack(ctx context.Context, input stack.SimpleInput) (stack.CommitStatus, error) {
+	n, err := c.client()
+	if err != nil {
+		return stack.CommitStatus{}, err
+	}
+	return n.PollCommitRuleStack(ctx, input)
+}
+
+func (c *Client) CommitStatusRuleStack(ctx context.Context, input stack.SimpleInput) (stack.CommitStatus, error) {
+	n, err := c.client()
+	if err != nil {
+		return stack.CommitStatus{}, err
+	}
+	return n.CommitStatusRuleStack(ctx, input)
+}
+
+func (c *Client) RevertRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.RevertRuleStack(ctx, input)
+}
+
+func (c *Client) ValidateRuleStack(ctx context.Context, input stack.SimpleInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.ValidateRuleStack(ctx, input)
+}
+
+func (c *Client) ListTagsRuleStack(ctx context.Context, input stack.ListTagsInput) (stack.ListTagsOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return stack.ListTagsOutput{}, err
+	}
+	return n.ListTagsRuleStack(ctx, input)
+}
+
+func (c *Client) AddTagsRuleStack(ctx context.Context, input stack.AddTagsInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.AddTagsRuleStack(ctx, input)
+}
+
+func (c *Client) RemoveTagsRuleStack(ctx context.Context, input stack.RemoveTagsInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.RemoveTagsRuleStack(ctx, input)
+}
+
+func (c *Client) ApplyTagsRuleStack(ctx context.Context, input stack.AddTagsInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.ApplyTagsRuleStack(ctx, input)
+}
+
+func (c *Client) ListAppID(ctx context.Context, input appid.ListInput) (appid.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return appid.ListOutput{}, err
+	}
+	return n.ListAppID(ctx, input)
+}
+
+func (c *Client) ReadAppID(ctx context.Context, input appid.ReadInput) (appid.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return appid.ReadOutput{}, err
+	}
+	return n.ReadAppID(ctx, input)
+}
+
+func (c *Client) ReadApplication(ctx context.Context, version, app string) (appid.ReadApplicationOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return appid.ReadApplicationOutput{}, err
+	}
+	return n.ReadApplication(ctx, version, app)
+}
+
+func (c *Client) ListCertificate(ctx context.Context, input certificate.ListInput) (certificate.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return certificate.ListOutput{}, err
+	}
+	return n.ListCertificate(ctx, input)
+}
+
+func (c *Client) CreateCertificate(ctx context.Context, input certificate.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CreateCertificate(ctx, input)
+}
+
+func (c *Client) ReadCertificate(ctx context.Context, input certificate.ReadInput) (certificate.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return certificate.ReadOutput{}, err
+	}
+	return n.ReadCertificate(ctx, input)
+}
+
+func (c *Client) UpdateCertificate(ctx context.Context, input certificate.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdateCertificate(ctx, input)
+}
+
+func (c *Client) DeleteCertificate(ctx context.Context, input certificate.DeleteInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.DeleteCertificate(ctx, input)
+}
+
+func (c *Client) ListCountry(ctx context.Context, input country.ListInput) (country.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return country.ListOutput{}, err
+	}
+	return n.ListCountry(ctx, input)
+}
+
+func (c *Client) ListFqdn(ctx context.Context, input fqdn.ListInput) (fqdn.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return fqdn.ListOutput{}, err
+	}
+	return n.ListFqdn(ctx, input)
+}
+
+func (c *Client) CreateFqdn(ctx context.Context, input fqdn.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CreateFqdn(ctx, input)
+}
+
+func (c *Client) ReadFqdn(ctx context.Context, input fqdn.ReadInput) (fqdn.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return fqdn.ReadOutput{}, err
+	}
+	return n.ReadFqdn(ctx, input)
+}
+
+func (c *Client) UpdateFqdn(ctx context.Context, input fqdn.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdateFqdn(ctx, input)
+}
+
+func (c *Client) DeleteFqdn(ctx context.Context, input fqdn.DeleteInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.DeleteFqdn(ctx, input)
+}
+
+func (c *Client) ListUrlPredefinedCategories(ctx context.Context, input predefinedurl.ListInput) (predefinedurl.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return predefinedurl.ListOutput{}, err
+	}
+	return n.ListUrlPredefinedCategories(ctx, input)
+}
+
+func (c *Client) ListUrlCategoriesActionOverride(ctx context.Context, input predefinedurl.ListOverridesInput) (predefinedurl.ListOverridesOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return predefinedurl.ListOverridesOutput{}, err
+	}
+	return n.ListUrlCategoriesActionOverride(ctx, input)
+}
+
+func (c *Client) DescribeUrlCategoryActionOverride(ctx context.Context, input predefinedurl.GetOverrideInput) (predefinedurl.GetOverrideOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return predefinedurl.GetOverrideOutput{}, err
+	}
+	return n.DescribeUrlCategoryActionOverride(ctx, input)
+}
+
+func (c *Client) UpdateUrlCategoryActionOverride(ctx context.Context, input predefinedurl.OverrideInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdateUrlCategoryActionOverride(ctx, input)
+}
+
+func (c *Client) ListPrefixList(ctx context.Context, input prefix.ListInput) (prefix.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return prefix.ListOutput{}, err
+	}
+	return n.ListPrefixList(ctx, input)
+}
+
+func (c *Client) CreatePrefixList(ctx context.Context, input prefix.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CreatePrefixList(ctx, input)
+}
+
+func (c *Client) ReadPrefixList(ctx context.Context, input prefix.ReadInput) (prefix.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return prefix.ReadOutput{}, err
+	}
+	return n.ReadPrefixList(ctx, input)
+}
+
+func (c *Client) UpdatePrefixList(ctx context.Context, input prefix.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdatePrefixList(ctx, input)
+}
+
+func (c *Client) DeletePrefixList(ctx context.Context, input prefix.DeleteInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.DeletePrefixList(ctx, input)
+}
+
+func (c *Client) ListUrlCustomCategory(ctx context.Context, input url.ListInput) (url.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return url.ListOutput{}, err
+	}
+	return n.ListUrlCustomCategory(ctx, input)
+}
+
+func (c *Client) CreateUrlCustomCategory(ctx context.Context, input url.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.CreateUrlCustomCategory(ctx, input)
+}
+
+func (c *Client) ReadUrlCustomCategory(ctx context.Context, input url.ReadInput) (url.ReadOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return url.ReadOutput{}, err
+	}
+	return n.ReadUrlCustomCategory(ctx, input)
+}
+
+func (c *Client) UpdateUrlCustomCategory(ctx context.Context, input url.Info) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.UpdateUrlCustomCategory(ctx, input)
+}
+
+func (c *Client) DeleteUrlCustomCategory(ctx context.Context, input url.DeleteInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.DeleteUrlCustomCategory(ctx, input)
+}
+
+func (c *Client) ListFirewall(ctx context.Context, input firewall.ListInput) (firewall.ListOutput, error) {
+	n, err := c.client()
+	if err != nil {
+		return firewall.ListOutput{}, err
+	}
+	return n.ListFirewall(ctx, input)
+}
+
+func (c *Client) SetEndpoint(ctx context.Context, input api.EndPointInput) error {
+	n, err := c.client()
+	if err != nil {
+		return err
+	}
+	return n.SetEndpoint(ctx, input)
+}
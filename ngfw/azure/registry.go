@@ -0,0 +1,31 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+
+	awsngfw "github.com/paloaltonetworks/cloud-ngfw-aws-go/v2"
+	"github.com/paloaltonetworks/cloud-ngfw-aws-go/v2/api"
+)
+
+var _ api.Client = (*Client)(nil)
+
+func init() {
+	api.Register(awsngfw.CloudProviderAzure, newAPIClient)
+}
+
+// newAPIClient is the api.Provider of Azure. Its settings must be a *Client
+// that was not set up yet; the Credential can't come from the environment.
+func newAPIClient(ctx context.Context, pc api.ProviderConfig) (api.Client, error) {
+	c, ok := pc.Settings.(*Client)
+	if !ok || c == nil {
+		return nil, fmt.Errorf("settings must be an *azure.Client, not %T", pc.Settings)
+	}
+	if c.Logger == nil {
+		c.Logger = pc.Logger
+	}
+	if err := c.Setup(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}